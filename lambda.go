@@ -2,6 +2,7 @@ package lambda
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"log"
@@ -13,7 +14,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
-	"github.com/aws/smithy-go"
 	smithyHttp "github.com/aws/smithy-go/transport/http"
 )
 
@@ -44,6 +44,31 @@ type secretsmanagerTriggerPayload struct {
 	Step string `json:"Step"`
 }
 
+// RotationEvent defines the details of the rotation step.
+type RotationEvent struct {
+	// SecretARN the secret ARN or identifier
+	SecretARN string
+
+	// ClientRequestToken the ClientRequestToken of the secret's version to rotate to
+	ClientRequestToken string
+
+	// Step the rotation step (one of createSecret, setSecret, testSecret, or finishSecret)
+	Step string
+}
+
+type contextKeyRotationEvent struct{}
+
+// ContextWithEvent returns the copy of the context which carries the details of the rotation step.
+func ContextWithEvent(ctx context.Context, event RotationEvent) context.Context {
+	return context.WithValue(ctx, contextKeyRotationEvent{}, event)
+}
+
+// EventFromContext returns the details of the rotation step from the context passed to the `ServiceClient`'s methods.
+func EventFromContext(ctx context.Context) (RotationEvent, bool) {
+	v, ok := ctx.Value(contextKeyRotationEvent{}).(RotationEvent)
+	return v, ok
+}
+
 // NewHandler initialises lambda handler.
 func NewHandler(cfg Config) (func(ctx context.Context, event secretsmanagerTriggerPayload) error, error) {
 	if cfg.SecretObj == nil {
@@ -56,6 +81,14 @@ func NewHandler(cfg Config) (func(ctx context.Context, event secretsmanagerTrigg
 				"[DEBUG] arn: " + event.SecretARN + "; step: " + event.Step + "; token: " + event.Token + "\n",
 			)
 		}
+		ctx = ContextWithEvent(
+			ctx, RotationEvent{
+				SecretARN:          event.SecretARN,
+				ClientRequestToken: event.Token,
+				Step:               event.Step,
+			},
+		)
+
 		if err := validateInput(ctx, event, cfg.SecretsmanagerClient); err != nil {
 			if cfg.Debug {
 				log.Println("[DEBUG] validation error:+" + err.Error() + "\n")
@@ -107,12 +140,90 @@ type ServiceClient interface {
 	Create(ctx context.Context, secret any) error
 
 	// Set sets newly generated credentials in the system delegated credentials storage.
+	// secretPrevious is nil if the secret's version staged as AWSPREVIOUS does not exist.
 	Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error
 
 	// Test tries to connect to the system delegated credentials storage using newly generated secret.
 	Test(ctx context.Context, secret any) error
 }
 
+// ServiceClientFinalizer defines the optional extension of the `ServiceClient` to clean up
+// after the newly generated secret's version was moved to the stage AWSCURRENT.
+type ServiceClientFinalizer interface {
+	// Finalize is called upon the step finishSecret with the secret's versions staged as AWSCURRENT and AWSPREVIOUS,
+	// e.g. to revoke the credentials of the previous version. secretPrevious is nil if no previous version exists.
+	Finalize(ctx context.Context, secretCurrent, secretPrevious any) error
+}
+
+// ServiceClientDiscarder defines the optional extension of the `ServiceClient` to discard the credentials generated
+// upon the step createSecret which could not be stored as the secret's version staged as AWSPENDING.
+type ServiceClientDiscarder interface {
+	// Discard is called upon the step createSecret with the secret generated by `Create` if it could not be stored,
+	// e.g. to revoke the credentials created by `Create` which would be orphaned otherwise.
+	Discard(ctx context.Context, secret any) error
+}
+
+// ServiceClientFactory initiates the `ServiceClient`, e.g. using the admin credentials read from the secretsmanager.
+type ServiceClientFactory func(ctx context.Context) (ServiceClient, error)
+
+// NewServiceClientPerInvocation returns the `ServiceClient` which is initiated by the factory upon every call,
+// i.e. upon every lambda invocation. It allows to pick up the admin credentials rotated by another lambda
+// instead of caching them at the lambda's cold start. The `ServiceClientFinalizer` and `ServiceClientDiscarder` of the
+// initiated client are called if implemented.
+func NewServiceClientPerInvocation(factory ServiceClientFactory) ServiceClient {
+	return serviceClientPerInvocation{factory: factory}
+}
+
+type serviceClientPerInvocation struct {
+	factory ServiceClientFactory
+}
+
+func (c serviceClientPerInvocation) Create(ctx context.Context, secret any) error {
+	client, err := c.factory(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Create(ctx, secret)
+}
+
+func (c serviceClientPerInvocation) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	client, err := c.factory(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Set(ctx, secretCurrent, secretPending, secretPrevious)
+}
+
+func (c serviceClientPerInvocation) Test(ctx context.Context, secret any) error {
+	client, err := c.factory(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Test(ctx, secret)
+}
+
+func (c serviceClientPerInvocation) Finalize(ctx context.Context, secretCurrent, secretPrevious any) error {
+	client, err := c.factory(ctx)
+	if err != nil {
+		return err
+	}
+	if f, ok := client.(ServiceClientFinalizer); ok {
+		return f.Finalize(ctx, secretCurrent, secretPrevious)
+	}
+	return nil
+}
+
+func (c serviceClientPerInvocation) Discard(ctx context.Context, secret any) error {
+	client, err := c.factory(ctx)
+	if err != nil {
+		return err
+	}
+	if d, ok := client.(ServiceClientDiscarder); ok {
+		return d.Discard(ctx, secret)
+	}
+	return nil
+}
+
 // validateInput checks if the secret version is staged correctly.
 func validateInput(ctx context.Context, event secretsmanagerTriggerPayload, client SecretsmanagerClient) error {
 	v, err := client.DescribeSecret(
@@ -206,14 +317,38 @@ func createSecret(ctx context.Context, event secretsmanagerTriggerPayload, cfg C
 			VersionStages:      []string{"AWSPENDING"},
 		},
 	)
-	if err != nil && cfg.Debug {
+	if err != nil {
 		if cfg.Debug {
 			log.Println("[DEBUG] error: " + err.Error())
 		}
+		discardSecret(ctx, event, cfg)
 	}
 	return err
 }
 
+// discardSecret calls the `ServiceClientDiscarder` with the generated secret which could not be stored as AWSPENDING.
+// The secret is not discarded if the version staged as AWSPENDING exists nonetheless, e.g. if the request
+// timed out after the version was stored, because the retried step createSecret would reuse it.
+func discardSecret(ctx context.Context, event secretsmanagerTriggerPayload, cfg Config) {
+	d, ok := cfg.ServiceClient.(ServiceClientDiscarder)
+	if !ok {
+		return
+	}
+
+	if _, err := getSecretValue(
+		ctx, cfg.SecretsmanagerClient, event.SecretARN, "AWSPENDING", event.Token,
+	); err == nil {
+		return
+	}
+
+	if cfg.Debug {
+		log.Println("[DEBUG] Discard newly generated secret")
+	}
+	if err := d.Discard(ctx, cfg.SecretObj); err != nil {
+		log.Println("[WARN] newly generated secret cannot be discarded: " + err.Error())
+	}
+}
+
 // setSecret sets the AWSPENDING secret in the service that the secret belongs to.
 // For example, if the secret is a database credential,
 // this method should take the value of the AWSPENDING secret
@@ -222,20 +357,8 @@ func setSecret(ctx context.Context, event secretsmanagerTriggerPayload, cfg Conf
 	if cfg.Debug {
 		log.Println("[DEBUG] Fetch AWSPREVIOUS of the secret: " + event.SecretARN)
 	}
-	secretPrevious, err := getSecretValue(ctx, cfg.SecretsmanagerClient, event.SecretARN, "AWSPREVIOUS", "")
-	switch err.(type) {
-	case *types.ResourceNotFoundException, nil:
-		secretPrevious = nil
-	case *smithy.OperationError:
-		if e, ok := err.(*smithy.OperationError).Unwrap().(*smithyHttp.ResponseError); ok {
-			switch e.HTTPStatusCode() {
-			case http.StatusBadRequest, http.StatusNotFound:
-				secretPrevious = nil
-			default:
-				return err
-			}
-		}
-	default:
+	secretPrevious, err := getSecretPrevious(ctx, cfg.SecretsmanagerClient, event.SecretARN)
+	if err != nil {
 		if cfg.Debug {
 			log.Println("[DEBUG] error: " + err.Error())
 		}
@@ -280,9 +403,10 @@ func setSecret(ctx context.Context, event secretsmanagerTriggerPayload, cfg Conf
 		return err
 	}
 
-	previous := initNewSecretObj(cfg.SecretObj)
+	var previous any
 	if secretPrevious != nil {
-		if err := ExtractSecretObject(secretPending, previous); err != nil {
+		previous = initNewSecretObj(cfg.SecretObj)
+		if err := ExtractSecretObject(secretPrevious, previous); err != nil {
 			return err
 		}
 	}
@@ -422,7 +546,7 @@ func finishSecret(ctx context.Context, event secretsmanagerTriggerPayload, cfg C
 						if cfg.Debug {
 							log.Println("[DEBUG] version " + version + " is already at the stage AWSCURRENT")
 						}
-						return nil
+						return finalize(ctx, event, cfg)
 					}
 					currentVersion = version
 				}
@@ -433,15 +557,69 @@ func finishSecret(ctx context.Context, event secretsmanagerTriggerPayload, cfg C
 	if cfg.Debug {
 		log.Println("[DEBUG] update version from " + currentVersion + " to AWSCURRENT")
 	}
-	_, err = cfg.SecretsmanagerClient.UpdateSecretVersionStage(
+	if _, err = cfg.SecretsmanagerClient.UpdateSecretVersionStage(
 		ctx, &secretsmanager.UpdateSecretVersionStageInput{
 			SecretId:            aws.String(event.SecretARN),
 			VersionStage:        aws.String("AWSCURRENT"),
 			MoveToVersionId:     aws.String(event.Token),
 			RemoveFromVersionId: aws.String(currentVersion),
 		},
-	)
-	return err
+	); err != nil {
+		if cfg.Debug {
+			log.Println("[DEBUG] error: " + err.Error())
+		}
+		return err
+	}
+
+	return finalize(ctx, event, cfg)
+}
+
+// finalize calls the `ServiceClientFinalizer` if the `ServiceClient` implements it.
+func finalize(ctx context.Context, event secretsmanagerTriggerPayload, cfg Config) error {
+	f, ok := cfg.ServiceClient.(ServiceClientFinalizer)
+	if !ok {
+		return nil
+	}
+
+	if cfg.Debug {
+		log.Println("[DEBUG] Fetch AWSCURRENT of the secret: " + event.SecretARN)
+	}
+	secretCurrent, err := getSecretValue(ctx, cfg.SecretsmanagerClient, event.SecretARN, "AWSCURRENT", "")
+	if err != nil {
+		if cfg.Debug {
+			log.Println("[DEBUG] error: " + err.Error())
+		}
+		return err
+	}
+
+	current := initNewSecretObj(cfg.SecretObj)
+	if err := ExtractSecretObject(secretCurrent, current); err != nil {
+		return err
+	}
+
+	if cfg.Debug {
+		log.Println("[DEBUG] Fetch AWSPREVIOUS of the secret: " + event.SecretARN)
+	}
+	secretPrevious, err := getSecretPrevious(ctx, cfg.SecretsmanagerClient, event.SecretARN)
+	if err != nil {
+		if cfg.Debug {
+			log.Println("[DEBUG] error: " + err.Error())
+		}
+		return err
+	}
+
+	var previous any
+	if secretPrevious != nil {
+		previous = initNewSecretObj(cfg.SecretObj)
+		if err := ExtractSecretObject(secretPrevious, previous); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Debug {
+		log.Println("[DEBUG] call cfg.ServiceClient.Finalize()")
+	}
+	return f.Finalize(ctx, current, previous)
 }
 
 // StrToBool converts string to bool.
@@ -454,6 +632,35 @@ func StrToBool(s string) bool {
 	}
 }
 
+// passwordCharset defines the characters of the password generated by GeneratePassword.
+const passwordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// GeneratePassword generates the random alphanumeric password of the given length using crypto/rand.
+// The random bytes which would skew the distribution of the characters are discarded.
+func GeneratePassword(length int) (string, error) {
+	if length <= 0 {
+		return "", errors.New("password length must be positive")
+	}
+
+	// the largest multiple of the charset's length which fits in a byte
+	const limit = 256 - 256%len(passwordCharset)
+
+	o := make([]byte, 0, length)
+	b := make([]byte, length)
+	for len(o) < length {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, v := range b {
+			if int(v) >= limit || len(o) == length {
+				continue
+			}
+			o = append(o, passwordCharset[int(v)%len(passwordCharset)])
+		}
+	}
+	return string(o), nil
+}
+
 // ExtractSecretObject deserializes secret value to a Go object of the secret type.
 func ExtractSecretObject(v *secretsmanager.GetSecretValueOutput, secret any) error {
 	return json.Unmarshal([]byte(*v.SecretString), secret)
@@ -467,6 +674,31 @@ func serialiseSecret(secret any) (*string, error) {
 	return (*string)(unsafe.Pointer(&o)), nil
 }
 
+// getSecretPrevious fetches the secret's version staged as AWSPREVIOUS, it returns nil if the version does not exist.
+func getSecretPrevious(
+	ctx context.Context, client SecretsmanagerClient, secretARN string,
+) (*secretsmanager.GetSecretValueOutput, error) {
+	v, err := getSecretValue(ctx, client, secretARN, "AWSPREVIOUS", "")
+	if err == nil {
+		return v, nil
+	}
+
+	var errNotFound *types.ResourceNotFoundException
+	if errors.As(err, &errNotFound) {
+		return nil, nil
+	}
+
+	var errResponse *smithyHttp.ResponseError
+	if errors.As(err, &errResponse) {
+		switch errResponse.HTTPStatusCode() {
+		case http.StatusBadRequest, http.StatusNotFound:
+			return nil, nil
+		}
+	}
+
+	return nil, err
+}
+
 func getSecretValue(
 	ctx context.Context, client SecretsmanagerClient, secretARN, stage, version string,
 ) (*secretsmanager.GetSecretValueOutput, error) {
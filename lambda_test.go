@@ -81,6 +81,10 @@ type mockSecretsmanagerClient struct {
 	secretByID map[string]map[string]string
 
 	rotationEnabled *bool
+
+	// putSecretValueErr the error returned by PutSecretValue, the version is stored nonetheless if putSecretValueStored
+	putSecretValueErr    error
+	putSecretValueStored bool
 }
 
 func getSecret(m *mockSecretsmanagerClient, stage, version string) mockObj {
@@ -219,6 +223,10 @@ func (m *mockSecretsmanagerClient) GetSecretValue(
 func (m *mockSecretsmanagerClient) PutSecretValue(
 	ctx context.Context, input *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options),
 ) (*secretsmanager.PutSecretValueOutput, error) {
+	if m.putSecretValueErr != nil && !m.putSecretValueStored {
+		return nil, m.putSecretValueErr
+	}
+
 	versionID := *input.ClientRequestToken
 	stage := input.VersionStages[0]
 
@@ -232,7 +240,7 @@ func (m *mockSecretsmanagerClient) PutSecretValue(
 
 	m.secretByID[versionID][stage] = *input.SecretString
 
-	return nil, nil
+	return nil, m.putSecretValueErr
 }
 
 func (m *mockSecretsmanagerClient) DescribeSecret(
@@ -304,6 +312,7 @@ var (
 
 type mockDBClient struct {
 	current, pending, previous any
+	event                      RotationEvent
 }
 
 func (m *mockDBClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
@@ -314,6 +323,7 @@ func (m *mockDBClient) Set(ctx context.Context, secretCurrent, secretPending, se
 }
 
 func (m *mockDBClient) Test(ctx context.Context, secret any) error {
+	m.event, _ = EventFromContext(ctx)
 	return nil
 }
 
@@ -322,6 +332,29 @@ func (m *mockDBClient) Create(ctx context.Context, secret any) error {
 	return nil
 }
 
+type mockDBClientFinalizer struct {
+	mockDBClient
+	finalizeErr error
+	finalized   bool
+}
+
+func (m *mockDBClientFinalizer) Finalize(ctx context.Context, secretCurrent, secretPrevious any) error {
+	m.current = secretCurrent
+	m.previous = secretPrevious
+	m.finalized = true
+	return m.finalizeErr
+}
+
+type mockDBClientDiscarder struct {
+	mockDBClient
+	discarded any
+}
+
+func (m *mockDBClientDiscarder) Discard(ctx context.Context, secret any) error {
+	m.discarded = secret
+	return nil
+}
+
 func Test_createSecret(t *testing.T) {
 	type args struct {
 		ctx   context.Context
@@ -329,9 +362,10 @@ func Test_createSecret(t *testing.T) {
 		cfg   Config
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name          string
+		args          args
+		wantErr       bool
+		wantDiscarded bool
 	}{
 		{
 			name: "happy path",
@@ -384,6 +418,51 @@ func Test_createSecret(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "unhappy path: failed to store the new secret, secret discarded",
+			args: args{
+				ctx: context.TODO(),
+				event: secretsmanagerTriggerPayload{
+					SecretARN: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+					Token:     "bar",
+					Step:      "createSecret",
+				},
+				cfg: Config{
+					SecretsmanagerClient: &mockSecretsmanagerClient{
+						secretAWSCurrent:  placeholderSecretUserStr,
+						secretByID:        map[string]map[string]string{"foo": {"AWSCURRENT": placeholderSecretUserStr}},
+						putSecretValueErr: errors.New("throttled"),
+					},
+					ServiceClient: &mockDBClientDiscarder{},
+					SecretObj:     &mockObj{},
+				},
+			},
+			wantErr:       true,
+			wantDiscarded: true,
+		},
+		{
+			name: "unhappy path: new secret stored despite the error, secret kept",
+			args: args{
+				ctx: context.TODO(),
+				event: secretsmanagerTriggerPayload{
+					SecretARN: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+					Token:     "bar",
+					Step:      "createSecret",
+				},
+				cfg: Config{
+					SecretsmanagerClient: &mockSecretsmanagerClient{
+						secretAWSCurrent:     placeholderSecretUserStr,
+						secretByID:           map[string]map[string]string{"foo": {"AWSCURRENT": placeholderSecretUserStr}},
+						putSecretValueErr:    errors.New("timeout"),
+						putSecretValueStored: true,
+					},
+					ServiceClient: &mockDBClientDiscarder{},
+					SecretObj:     &mockObj{},
+				},
+			},
+			wantErr:       true,
+			wantDiscarded: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
@@ -392,6 +471,11 @@ func Test_createSecret(t *testing.T) {
 					t.Errorf("createSecret() error = %v, wantErr %v", err, tt.wantErr)
 				}
 
+				if m, ok := tt.args.cfg.ServiceClient.(*mockDBClientDiscarder); ok &&
+					(m.discarded == tt.args.cfg.SecretObj) != tt.wantDiscarded {
+					t.Errorf("createSecret() discarded = %v, want %v", m.discarded != nil, tt.wantDiscarded)
+				}
+
 				if !tt.wantErr {
 					secretInitial := placeholderSecretUser
 					passwordInitial := secretInitial.Password
@@ -513,6 +597,88 @@ func Test_finishSecret(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "happy path: finalizer",
+			args: args{
+				ctx: context.TODO(),
+				event: secretsmanagerTriggerPayload{
+					SecretARN: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+					Token:     "bar",
+					Step:      "finishSecret",
+				},
+				cfg: Config{
+					SecretsmanagerClient: &mockSecretsmanagerClient{
+						secretAWSCurrent:  placeholderSecretUserStr,
+						secretAWSPrevious: placeholderSecretUserStr,
+						secretByID: map[string]map[string]string{
+							"foo": {
+								"AWSCURRENT": placeholderSecretUserStr,
+							},
+							"bar": {
+								"AWSPENDING": placeholderSecretUserNewStr,
+							},
+						},
+					},
+					ServiceClient: &mockDBClientFinalizer{},
+					SecretObj:     &mockObj{},
+					Debug:         true,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "happy path: finalizer, already set",
+			args: args{
+				ctx: context.TODO(),
+				event: secretsmanagerTriggerPayload{
+					SecretARN: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+					Token:     "bar",
+					Step:      "finishSecret",
+				},
+				cfg: Config{
+					SecretsmanagerClient: &mockSecretsmanagerClient{
+						secretAWSCurrent: placeholderSecretUserNewStr,
+						secretByID: map[string]map[string]string{
+							"bar": {
+								"AWSCURRENT": placeholderSecretUserNewStr,
+							},
+						},
+					},
+					ServiceClient: &mockDBClientFinalizer{},
+					SecretObj:     &mockObj{},
+					Debug:         true,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unhappy path: finalizer failed",
+			args: args{
+				ctx: context.TODO(),
+				event: secretsmanagerTriggerPayload{
+					SecretARN: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+					Token:     "bar",
+					Step:      "finishSecret",
+				},
+				cfg: Config{
+					SecretsmanagerClient: &mockSecretsmanagerClient{
+						secretAWSCurrent: placeholderSecretUserStr,
+						secretByID: map[string]map[string]string{
+							"foo": {
+								"AWSCURRENT": placeholderSecretUserStr,
+							},
+							"bar": {
+								"AWSPENDING": placeholderSecretUserNewStr,
+							},
+						},
+					},
+					ServiceClient: &mockDBClientFinalizer{finalizeErr: errors.New("foo")},
+					SecretObj:     &mockObj{},
+					Debug:         true,
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
@@ -537,6 +703,18 @@ func Test_finishSecret(t *testing.T) {
 						placeholderSecretUserNewStr {
 						t.Errorf("finishSecret() result does not match expectation")
 					}
+
+					if m, ok := tt.args.cfg.ServiceClient.(*mockDBClientFinalizer); ok {
+						if !m.finalized {
+							t.Errorf("finishSecret() did not call Finalize()")
+						}
+						if !reflect.DeepEqual(m.current, &placeholderSecretUserNew) {
+							t.Errorf("finishSecret() current secret is not propagated right")
+						}
+						if m.previous == nil && tt.args.cfg.SecretsmanagerClient.(*mockSecretsmanagerClient).secretAWSPrevious != "" {
+							t.Errorf("finishSecret() previous secret is not propagated right")
+						}
+					}
 				}
 			},
 		)
@@ -553,11 +731,12 @@ func Test_setSecret(t *testing.T) {
 		cfg   Config
 	}
 	tests := []struct {
-		name                string
-		args                args
-		wantErr             bool
-		wantExpectedCurrent any
-		wantExpectedPending any
+		name                 string
+		args                 args
+		wantErr              bool
+		wantExpectedCurrent  any
+		wantExpectedPending  any
+		wantExpectedPrevious any
 	}{
 		{
 			name: "happy path",
@@ -647,7 +826,10 @@ func Test_setSecret(t *testing.T) {
 					Debug:         true,
 				},
 			},
-			wantErr: false,
+			wantErr:              false,
+			wantExpectedCurrent:  &placeholderSecretUser,
+			wantExpectedPending:  &placeholderSecretUserNew,
+			wantExpectedPrevious: &placeholderSecretUser,
 		},
 		{
 			name: "happy path: no AWSCURRENT version",
@@ -711,6 +893,9 @@ func Test_setSecret(t *testing.T) {
 							t.Errorf("setSecret() pending secret is not propagated right")
 						}
 					}
+					if !reflect.DeepEqual(m.previous, tt.wantExpectedPrevious) {
+						t.Errorf("setSecret() previous secret is not propagated right")
+					}
 				}
 			},
 		)
@@ -1217,3 +1402,152 @@ func TestNewHandler(t *testing.T) {
 		)
 	}
 }
+
+func TestNewHandler_rotationEvent(t *testing.T) {
+	client := &mockDBClient{}
+	handler, err := NewHandler(
+		Config{
+			SecretsmanagerClient: &mockSecretsmanagerClient{
+				secretAWSCurrent: placeholderSecretUserStr,
+				secretByID: map[string]map[string]string{
+					"foo": {
+						"AWSCURRENT": placeholderSecretUserStr,
+						"AWSPENDING": placeholderSecretUserNewStr,
+					},
+				},
+				rotationEnabled: aws.Bool(true),
+			},
+			ServiceClient: client,
+			SecretObj:     &map[string]string{},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := RotationEvent{
+		SecretARN:          "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+		ClientRequestToken: "foo",
+		Step:               "testSecret",
+	}
+	if err := handler(
+		context.TODO(), secretsmanagerTriggerPayload{
+			SecretARN: want.SecretARN,
+			Token:     want.ClientRequestToken,
+			Step:      want.Step,
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(client.event, want) {
+		t.Errorf("EventFromContext() = %v, want %v", client.event, want)
+	}
+
+	if _, ok := EventFromContext(context.TODO()); ok {
+		t.Errorf("EventFromContext() shall return false for the context without the event")
+	}
+}
+
+func TestNewServiceClientPerInvocation(t *testing.T) {
+	var calls int
+	client := &mockDBClientFinalizer{}
+	c := NewServiceClientPerInvocation(
+		func(ctx context.Context) (ServiceClient, error) {
+			calls++
+			return client, nil
+		},
+	)
+
+	if err := c.Set(context.TODO(), "current", "pending", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Test(context.TODO(), "pending"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(ServiceClientFinalizer).Finalize(context.TODO(), "pending", "current"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(ServiceClientDiscarder).Discard(context.TODO(), "pending"); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 4 {
+		t.Errorf("factory calls = %d, want 4", calls)
+	}
+	if !client.finalized || client.current != "pending" || client.previous != "current" {
+		t.Errorf("Finalize() shall be called on the initiated client")
+	}
+
+	t.Run(
+		"unhappy path: factory error", func(t *testing.T) {
+			c := NewServiceClientPerInvocation(
+				func(ctx context.Context) (ServiceClient, error) {
+					return nil, errors.New("foo")
+				},
+			)
+			if err := c.Create(context.TODO(), &mockObj{}); err == nil || err.Error() != "foo" {
+				t.Errorf("Create() error = %v, want foo", err)
+			}
+		},
+	)
+
+	t.Run(
+		"happy path: client without finalizer", func(t *testing.T) {
+			c := NewServiceClientPerInvocation(
+				func(ctx context.Context) (ServiceClient, error) {
+					return &mockDBClient{}, nil
+				},
+			)
+			if err := c.(ServiceClientFinalizer).Finalize(context.TODO(), "pending", "current"); err != nil {
+				t.Errorf("Finalize() unexpected error = %v", err)
+			}
+		},
+	)
+}
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			length:  32,
+			wantErr: false,
+		},
+		{
+			name:    "happy path: long password",
+			length:  1024,
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: zero length",
+			length:  0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := GeneratePassword(tt.length)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("GeneratePassword() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+				if len(got) != tt.length {
+					t.Errorf("GeneratePassword() length = %d, want %d", len(got), tt.length)
+				}
+				if strings.Trim(got, passwordCharset) != "" {
+					t.Errorf("GeneratePassword() = %s, want alphanumeric characters only", got)
+				}
+				if other, _ := GeneratePassword(tt.length); other == got {
+					t.Errorf("GeneratePassword() generated the same password twice")
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,123 @@
+package confluent
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+// DefaultBaseURL the Confluent Cloud API endpoint.
+const DefaultBaseURL = "https://api.confluent.cloud"
+
+// DefaultAPITimeout the default timeout of the Confluent Cloud API requests.
+const DefaultAPITimeout = 30 * time.Second
+
+// Version the plugin's version reported in the user agent.
+// It can be set at link time: -ldflags="-X github.com/kislerdm/aws-lambda-secret-rotation/plugin/confluent.Version=vX.Y.Z".
+var Version = "v0.2.0"
+
+// UserAgent returns the user agent of the Confluent Cloud API client.
+func UserAgent() string {
+	return "aws-lambda-secret-rotation-confluent/" + Version + " (+https://github.com/kislerdm/aws-lambda-secret-rotation)"
+}
+
+// APIClientConfig defines the configuration of the Confluent Cloud API client.
+type APIClientConfig struct {
+	// BaseURL the API endpoint, DefaultBaseURL by default.
+	BaseURL string
+
+	// UserAgent the user agent, see UserAgent for the default value.
+	UserAgent string
+
+	// Timeout the timeout of the API requests, DefaultAPITimeout by default.
+	// It is ignored if HTTPClient is set.
+	Timeout time.Duration
+
+	// HTTPClient the http client to send the API requests.
+	// Its transport is wrapped to retry the failed requests, see RetryPolicy.
+	HTTPClient *http.Client
+
+	// RetryPolicy the policy to retry the failed API requests, DefaultRetryPolicy by default.
+	RetryPolicy *RetryPolicy
+}
+
+// APIClientConfigFromEnv reads the configuration of the Confluent Cloud API client from the env. variables:
+// CONFLUENT_BASE_URL 	<- BaseURL
+// CONFLUENT_USER_AGENT <- UserAgent
+// CONFLUENT_TIMEOUT 	<- Timeout, e.g. "10s", see https://pkg.go.dev/time#ParseDuration
+// CONFLUENT_MAX_RETRIES <- RetryPolicy.MaxRetries, "0" disables retries
+func APIClientConfigFromEnv() (APIClientConfig, error) {
+	cfg := APIClientConfig{
+		BaseURL:   os.Getenv("CONFLUENT_BASE_URL"),
+		UserAgent: os.Getenv("CONFLUENT_USER_AGENT"),
+	}
+
+	if v := os.Getenv("CONFLUENT_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, errors.New("unable to parse CONFLUENT_TIMEOUT, " + err.Error())
+		}
+		cfg.Timeout = timeout
+	}
+
+	if v := os.Getenv("CONFLUENT_MAX_RETRIES"); v != "" {
+		maxRetries, err := strconv.Atoi(v)
+		if err != nil || maxRetries < 0 {
+			return cfg, errors.New(`wrong CONFLUENT_MAX_RETRIES value "` + v + `"`)
+		}
+		p := DefaultRetryPolicy()
+		p.MaxRetries = maxRetries
+		cfg.RetryPolicy = &p
+	}
+
+	return cfg, nil
+}
+
+// NewAPIClient initiates the Confluent Cloud API client.
+func NewAPIClient(cfg APIClientConfig) (*sdk.APIClient, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.New(`wrong base URL "` + cfg.BaseURL + `"`)
+	}
+
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = UserAgent()
+	}
+
+	var httpClient http.Client
+	if cfg.HTTPClient != nil {
+		httpClient = *cfg.HTTPClient
+	} else {
+		httpClient.Timeout = cfg.Timeout
+		if httpClient.Timeout == 0 {
+			httpClient.Timeout = DefaultAPITimeout
+		}
+	}
+
+	retry := DefaultRetryPolicy()
+	if cfg.RetryPolicy != nil {
+		retry = *cfg.RetryPolicy
+	}
+	if retry.MaxRetries > 0 {
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = retryTransport{next: next, policy: retry}
+	}
+
+	c := sdk.NewConfiguration()
+	c.Servers[0].URL = cfg.BaseURL
+	c.UserAgent = cfg.UserAgent
+	c.HTTPClient = &httpClient
+
+	return sdk.NewAPIClient(c), nil
+}
@@ -0,0 +1,126 @@
+package confluent
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewAPIClient(t *testing.T) {
+	transport := &http.Transport{}
+	httpClient := &http.Client{Transport: transport, Timeout: 3 * time.Second}
+	noRetries := RetryPolicy{}
+
+	tests := []struct {
+		name          string
+		cfg           APIClientConfig
+		wantURL       string
+		wantUserAgent string
+		wantTimeout   time.Duration
+		wantTransport http.RoundTripper
+		wantErr       bool
+	}{
+		{
+			name:          "happy path: defaults",
+			cfg:           APIClientConfig{},
+			wantURL:       DefaultBaseURL,
+			wantUserAgent: UserAgent(),
+			wantTimeout:   DefaultAPITimeout,
+			wantTransport: retryTransport{next: http.DefaultTransport, policy: DefaultRetryPolicy()},
+			wantErr:       false,
+		},
+		{
+			name: "happy path: custom configuration",
+			cfg: APIClientConfig{
+				BaseURL: "http://localhost:8080", UserAgent: "foo", Timeout: time.Second,
+			},
+			wantURL:       "http://localhost:8080",
+			wantUserAgent: "foo",
+			wantTimeout:   time.Second,
+			wantTransport: retryTransport{next: http.DefaultTransport, policy: DefaultRetryPolicy()},
+			wantErr:       false,
+		},
+		{
+			name:          "happy path: custom http client",
+			cfg:           APIClientConfig{HTTPClient: httpClient, Timeout: time.Second},
+			wantURL:       DefaultBaseURL,
+			wantUserAgent: UserAgent(),
+			wantTimeout:   3 * time.Second,
+			wantTransport: retryTransport{next: transport, policy: DefaultRetryPolicy()},
+			wantErr:       false,
+		},
+		{
+			name:          "happy path: retries disabled",
+			cfg:           APIClientConfig{HTTPClient: httpClient, RetryPolicy: &noRetries},
+			wantURL:       DefaultBaseURL,
+			wantUserAgent: UserAgent(),
+			wantTimeout:   3 * time.Second,
+			wantTransport: transport,
+			wantErr:       false,
+		},
+		{
+			name:    "unhappy path: wrong base URL",
+			cfg:     APIClientConfig{BaseURL: "localhost"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := NewAPIClient(tt.cfg)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("NewAPIClient() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				cfg := got.GetConfig()
+				if cfg.Servers[0].URL != tt.wantURL {
+					t.Errorf("NewAPIClient() URL = %v, want %v", cfg.Servers[0].URL, tt.wantURL)
+				}
+				if cfg.UserAgent != tt.wantUserAgent {
+					t.Errorf("NewAPIClient() UserAgent = %v, want %v", cfg.UserAgent, tt.wantUserAgent)
+				}
+				if cfg.HTTPClient.Timeout != tt.wantTimeout {
+					t.Errorf("NewAPIClient() timeout = %v, want %v", cfg.HTTPClient.Timeout, tt.wantTimeout)
+				}
+				if !reflect.DeepEqual(cfg.HTTPClient.Transport, tt.wantTransport) {
+					t.Errorf("NewAPIClient() transport = %v, want %v", cfg.HTTPClient.Transport, tt.wantTransport)
+				}
+			},
+		)
+	}
+}
+
+func TestAPIClientConfigFromEnv(t *testing.T) {
+	t.Setenv("CONFLUENT_BASE_URL", "http://localhost:8080")
+	t.Setenv("CONFLUENT_USER_AGENT", "foo")
+	t.Setenv("CONFLUENT_TIMEOUT", "10s")
+	t.Setenv("CONFLUENT_MAX_RETRIES", "2")
+
+	got, err := APIClientConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry := DefaultRetryPolicy()
+	retry.MaxRetries = 2
+	want := APIClientConfig{
+		BaseURL: "http://localhost:8080", UserAgent: "foo", Timeout: 10 * time.Second, RetryPolicy: &retry,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("APIClientConfigFromEnv() got = %v, want %v", got, want)
+	}
+
+	t.Setenv("CONFLUENT_MAX_RETRIES", "-1")
+	if _, err := APIClientConfigFromEnv(); err == nil {
+		t.Errorf("APIClientConfigFromEnv() error expected: wrong max retries")
+	}
+
+	t.Setenv("CONFLUENT_MAX_RETRIES", "2")
+	t.Setenv("CONFLUENT_TIMEOUT", "foo")
+	if _, err := APIClientConfigFromEnv(); err == nil {
+		t.Errorf("APIClientConfigFromEnv() error expected: wrong timeout")
+	}
+}
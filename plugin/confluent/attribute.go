@@ -0,0 +1,113 @@
+package confluent
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// parsePath splits the attribute's path to the reference tokens.
+// The path is either the name of the top-level attribute, e.g. "user",
+// or the JSON pointer, e.g. "/kafka/sasl/username", see https://www.rfc-editor.org/rfc/rfc6901.
+func parsePath(path string) []string {
+	if !strings.HasPrefix(path, "/") {
+		return []string{path}
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// get returns the value of the attribute by its path.
+func (s SecretUser) get(path string) (any, bool) {
+	var o any = map[string]any(s)
+	for _, t := range parsePath(path) {
+		switch v := o.(type) {
+		case map[string]any:
+			var ok bool
+			if o, ok = v[t]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(t)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			o = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return o, true
+}
+
+// getString returns the value of the string attribute by its path.
+func (s SecretUser) getString(path string) (string, bool) {
+	v, ok := s.get(path)
+	if !ok {
+		return "", false
+	}
+	o, ok := v.(string)
+	return o, ok
+}
+
+// set sets the value of the attribute by its path, the missing parent objects are created.
+func (s SecretUser) set(path string, value any) error {
+	tokens := parsePath(path)
+
+	var o any = map[string]any(s)
+	for i, t := range tokens {
+		last := i == len(tokens)-1
+		switch v := o.(type) {
+		case map[string]any:
+			if last {
+				v[t] = value
+				return nil
+			}
+			if _, ok := v[t]; !ok {
+				v[t] = map[string]any{}
+			}
+			o = v[t]
+		case []any:
+			idx, err := strconv.Atoi(t)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return errors.New(`attribute "` + path + `" cannot be set: index "` + t + `" is out of range`)
+			}
+			if last {
+				v[idx] = value
+				return nil
+			}
+			o = v[idx]
+		default:
+			return errors.New(`attribute "` + path + `" cannot be set: "` + t + `" parent is not an object`)
+		}
+	}
+	return errors.New(`attribute "` + path + `" cannot be set: empty path`)
+}
+
+// clone returns the deep copy of the document.
+func (s SecretUser) clone() SecretUser {
+	return cloneValue(map[string]any(s)).(map[string]any)
+}
+
+func cloneValue(v any) any {
+	switch o := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(o))
+		for k, v := range o {
+			c[k] = cloneValue(v)
+		}
+		return c
+	case []any:
+		c := make([]any, len(o))
+		for i, v := range o {
+			c[i] = cloneValue(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
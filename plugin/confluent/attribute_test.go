@@ -0,0 +1,189 @@
+package confluent
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSecretUser_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    SecretUser
+		wantErr bool
+	}{
+		{
+			name: "happy path",
+			data: `{"user":"foo","password":"bar","kafka":{"port":9092,"tls":true,"brokers":["a:9092","b:9092"]}}`,
+			want: SecretUser{
+				"user":     "foo",
+				"password": "bar",
+				"kafka": map[string]any{
+					"port": json.Number("9092"), "tls": true, "brokers": []any{"a:9092", "b:9092"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: not an object",
+			data:    `["foo"]`,
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: null",
+			data:    `null`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var got SecretUser
+				if err := json.Unmarshal([]byte(tt.data), &got); (err != nil) != tt.wantErr {
+					t.Errorf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("UnmarshalJSON() got = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestSecretUser_Strings(t *testing.T) {
+	s := SecretUser{"user": "foo", "password": "bar", "port": json.Number("9092"), "kafka": map[string]any{"tls": true}}
+	want := map[string]string{"user": "foo", "password": "bar"}
+
+	got := s.Strings()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Strings() got = %v, want %v", got, want)
+	}
+	if got := NewSecretUserFromStrings(got); !reflect.DeepEqual(got, SecretUser{"user": "foo", "password": "bar"}) {
+		t.Errorf("NewSecretUserFromStrings() got = %v", got)
+	}
+}
+
+func TestSecretUser_get(t *testing.T) {
+	s := SecretUser{
+		"user": "foo",
+		"a/b":  "slash",
+		"m~n":  "tilde",
+		"kafka": map[string]any{
+			"sasl":    map[string]any{"username": "bar"},
+			"brokers": []any{"a:9092", "b:9092"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		want   any
+		wantOk bool
+	}{
+		{name: "happy path: top-level attribute", path: "user", want: "foo", wantOk: true},
+		{name: "happy path: top-level pointer", path: "/user", want: "foo", wantOk: true},
+		{name: "happy path: nested attribute", path: "/kafka/sasl/username", want: "bar", wantOk: true},
+		{name: "happy path: array element", path: "/kafka/brokers/1", want: "b:9092", wantOk: true},
+		{name: "happy path: escaped slash", path: "/a~1b", want: "slash", wantOk: true},
+		{name: "happy path: escaped tilde", path: "/m~0n", want: "tilde", wantOk: true},
+		{name: "unhappy path: attribute not found", path: "/kafka/sasl/password", wantOk: false},
+		{name: "unhappy path: index out of range", path: "/kafka/brokers/2", wantOk: false},
+		{name: "unhappy path: parent is not an object", path: "/user/foo", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok := s.get(tt.path)
+				if ok != tt.wantOk {
+					t.Errorf("get() ok = %v, want %v", ok, tt.wantOk)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("get() got = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestSecretUser_set(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       SecretUser
+		path    string
+		want    SecretUser
+		wantErr bool
+	}{
+		{
+			name:    "happy path: top-level attribute",
+			s:       SecretUser{"user": "foo", "port": json.Number("9092")},
+			path:    "user",
+			want:    SecretUser{"user": "qux", "port": json.Number("9092")},
+			wantErr: false,
+		},
+		{
+			name: "happy path: nested attribute",
+			s:    SecretUser{"kafka": map[string]any{"sasl": map[string]any{"username": "foo"}, "tls": true}},
+			path: "/kafka/sasl/username",
+			want: SecretUser{
+				"kafka": map[string]any{"sasl": map[string]any{"username": "qux"}, "tls": true},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "happy path: missing parent is created",
+			s:       SecretUser{},
+			path:    "/kafka/sasl/username",
+			want:    SecretUser{"kafka": map[string]any{"sasl": map[string]any{"username": "qux"}}},
+			wantErr: false,
+		},
+		{
+			name:    "happy path: array element",
+			s:       SecretUser{"keys": []any{"foo", "bar"}},
+			path:    "/keys/1",
+			want:    SecretUser{"keys": []any{"foo", "qux"}},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: index out of range",
+			s:       SecretUser{"keys": []any{"foo"}},
+			path:    "/keys/1",
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: parent is not an object",
+			s:       SecretUser{"user": "foo"},
+			path:    "/user/name",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if err := tt.s.set(tt.path, "qux"); (err != nil) != tt.wantErr {
+					t.Errorf("set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(tt.s, tt.want) {
+					t.Errorf("set() got = %v, want %v", tt.s, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestSecretUser_clone(t *testing.T) {
+	s := SecretUser{"kafka": map[string]any{"sasl": map[string]any{"username": "foo"}, "brokers": []any{"a"}}}
+
+	got := s.clone()
+	if err := got.set("/kafka/sasl/username", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.set("/kafka/brokers/0", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := SecretUser{"kafka": map[string]any{"sasl": map[string]any{"username": "foo"}, "brokers": []any{"a"}}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("clone() modified the original document: %v", s)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	confluentClient "github.com/kislerdm/aws-lambda-secret-rotation/plugin/confluent"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretRotation "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+func main() {
+	cfgSecretsManager, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	cfgAPIClient, err := confluentClient.APIClientConfigFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	apiClient, err := confluentClient.NewAPIClient(cfgAPIClient)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	client, err := confluentClient.NewAdminServiceClient(apiClient)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var s confluentClient.SecretAdmin
+	handler, err := secretRotation.NewHandler(
+		secretRotation.Config{
+			SecretsmanagerClient: secretsmanager.NewFromConfig(cfgSecretsManager),
+			ServiceClient:        client,
+			SecretObj:            &s,
+			Debug:                secretRotation.StrToBool(os.Getenv("DEBUG")),
+		},
+	)
+	if err != nil {
+		log.Fatalf("unable to init lambda handler to rotate secret, %v", err)
+	}
+
+	lambda.Start(handler)
+}
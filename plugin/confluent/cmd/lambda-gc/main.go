@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	confluentClient "github.com/kislerdm/aws-lambda-secret-rotation/plugin/confluent"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretRotation "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+func main() {
+	secretAdminARN := os.Getenv("ADMIN_SECRET_ARN")
+	if secretAdminARN == "" {
+		log.Fatalln("ADMIN_SECRET_ARN env. variable must be set")
+	}
+
+	var secretARNs []string
+	for _, v := range strings.Split(os.Getenv("SECRET_ARNS"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			secretARNs = append(secretARNs, v)
+		}
+	}
+	if len(secretARNs) == 0 {
+		log.Fatalln("SECRET_ARNS env. variable must be set")
+	}
+
+	cfgSecretsManager, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	clientSecretsManager := secretsmanager.NewFromConfig(cfgSecretsManager)
+
+	cfgAPIClient, err := confluentClient.APIClientConfigFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	apiClient, err := confluentClient.NewAPIClient(cfgAPIClient)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var opts []confluentClient.Option
+	if v := os.Getenv("ATTRIBUTE_KEYS"); v != "" {
+		opts = append(opts, confluentClient.WithKeysAttribute(v))
+	}
+
+	var minAge time.Duration
+	if v := os.Getenv("MIN_AGE"); v != "" {
+		if minAge, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("unable to parse MIN_AGE, %v", err)
+		}
+	}
+
+	dryRun := secretRotation.StrToBool(os.Getenv("DRY_RUN"))
+
+	// the admin API key is read upon every invocation because it can be rotated by the lambda-admin
+	newGarbageCollector := func(ctx context.Context) (*confluentClient.GarbageCollector, error) {
+		v, err := clientSecretsManager.GetSecretValue(
+			ctx, &secretsmanager.GetSecretValueInput{SecretId: &secretAdminARN},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		var adminSecret confluentClient.SecretAdmin
+		if err := secretRotation.ExtractSecretObject(v, &adminSecret); err != nil {
+			return nil, err
+		}
+
+		client, err := confluentClient.NewServiceClient(
+			apiClient,
+			adminSecret.APIKey, adminSecret.APISecret,
+			os.Getenv("ATTRIBUTE_KEY"), os.Getenv("ATTRIBUTE_SECRET"),
+			opts...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return confluentClient.NewGarbageCollector(
+			confluentClient.GarbageCollectorConfig{
+				SecretsmanagerClient: clientSecretsManager,
+				ServiceClient:        client,
+				DryRun:               dryRun,
+				MinAge:               minAge,
+			},
+		)
+	}
+
+	lambda.Start(
+		func(ctx context.Context) error {
+			gc, err := newGarbageCollector(ctx)
+			if err != nil {
+				return errors.New("unable to init garbage collector, " + err.Error())
+			}
+
+			var failed bool
+			for _, arn := range secretARNs {
+				orphans, err := gc.Collect(ctx, arn)
+				if err != nil {
+					failed = true
+					log.Printf("[ERROR] %s: %v\n", arn, err)
+					continue
+				}
+				log.Printf("[INFO] %s: %d orphaned API keys found\n", arn, len(orphans))
+			}
+			if failed {
+				return errors.New("garbage collection failed")
+			}
+			return nil
+		},
+	)
+}
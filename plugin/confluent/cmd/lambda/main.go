@@ -4,27 +4,17 @@ import (
 	"context"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	confluentClient "github.com/kislerdm/aws-lambda-secret-rotation/plugin/confluent"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
 	secretRotation "github.com/kislerdm/aws-lambda-secret-rotation"
 )
 
-// userAgent mimics terraform UserAgent.
-func userAgent() string {
-	// terraform sdk version
-	// https://pkg.go.dev/github.com/hashicorp/terraform-plugin-sdk/v2/meta@v2.24.1#SDKVersion
-	const (
-		terraformSDKVersion = "2.10.1"
-		terraformVersion    = "v1.3.3"
-	)
-	return "Terraform/" + terraformVersion + " (+https://www.terraform.io) Terraform-Plugin-SDK/" + terraformSDKVersion
-}
-
 func main() {
 	secretAdminARN := os.Getenv("ADMIN_SECRET_ARN")
 	if secretAdminARN == "" {
@@ -38,36 +28,93 @@ func main() {
 
 	clientSecretsManager := secretsmanager.NewFromConfig(cfgSecretsManager)
 
-	v, err := clientSecretsManager.GetSecretValue(
-		context.Background(), &secretsmanager.GetSecretValueInput{SecretId: &secretAdminARN},
-	)
+	cfgAPIClient, err := confluentClient.APIClientConfigFromEnv()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	var adminSecret confluentClient.SecretAdmin
-	if err := secretRotation.ExtractSecretObject(v, &adminSecret); err != nil {
+	apiClient, err := confluentClient.NewAPIClient(cfgAPIClient)
+	if err != nil {
 		log.Fatalln(err)
 	}
 
-	cfg := sdk.NewConfiguration()
-	cfg.Servers[0].URL = "https://api.confluent.cloud"
-	cfg.UserAgent = userAgent()
+	var opts []confluentClient.Option
+	if v := os.Getenv("GRACE_PERIOD"); v != "" {
+		gracePeriod, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("unable to parse GRACE_PERIOD, %v", err)
+		}
+		opts = append(opts, confluentClient.WithGracePeriod(gracePeriod))
+	}
 
-	client, err := confluentClient.NewServiceClient(
-		sdk.NewAPIClient(cfg),
-		adminSecret.APIKey, adminSecret.APISecret,
-		os.Getenv("ATTRIBUTE_KEY"), os.Getenv("ATTRIBUTE_SECRET"),
-	)
-	if err != nil {
-		log.Fatalln(err)
+	if v := os.Getenv("ATTRIBUTE_KEYS"); v != "" {
+		opts = append(opts, confluentClient.WithKeysAttribute(v))
+	}
+	if v := os.Getenv("ATTRIBUTE_METADATA"); v != "" {
+		opts = append(opts, confluentClient.WithMetadataAttribute(v))
+	}
+	if v := os.Getenv("ATTRIBUTE_BOOTSTRAP_SERVERS"); v != "" {
+		opts = append(opts, confluentClient.WithBootstrapServersAttribute(v))
+	}
+	if v := os.Getenv("ATTRIBUTE_SCHEMA_REGISTRY_URL"); v != "" {
+		opts = append(opts, confluentClient.WithSchemaRegistryURLAttribute(v))
+	}
+	if v := os.Getenv("DISPLAY_NAME_TEMPLATE"); v != "" {
+		opts = append(opts, confluentClient.WithDisplayNameTemplate(v))
+	}
+	if v := os.Getenv("DESCRIPTION_TEMPLATE"); v != "" {
+		opts = append(opts, confluentClient.WithDescriptionTemplate(v))
+	}
+
+	if v := os.Getenv("ATTRIBUTE_REST_ENDPOINT"); v != "" {
+		opts = append(opts, confluentClient.WithRESTEndpointAttribute(v))
+	}
+	if secretRotation.StrToBool(os.Getenv("COMPARE_PERMISSIONS")) {
+		opts = append(opts, confluentClient.WithPermissionsComparison(os.Getenv("ROLE_BINDINGS_CRN_PATTERN")))
+	}
+	if v := os.Getenv("PERMISSION_PROBES"); v != "" {
+		var probes []confluentClient.PermissionProbe
+		for _, s := range strings.Split(v, ",") {
+			p, err := confluentClient.ParsePermissionProbe(s)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			probes = append(probes, p)
+		}
+		opts = append(opts, confluentClient.WithPermissionProbes(probes...))
+	}
+
+	if cfgAPIClient.RetryPolicy != nil {
+		opts = append(opts, confluentClient.WithRetryPolicy(*cfgAPIClient.RetryPolicy))
+	}
+
+	// the admin API key is read upon every invocation because it can be rotated by the lambda-admin
+	newServiceClient := func(ctx context.Context) (secretRotation.ServiceClient, error) {
+		v, err := clientSecretsManager.GetSecretValue(
+			ctx, &secretsmanager.GetSecretValueInput{SecretId: &secretAdminARN},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		var adminSecret confluentClient.SecretAdmin
+		if err := secretRotation.ExtractSecretObject(v, &adminSecret); err != nil {
+			return nil, err
+		}
+
+		return confluentClient.NewServiceClient(
+			apiClient,
+			adminSecret.APIKey, adminSecret.APISecret,
+			os.Getenv("ATTRIBUTE_KEY"), os.Getenv("ATTRIBUTE_SECRET"),
+			opts...,
+		)
 	}
 
 	var s confluentClient.SecretUser
 	handler, err := secretRotation.NewHandler(
 		secretRotation.Config{
 			SecretsmanagerClient: clientSecretsManager,
-			ServiceClient:        client,
+			ServiceClient:        secretRotation.NewServiceClientPerInvocation(newServiceClient),
 			SecretObj:            &s,
 			Debug:                secretRotation.StrToBool(os.Getenv("DEBUG")),
 		},
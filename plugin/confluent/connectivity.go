@@ -0,0 +1,359 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+const (
+	resourceKindKafka          = "Cluster"
+	resourceKindSchemaRegistry = "SchemaRegistry"
+	resourceKindCloud          = "Cloud"
+
+	kafkaErrorSaslAuthenticationFailed int16 = 58
+
+	defaultConnectionTimeout = 30 * time.Second
+
+	// kafkaTimeout limits the dial and every request to the broker, hence an unresponsive broker
+	// does not exhaust the deadline of the whole test.
+	kafkaTimeout = 10 * time.Second
+
+	// kafkaMaxFrameSize limits the size of the broker's response, it matches the broker's default
+	// limit of the request size, socket.request.max.bytes.
+	kafkaMaxFrameSize = 100 * 1024 * 1024
+)
+
+// testCloudConnection authenticates with the API key-secret pair to read the key's details using Confluent Cloud API.
+func (c dbClient) testCloudConnection(ctx context.Context, key, secret string) error {
+	return probeKey(ctx, c.c.APIKeysIamV2Api, key, secret)
+}
+
+// probeKey authenticates with the API key-secret pair to read the key's details using Confluent Cloud API,
+// the error is classified given the response status, see probeStatusError.
+func probeKey(ctx context.Context, c sdk.APIKeysIamV2Api, key, secret string) error {
+	_, resp, err := c.GetIamV2ApiKey(withBasicAuth(ctx, key, secret), key).Execute()
+	if err != nil && resp != nil {
+		return probeStatusError(resp.StatusCode, err)
+	}
+	return err
+}
+
+// testSchemaRegistryConnection authenticates with the API key-secret pair to list the Schema Registry subjects.
+func (c dbClient) testSchemaRegistryConnection(ctx context.Context, endpoint, key, secret string) error {
+	if endpoint == "" {
+		return errors.New(`wrong secret type: "` + c.attributeSchemaRegistryURL + `" field not found`)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/subjects", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(key, secret)
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return probeStatusError(
+			resp.StatusCode, errors.New("schema registry responded with the status "+resp.Status),
+		)
+	}
+	return nil
+}
+
+// testKafkaConnection authenticates with the API key-secret pair using SASL/PLAIN
+// and requests the cluster metadata from the first reachable bootstrap server.
+func (c dbClient) testKafkaConnection(ctx context.Context, bootstrapServers, key, secret string) error {
+	if bootstrapServers == "" {
+		return errors.New(`wrong secret type: "` + c.attributeBootstrapServers + `" field not found`)
+	}
+
+	var err error
+	for _, addr := range splitBootstrapServers(bootstrapServers) {
+		if err = kafkaMetadataRequest(ctx, addr, key, secret, c.kafkaTLSConfig); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// splitBootstrapServers splits the comma-separated list of the bootstrap servers, the protocol prefix is trimmed.
+func splitBootstrapServers(bootstrapServers string) []string {
+	o := strings.Split(bootstrapServers, ",")
+	for i, addr := range o {
+		addr = strings.TrimSpace(addr)
+		if j := strings.Index(addr, "://"); j > -1 {
+			addr = addr[j+3:]
+		}
+		o[i] = addr
+	}
+	return o
+}
+
+const (
+	kafkaClientID = "aws-lambda-secret-rotation"
+
+	kafkaAPIKeyMetadata         int16 = 3
+	kafkaAPIKeyFindCoordinator  int16 = 10
+	kafkaAPIKeyDescribeGroups   int16 = 15
+	kafkaAPIKeySaslHandshake    int16 = 17
+	kafkaAPIKeySaslAuthenticate int16 = 36
+)
+
+// kafkaMetadataRequest authenticates and executes the Metadata request.
+func kafkaMetadataRequest(ctx context.Context, addr, user, password string, tlsConfig *tls.Config) error {
+	return withKafkaConn(
+		ctx, addr, user, password, tlsConfig, func(k *kafkaConn) error {
+			var req kafkaEncoder
+			req.putInt32(0)
+			resp, err := k.roundTrip(kafkaAPIKeyMetadata, 1, req.Bytes())
+			if err != nil {
+				return err
+			}
+			if n := resp.int32(); resp.err != nil || n < 1 {
+				return errors.New("kafka Metadata response is corrupt: no brokers found")
+			}
+			return nil
+		},
+	)
+}
+
+// withKafkaConn connects to the broker, executes the SaslHandshake and SaslAuthenticate requests and calls fn.
+// See the protocol definition: https://kafka.apache.org/protocol.html
+func withKafkaConn(
+	ctx context.Context, addr, user, password string, tlsConfig *tls.Config, fn func(k *kafkaConn) error,
+) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultConnectionTimeout)
+		defer cancel()
+	}
+
+	d := net.Dialer{Timeout: kafkaTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	k := &kafkaConn{conn: conn}
+	k.deadline, _ = ctx.Deadline()
+
+	if tlsConfig != nil {
+		cfg := tlsConfig.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, cfg)
+		k.setDeadline()
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		k.conn = tlsConn
+	}
+
+	var req kafkaEncoder
+	req.putString("PLAIN")
+	resp, err := k.roundTrip(kafkaAPIKeySaslHandshake, 1, req.Bytes())
+	if err != nil {
+		return err
+	}
+	if code := resp.int16(); resp.err != nil || code != 0 {
+		return kafkaError("SaslHandshake", code, "")
+	}
+
+	req.Reset()
+	req.putBytes([]byte("\x00" + user + "\x00" + password))
+	resp, err = k.roundTrip(kafkaAPIKeySaslAuthenticate, 0, req.Bytes())
+	if err != nil {
+		return err
+	}
+	if code, msg := resp.int16(), resp.nullableString(); resp.err != nil || code != 0 {
+		if code == kafkaErrorSaslAuthenticationFailed {
+			return fmt.Errorf("%w: %s", errKeyRejected, kafkaError("SaslAuthenticate", code, msg))
+		}
+		return kafkaError("SaslAuthenticate", code, msg)
+	}
+
+	return fn(k)
+}
+
+func kafkaError(request string, code int16, msg string) error {
+	o := "kafka " + request + " failed with the error code " + strconv.Itoa(int(code))
+	if msg != "" {
+		o += ": " + msg
+	}
+	return errors.New(o)
+}
+
+type kafkaConn struct {
+	conn          net.Conn
+	deadline      time.Time
+	correlationID int32
+}
+
+// setDeadline sets the connection's deadline for the next request,
+// it does not exceed the deadline of the context the connection was established with.
+func (k *kafkaConn) setDeadline() {
+	deadline := time.Now().Add(kafkaTimeout)
+	if !k.deadline.IsZero() && k.deadline.Before(deadline) {
+		deadline = k.deadline
+	}
+	_ = k.conn.SetDeadline(deadline)
+}
+
+// roundTrip sends the request with the header v1 and reads the response with the header v0.
+func (k *kafkaConn) roundTrip(apiKey, apiVersion int16, body []byte) (*kafkaDecoder, error) {
+	k.correlationID++
+
+	var req kafkaEncoder
+	req.putInt16(apiKey)
+	req.putInt16(apiVersion)
+	req.putInt32(k.correlationID)
+	req.putString(kafkaClientID)
+	_, _ = req.Write(body)
+
+	frame := make([]byte, 4, 4+req.Len())
+	binary.BigEndian.PutUint32(frame, uint32(req.Len()))
+	k.setDeadline()
+	if _, err := k.conn.Write(append(frame, req.Bytes()...)); err != nil {
+		return nil, err
+	}
+
+	resp, err := readKafkaFrame(k.conn)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.int32(); resp.err != nil || id != k.correlationID {
+		return nil, errors.New("kafka response is corrupt: correlation ID does not match")
+	}
+	return resp, nil
+}
+
+func readKafkaFrame(r io.Reader) (*kafkaDecoder, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, errors.New("kafka frame is corrupt: negative size")
+	}
+	if size > kafkaMaxFrameSize {
+		return nil, errors.New("kafka frame is too large: " + strconv.Itoa(int(size)) + " bytes")
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return &kafkaDecoder{buf: buf}, nil
+}
+
+type kafkaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *kafkaEncoder) putInt16(v int16) {
+	_ = binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) putInt32(v int32) {
+	_ = binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) putString(v string) {
+	e.putInt16(int16(len(v)))
+	_, _ = e.WriteString(v)
+}
+
+func (e *kafkaEncoder) putBool(v bool) {
+	var b byte
+	if v {
+		b = 1
+	}
+	_ = e.WriteByte(b)
+}
+
+func (e *kafkaEncoder) putBytes(v []byte) {
+	e.putInt32(int32(len(v)))
+	_, _ = e.Write(v)
+}
+
+type kafkaDecoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	o := d.buf[d.off : d.off+n]
+	d.off += n
+	return o
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+// array reads the array's length and calls fn for every element.
+func (d *kafkaDecoder) array(fn func()) {
+	n := d.int32()
+	for i := int32(0); i < n && d.err == nil; i++ {
+		fn()
+	}
+}
+
+// bytes skips the nullable bytes.
+func (d *kafkaDecoder) bytes() {
+	if n := d.int32(); n > 0 {
+		d.next(int(n))
+	}
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
@@ -0,0 +1,325 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+// mockKafkaBroker starts the broker which accepts SASL/PLAIN authentication with the user-password pair.
+// The authorizedOps defines the bit fields of the operations authorized to the user per resource,
+// e.g. {"topic foo": 1 << 3}, access to the resources not found in the map is denied.
+func mockKafkaBroker(t *testing.T, user, password string, authorizedOps map[string]int32) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveKafka(conn, l.Addr().(*net.TCPAddr), user, password, authorizedOps)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func serveKafka(conn net.Conn, addr *net.TCPAddr, user, password string, authorizedOps map[string]int32) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	for {
+		req, err := readKafkaFrame(conn)
+		if err != nil {
+			return
+		}
+
+		apiKey, apiVersion, correlationID := req.int16(), req.int16(), req.int32()
+		_ = req.nullableString()
+
+		var resp kafkaEncoder
+		resp.putInt32(correlationID)
+
+		switch apiKey {
+		case kafkaAPIKeySaslHandshake:
+			resp.putInt16(0)
+			resp.putInt32(1)
+			resp.putString("PLAIN")
+		case kafkaAPIKeySaslAuthenticate:
+			n := req.int32()
+			if string(req.next(int(n))) != "\x00"+user+"\x00"+password {
+				resp.putInt16(58)
+				resp.putString("Authentication failed")
+				resp.putBytes(nil)
+				writeKafkaFrame(conn, resp.Bytes())
+				return
+			}
+			resp.putInt16(0)
+			resp.putInt16(-1)
+			resp.putBytes(nil)
+		case kafkaAPIKeyMetadata:
+			if apiVersion < 8 {
+				resp.putInt32(1)
+				resp.putInt32(0)
+				resp.putString("localhost")
+				resp.putInt32(9092)
+				resp.putInt16(-1)
+				resp.putInt32(0)
+				resp.putInt32(0)
+				break
+			}
+
+			_ = req.int32()
+			topic := req.nullableString()
+			ops, ok := authorizedOps[probeResourceTopic+" "+topic]
+
+			resp.putInt32(0)
+			resp.putInt32(0)
+			resp.putInt16(-1)
+			resp.putInt32(0)
+			resp.putInt32(1)
+			switch {
+			case topic == "unknown":
+				resp.putInt16(kafkaErrorUnknownTopic)
+			case !ok:
+				resp.putInt16(kafkaErrorTopicAuthorizationFailed)
+			default:
+				resp.putInt16(0)
+			}
+			resp.putString(topic)
+			resp.putBool(false)
+			resp.putInt32(0)
+			if !ok {
+				ops = kafkaAuthorizedOperationsNotDefined
+			}
+			resp.putInt32(ops)
+		case kafkaAPIKeyFindCoordinator:
+			resp.putInt32(0)
+			resp.putInt16(0)
+			resp.putInt16(-1)
+			resp.putInt32(0)
+			resp.putString(addr.IP.String())
+			resp.putInt32(int32(addr.Port))
+		case kafkaAPIKeyDescribeGroups:
+			_ = req.int32()
+			group := req.nullableString()
+			ops, ok := authorizedOps[probeResourceGroup+" "+group]
+
+			resp.putInt32(0)
+			resp.putInt32(1)
+			if ok {
+				resp.putInt16(0)
+			} else {
+				resp.putInt16(kafkaErrorGroupAuthorizationFailed)
+				ops = kafkaAuthorizedOperationsNotDefined
+			}
+			resp.putString(group)
+			resp.putString("Stable")
+			resp.putString("consumer")
+			resp.putString("range")
+			resp.putInt32(0)
+			resp.putInt32(ops)
+		default:
+			return
+		}
+
+		writeKafkaFrame(conn, resp.Bytes())
+	}
+}
+
+func writeKafkaFrame(w io.Writer, b []byte) {
+	frame := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	_, _ = w.Write(append(frame, b...))
+}
+
+// mockSchemaRegistry starts the Schema Registry which accepts basic authentication with the user-password pair.
+func mockSchemaRegistry(t *testing.T, user, password string) string {
+	t.Helper()
+
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/subjects" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`["foo-value"]`))
+			},
+		),
+	)
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func newMockResourceKey(id, secret, kind string) sdk.IamV2ApiKey {
+	return sdk.IamV2ApiKey{
+		Id: optString(id),
+		Spec: &sdk.IamV2ApiKeySpec{
+			Secret:   optString(secret),
+			Resource: &sdk.ObjectReference{Id: "lkc-123", Kind: optString(kind)},
+		},
+	}
+}
+
+func Test_dbClient_Test_connectivity(t *testing.T) {
+	broker := mockKafkaBroker(t, "foo", "bar", nil)
+	registry := mockSchemaRegistry(t, "foo", "bar")
+
+	tests := []struct {
+		name    string
+		key     sdk.IamV2ApiKey
+		secret  *SecretUser
+		wantErr bool
+	}{
+		{
+			name: "happy path: kafka cluster",
+			key:  newMockResourceKey("foo", "bar", resourceKindKafka),
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "bootstrap_servers": "SASL_SSL://" + broker,
+			},
+			wantErr: false,
+		},
+		{
+			name: "happy path: kafka cluster, first bootstrap server unreachable",
+			key:  newMockResourceKey("foo", "bar", resourceKindKafka),
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "bootstrap_servers": "127.0.0.1:1, " + broker,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unhappy path: kafka cluster, wrong secret",
+			key:  newMockResourceKey("foo", "bar", resourceKindKafka),
+			secret: &SecretUser{
+				"user": "foo", "password": "qux", "bootstrap_servers": broker,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: kafka cluster, bootstrap servers not found",
+			key:     newMockResourceKey("foo", "bar", resourceKindKafka),
+			secret:  &SecretUser{"user": "foo", "password": "bar"},
+			wantErr: true,
+		},
+		{
+			name: "happy path: schema registry",
+			key:  newMockResourceKey("foo", "bar", resourceKindSchemaRegistry),
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "schema_registry_url": registry + "/",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unhappy path: schema registry, wrong secret",
+			key:  newMockResourceKey("foo", "bar", resourceKindSchemaRegistry),
+			secret: &SecretUser{
+				"user": "foo", "password": "qux", "schema_registry_url": registry,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: schema registry, endpoint not found",
+			key:     newMockResourceKey("foo", "bar", resourceKindSchemaRegistry),
+			secret:  &SecretUser{"user": "foo", "password": "bar"},
+			wantErr: true,
+		},
+		{
+			name:    "happy path: cloud",
+			key:     newMockResourceKey("foo", "bar", resourceKindCloud),
+			secret:  &SecretUser{"user": "foo", "password": "bar"},
+			wantErr: false,
+		},
+		{
+			name:    "happy path: unknown resource kind, test skipped",
+			key:     newMockResourceKey("foo", "bar", "ksqlDB"),
+			secret:  &SecretUser{"user": "foo", "password": "bar"},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{tt.key.GetId(): tt.key}}
+				c, err := NewServiceClient(
+					&sdk.APIClient{APIKeysIamV2Api: m}, "admin", "admin-secret", "", "",
+					WithKafkaTLSConfig(nil), WithBackoff(0, 0),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+				defer cancel()
+
+				if err := c.Test(ctx, tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_readKafkaFrame(t *testing.T) {
+	frame := func(size int32, body []byte) []byte {
+		o := make([]byte, 4, 4+len(body))
+		binary.BigEndian.PutUint32(o, uint32(size))
+		return append(o, body...)
+	}
+
+	tests := []struct {
+		name    string
+		in      []byte
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			in:      frame(3, []byte("foo")),
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: negative size",
+			in:      frame(-1, nil),
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: size exceeds the limit",
+			in:      frame(kafkaMaxFrameSize+1, nil),
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: truncated frame",
+			in:      frame(4, []byte("foo")),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if _, err := readKafkaFrame(bytes.NewReader(tt.in)); (err != nil) != tt.wantErr {
+					t.Errorf("readKafkaFrame() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,377 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+// fakeIAMServer imitates the Confluent Cloud IAM v2 API keys endpoints.
+type fakeIAMServer struct {
+	mu sync.Mutex
+
+	adminKey, adminSecret string
+
+	keys    map[string]sdk.IamV2ApiKey
+	created int
+
+	// rateLimited the number of requests to reject with the status 429
+	rateLimited int
+	// rateLimitedCreates the number of creation requests to reject with the status 429
+	rateLimitedCreates int
+	// createRequests the number of creation requests
+	createRequests int
+	// createFailures the number of creation requests to fail with the status 500 after the key was created
+	createFailures int
+
+	userAgents map[string]struct{}
+}
+
+func (f *fakeIAMServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.userAgents[r.UserAgent()] = struct{}{}
+
+	if r.Method == http.MethodPost {
+		f.createRequests++
+	}
+
+	if f.rateLimited > 0 || r.Method == http.MethodPost && f.rateLimitedCreates > 0 {
+		if r.Method == http.MethodPost && f.rateLimitedCreates > 0 {
+			f.rateLimitedCreates--
+		} else {
+			f.rateLimited--
+		}
+		w.Header().Set("Retry-After", "0")
+		f.writeError(w, http.StatusTooManyRequests)
+		return
+	}
+
+	const prefix = "/iam/v2/api-keys"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		f.writeError(w, http.StatusNotFound)
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	user, password, _ := r.BasicAuth()
+	isAdmin := user == f.adminKey && password == f.adminSecret
+
+	switch {
+	case r.Method == http.MethodPost && id == "" && isAdmin:
+		var req sdk.IamV2ApiKey
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Spec == nil {
+			f.writeError(w, http.StatusBadRequest)
+			return
+		}
+		f.created++
+		k := sdk.IamV2ApiKey{
+			Id:       optString("KEY" + strconv.Itoa(f.created)),
+			Metadata: &sdk.ObjectMeta{CreatedAt: optTime(time.Now())},
+			Spec:     req.Spec,
+		}
+		k.Spec.SetSecret("SECRET" + strconv.Itoa(f.created))
+		f.keys[k.GetId()] = k
+		if f.createFailures > 0 {
+			f.createFailures--
+			f.writeError(w, http.StatusInternalServerError)
+			return
+		}
+		f.writeJSON(w, http.StatusAccepted, k)
+
+	case r.Method == http.MethodGet && id == "" && isAdmin:
+		list := sdk.IamV2ApiKeyList{Data: make([]sdk.IamV2ApiKey, 0, len(f.keys))}
+		for _, k := range f.keys {
+			list.Data = append(list.Data, f.withoutSecret(k))
+		}
+		f.writeJSON(w, http.StatusOK, list)
+
+	case r.Method == http.MethodGet && id != "":
+		k, ok := f.keys[id]
+		switch {
+		case ok && !isAdmin && (user != id || password != k.Spec.GetSecret()):
+			f.writeError(w, http.StatusUnauthorized)
+		case !ok && isAdmin:
+			f.writeError(w, http.StatusNotFound)
+		case !ok:
+			f.writeError(w, http.StatusUnauthorized)
+		default:
+			f.writeJSON(w, http.StatusOK, f.withoutSecret(k))
+		}
+
+	case r.Method == http.MethodDelete && id != "" && isAdmin:
+		if _, ok := f.keys[id]; !ok {
+			f.writeError(w, http.StatusNotFound)
+			return
+		}
+		delete(f.keys, id)
+		w.WriteHeader(http.StatusNoContent)
+
+	case !isAdmin:
+		f.writeError(w, http.StatusUnauthorized)
+
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeIAMServer) withoutSecret(k sdk.IamV2ApiKey) sdk.IamV2ApiKey {
+	spec := k.GetSpec()
+	spec.Secret = nil
+	k.Spec = &spec
+	return k
+}
+
+func (f *fakeIAMServer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeIAMServer) writeError(w http.ResponseWriter, status int) {
+	f.writeJSON(
+		w, status, map[string]any{
+			"errors": []map[string]string{{"status": strconv.Itoa(status), "detail": http.StatusText(status)}},
+		},
+	)
+}
+
+func (f *fakeIAMServer) keyIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o := make([]string, 0, len(f.keys))
+	for id := range f.keys {
+		o = append(o, id)
+	}
+	sort.Strings(o)
+	return o
+}
+
+// fakeSecretsmanager imitates the versioning of the AWS Secretsmanager secret.
+type fakeSecretsmanager struct {
+	values map[string]string
+	stages map[string][]string
+}
+
+func (f *fakeSecretsmanager) versionByStage(stage string) string {
+	for version, stages := range f.stages {
+		for _, s := range stages {
+			if s == stage {
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+func (f *fakeSecretsmanager) GetSecretValue(
+	ctx context.Context, input *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options),
+) (*secretsmanager.GetSecretValueOutput, error) {
+	version := aws.ToString(input.VersionId)
+	if version == "" {
+		version = f.versionByStage(aws.ToString(input.VersionStage))
+	}
+	v, ok := f.values[version]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(v), VersionId: aws.String(version)}, nil
+}
+
+func (f *fakeSecretsmanager) PutSecretValue(
+	ctx context.Context, input *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options),
+) (*secretsmanager.PutSecretValueOutput, error) {
+	version := aws.ToString(input.ClientRequestToken)
+	f.values[version] = aws.ToString(input.SecretString)
+	f.stages[version] = input.VersionStages
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeSecretsmanager) DescribeSecret(
+	ctx context.Context, input *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options),
+) (*secretsmanager.DescribeSecretOutput, error) {
+	return &secretsmanager.DescribeSecretOutput{RotationEnabled: aws.Bool(true), VersionIdsToStages: f.stages}, nil
+}
+
+func (f *fakeSecretsmanager) UpdateSecretVersionStage(
+	ctx context.Context, input *secretsmanager.UpdateSecretVersionStageInput, optFns ...func(*secretsmanager.Options),
+) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	from, to := aws.ToString(input.RemoveFromVersionId), aws.ToString(input.MoveToVersionId)
+	for version := range f.stages {
+		f.stages[version] = nil
+	}
+	f.stages[from] = []string{"AWSPREVIOUS"}
+	f.stages[to] = []string{aws.ToString(input.VersionStage)}
+	return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+}
+
+func TestRotation_e2e(t *testing.T) {
+	const (
+		secretARN = "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8"
+		version   = "v2"
+	)
+
+	retry := RetryPolicy{MaxRetries: 3, InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+
+	tests := []struct {
+		name               string
+		rateLimited        int
+		rateLimitedCreates int
+		createFailures     int
+		wantKey            string
+		wantSecret         string
+		wantCreateRequests int
+		wantErr            bool
+	}{
+		{
+			name:       "happy path",
+			wantKey:    "KEY1",
+			wantSecret: "SECRET1",
+			wantErr:    false,
+		},
+		{
+			name:        "happy path: rate limited requests retried",
+			rateLimited: 3,
+			wantKey:     "KEY1",
+			wantSecret:  "SECRET1",
+			wantErr:     false,
+		},
+		{
+			name:           "happy path: failed creation retried, the key created by the failed request deleted",
+			createFailures: 1,
+			wantKey:        "KEY2",
+			wantSecret:     "SECRET2",
+			wantErr:        false,
+		},
+		{
+			name:           "unhappy path: creation fails after all retries",
+			createFailures: 10,
+			wantErr:        true,
+		},
+		{
+			name:               "unhappy path: rate limited creation retried by a single layer",
+			rateLimitedCreates: 100,
+			wantCreateRequests: retry.MaxRetries + 1,
+			wantErr:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				server := &fakeIAMServer{
+					adminKey:    "admin",
+					adminSecret: "admin-secret",
+					keys: map[string]sdk.IamV2ApiKey{
+						"KEY0": {
+							Id: optString("KEY0"),
+							Spec: &sdk.IamV2ApiKeySpec{
+								Secret:      optString("SECRET0"),
+								DisplayName: optString("app"),
+								Owner:       &sdk.ObjectReference{Id: "sa-1", Kind: optString("ServiceAccount")},
+								Resource:    &sdk.ObjectReference{Id: "env-1", Kind: optString(resourceKindCloud)},
+							},
+						},
+					},
+					rateLimited:        tt.rateLimited,
+					rateLimitedCreates: tt.rateLimitedCreates,
+					createFailures:     tt.createFailures,
+					userAgents:         map[string]struct{}{},
+				}
+				ts := httptest.NewServer(server)
+				defer ts.Close()
+
+				apiClient, err := NewAPIClient(
+					APIClientConfig{BaseURL: ts.URL, HTTPClient: ts.Client(), RetryPolicy: &retry},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				client, err := NewServiceClient(
+					apiClient, server.adminKey, server.adminSecret, "", "",
+					WithBackoff(0, 0), WithRetryPolicy(retry),
+					WithDescriptionTemplate("{{.SecretName}} {{.VersionID}}"),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				secrets := &fakeSecretsmanager{
+					values: map[string]string{"v1": `{"user":"KEY0","password":"SECRET0","app":"foo"}`},
+					stages: map[string][]string{"v1": {"AWSCURRENT"}, version: {"AWSPENDING"}},
+				}
+
+				handler, err := lambda.NewHandler(
+					lambda.Config{SecretsmanagerClient: secrets, ServiceClient: client, SecretObj: &SecretUser{}},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				h := awslambda.NewHandler(handler)
+				for _, step := range []string{"createSecret", "setSecret", "testSecret", "finishSecret"} {
+					event, _ := json.Marshal(
+						map[string]string{"SecretId": secretARN, "ClientRequestToken": version, "Step": step},
+					)
+					if _, err = h.Invoke(context.TODO(), event); err != nil {
+						break
+					}
+				}
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("rotation error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantCreateRequests > 0 && server.createRequests != tt.wantCreateRequests {
+					t.Errorf("creation requests = %d, want %d", server.createRequests, tt.wantCreateRequests)
+				}
+				if tt.wantErr {
+					if keys := server.keyIDs(); !reflect.DeepEqual(keys, []string{"KEY0"}) {
+						t.Errorf("API keys = %v, want [KEY0]", keys)
+					}
+					return
+				}
+
+				var got SecretUser
+				if err := json.Unmarshal([]byte(secrets.values[secrets.versionByStage("AWSCURRENT")]), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if got["user"] != tt.wantKey || got["password"] != tt.wantSecret || got["app"] != "foo" {
+					t.Errorf("rotated secret = %v", got)
+				}
+
+				meta, _ := client.(*dbClient).metadata(got)
+				if meta.ID != tt.wantKey || meta.CreatedAt == nil || meta.Owner.GetId() != "sa-1" {
+					t.Errorf("rotated secret metadata = %+v", meta)
+				}
+				if want := "foo/bar v2 " + KeyDescriptionMarker(secretARN); meta.Description != want {
+					t.Errorf("new key description = %q, want %q", meta.Description, want)
+				}
+
+				if keys := server.keyIDs(); !reflect.DeepEqual(keys, []string{tt.wantKey}) {
+					t.Errorf("API keys = %v, want [%s]", keys, tt.wantKey)
+				}
+
+				if !reflect.DeepEqual(server.userAgents, map[string]struct{}{UserAgent(): {}}) {
+					t.Errorf("user agents = %v, want %s", server.userAgents, UserAgent())
+				}
+			},
+		)
+	}
+}
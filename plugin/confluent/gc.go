@@ -0,0 +1,273 @@
+package confluent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+// keyDescriptionMarkerPrefix the prefix of the marker of the API keys created upon rotation.
+const keyDescriptionMarkerPrefix = "[managed by aws-lambda-secret-rotation"
+
+// keyDescriptionMarkerRegexp matches the markers of any secret.
+var keyDescriptionMarkerRegexp = regexp.MustCompile(`\s*` + regexp.QuoteMeta(keyDescriptionMarkerPrefix) + `[^]]*]`)
+
+// KeyDescriptionMarker returns the marker of the description of the API keys created upon rotation of the secret,
+// e.g. "[managed by aws-lambda-secret-rotation 1a2b3c4d5e6f]". The secret is identified by the first 12 characters of
+// the hex encoded SHA-256 hash of its ARN, hence the keys of different secrets can be told apart.
+func KeyDescriptionMarker(secretARN string) string {
+	h := sha256.Sum256([]byte(secretARN))
+	return keyDescriptionMarkerPrefix + " " + hex.EncodeToString(h[:6]) + "]"
+}
+
+const (
+	// DefaultGarbageCollectorMinAge the default minimal age of the API key to be collected.
+	DefaultGarbageCollectorMinAge = time.Hour
+
+	listKeysPageSize = 100
+)
+
+// withDescriptionMarker appends the secret's marker to the API key's description,
+// the markers copied from the description of the current key are removed.
+func withDescriptionMarker(description, secretARN string) string {
+	description = strings.TrimSpace(keyDescriptionMarkerRegexp.ReplaceAllString(description, ""))
+	if description == "" {
+		return KeyDescriptionMarker(secretARN)
+	}
+	return description + " " + KeyDescriptionMarker(secretARN)
+}
+
+// GarbageCollectorConfig defines the configuration of the garbage collector of the orphaned API keys.
+type GarbageCollectorConfig struct {
+	SecretsmanagerClient lambda.SecretsmanagerClient
+
+	// ServiceClient the client to rotate the secret, it must be initialised using NewServiceClient.
+	ServiceClient lambda.ServiceClient
+
+	// DryRun the orphaned API keys are reported, but not deleted if set to true.
+	DryRun bool
+
+	// MinAge minimal age of the API key to be collected, DefaultGarbageCollectorMinAge by default.
+	// It prevents the deletion of the keys created by the rotation which is in progress.
+	MinAge time.Duration
+}
+
+// NewGarbageCollector initiates the garbage collector of the orphaned API keys.
+// The API key is orphaned if it was created upon rotation of the secret, i.e. its description contains the secret's
+// marker, see KeyDescriptionMarker, it belongs to the owner and resource of the key held in the secret, and the key is
+// referenced by none of the secret's versions staged as AWSCURRENT, AWSPENDING, or AWSPREVIOUS.
+// The key of the version staged as AWSPREVIOUS is kept because it's deleted upon rotation, see WithGracePeriod.
+// The keys of the same owner and resource created upon rotation of other secrets are kept.
+func NewGarbageCollector(cfg GarbageCollectorConfig) (*GarbageCollector, error) {
+	if cfg.SecretsmanagerClient == nil {
+		return nil, errors.New("secretsmanager client must be provided")
+	}
+
+	c, ok := cfg.ServiceClient.(*dbClient)
+	if !ok {
+		return nil, errors.New("service client must be initialised using NewServiceClient")
+	}
+
+	if cfg.MinAge == 0 {
+		cfg.MinAge = DefaultGarbageCollectorMinAge
+	}
+
+	return &GarbageCollector{
+		secretsmanager: cfg.SecretsmanagerClient,
+		c:              c,
+		dryRun:         cfg.DryRun,
+		minAge:         cfg.MinAge,
+	}, nil
+}
+
+// GarbageCollector deletes the orphaned API keys.
+type GarbageCollector struct {
+	secretsmanager lambda.SecretsmanagerClient
+	c              *dbClient
+	dryRun         bool
+	minAge         time.Duration
+}
+
+// Collect deletes the orphaned API keys of the secret, it returns the IDs of the orphaned keys.
+// The keys are only reported if the collector runs in dry-run mode.
+// Note that the secret must be identified by its full ARN because the keys are marked using the ARN.
+func (g GarbageCollector) Collect(ctx context.Context, secretARN string) ([]string, error) {
+	marker := KeyDescriptionMarker(secretARN)
+	referenced := map[string]struct{}{}
+
+	var current SecretUser
+	for _, stage := range []string{"AWSCURRENT", "AWSPENDING", "AWSPREVIOUS"} {
+		s, err := g.getSecret(ctx, secretARN, stage)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			continue
+		}
+		if stage == "AWSCURRENT" {
+			current = s
+		}
+
+		entries, err := g.c.entries(s)
+		if err != nil {
+			if stage == "AWSCURRENT" {
+				return nil, err
+			}
+			continue
+		}
+		for _, e := range entries {
+			referenced[g.c.apiKeyID(e)] = struct{}{}
+		}
+	}
+
+	if current == nil {
+		return nil, errors.New("secret's version staged as AWSCURRENT not found")
+	}
+
+	ctx = g.c.wrapContext(ctx)
+
+	var orphans []string
+	seen := map[string]struct{}{}
+
+	entries, _ := g.c.entries(current)
+	for _, e := range entries {
+		k, err := readKey(ctx, g.c.c.APIKeysIamV2Api, g.c.apiKeyID(e))
+		if err != nil {
+			return orphans, err
+		}
+
+		spec := k.GetSpec()
+		owner, resource := spec.Owner.GetId(), spec.Resource.GetId()
+		if owner == "" || resource == "" {
+			log.Println(`[INFO] API key "` + k.GetId() + `" is skipped: owner, or resource is not set`)
+			continue
+		}
+
+		keys, err := listKeys(ctx, g.c.c.APIKeysIamV2Api, owner, resource)
+		if err != nil {
+			return orphans, err
+		}
+
+		for _, key := range keys {
+			id := key.GetId()
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+
+			if !g.isOrphan(key, referenced, marker) {
+				continue
+			}
+
+			orphans = append(orphans, id)
+
+			if g.dryRun {
+				log.Println(`[INFO] dry-run: orphaned API key "` + id + `" found`)
+				continue
+			}
+
+			if err := deleteKey(ctx, g.c.c.APIKeysIamV2Api, id); err != nil {
+				return orphans, err
+			}
+			log.Println(`[INFO] orphaned API key "` + id + `" deleted`)
+		}
+	}
+
+	return orphans, nil
+}
+
+func (g GarbageCollector) isOrphan(key sdk.IamV2ApiKey, referenced map[string]struct{}, marker string) bool {
+	if _, ok := referenced[key.GetId()]; ok {
+		return false
+	}
+
+	spec := key.GetSpec()
+	if !strings.Contains(spec.GetDescription(), marker) {
+		return false
+	}
+
+	meta := key.GetMetadata()
+	createdAt, ok := meta.GetCreatedAtOk()
+	return ok && time.Since(*createdAt) >= g.minAge
+}
+
+// getSecret fetches the secret's version by its stage, it returns nil if the version does not exist.
+func (g GarbageCollector) getSecret(ctx context.Context, secretARN, stage string) (SecretUser, error) {
+	v, err := g.secretsmanager.GetSecretValue(
+		ctx, &secretsmanager.GetSecretValueInput{
+			SecretId:     aws.String(secretARN),
+			VersionStage: aws.String(stage),
+		},
+	)
+	if err != nil {
+		var errNotFound *types.ResourceNotFoundException
+		if errors.As(err, &errNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var s SecretUser
+	if err := lambda.ExtractSecretObject(v, &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// listKeys lists all API keys of the owner and resource, the empty resource denotes the keys without resource.
+func listKeys(ctx context.Context, c sdk.APIKeysIamV2Api, owner, resource string) ([]sdk.IamV2ApiKey, error) {
+	var o []sdk.IamV2ApiKey
+
+	var pageToken string
+	for {
+		r := c.ListIamV2ApiKeys(ctx).PageSize(listKeysPageSize).SpecOwner(owner)
+		if resource != "" {
+			r = r.SpecResource(resource)
+		}
+		if pageToken != "" {
+			r = r.PageToken(pageToken)
+		}
+
+		list, _, err := r.Execute()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range list.GetData() {
+			spec := k.GetSpec()
+			if spec.Owner.GetId() == owner && spec.Resource.GetId() == resource {
+				o = append(o, k)
+			}
+		}
+
+		meta := list.GetMetadata()
+		pageToken = nextPageToken(meta.GetNext())
+		if pageToken == "" {
+			return o, nil
+		}
+	}
+}
+
+// nextPageToken extracts the page token from the URL of the next page.
+func nextPageToken(next string) string {
+	if next == "" {
+		return ""
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("page_token")
+}
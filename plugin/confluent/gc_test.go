@@ -0,0 +1,230 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+// mockSecretsmanagerClient serves the secret's versions by their stages.
+type mockSecretsmanagerClient struct {
+	lambda.SecretsmanagerClient
+
+	versions map[string]any
+	err      error
+}
+
+func (m mockSecretsmanagerClient) GetSecretValue(
+	ctx context.Context, input *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options),
+) (*secretsmanager.GetSecretValueOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	v, ok := m.versions[*input.VersionStage]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	o, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(o)
+	return &secretsmanager.GetSecretValueOutput{SecretString: &s}, nil
+}
+
+func newMockGCKey(id, description string, age time.Duration) sdk.IamV2ApiKey {
+	return sdk.IamV2ApiKey{
+		Id:       optString(id),
+		Metadata: &sdk.ObjectMeta{CreatedAt: optTime(time.Now().Add(-age))},
+		Spec: &sdk.IamV2ApiKeySpec{
+			Description: optString(description),
+			Owner:       &sdk.ObjectReference{Id: "sa-1"},
+			Resource:    &sdk.ObjectReference{Id: "lkc-1"},
+		},
+	}
+}
+
+func TestGarbageCollector_Collect(t *testing.T) {
+	const secretARN = "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo"
+	marker := KeyDescriptionMarker(secretARN)
+
+	newKeys := func() map[string]sdk.IamV2ApiKey {
+		return map[string]sdk.IamV2ApiKey{
+			"current":  newMockGCKey("current", marker, 48*time.Hour),
+			"pending":  newMockGCKey("pending", marker, 2*time.Hour),
+			"previous": newMockGCKey("previous", marker, 72*time.Hour),
+			"orphan":   newMockGCKey("orphan", "foo "+marker, 24*time.Hour),
+			"fresh":    newMockGCKey("fresh", marker, time.Minute),
+			"manual":   newMockGCKey("manual", "created manually", 24*time.Hour),
+			"sibling": newMockGCKey(
+				"sibling", KeyDescriptionMarker("arn:aws:secretsmanager:us-east-1:000000000000:secret:bar"), 24*time.Hour,
+			),
+			"other": {
+				Id:       optString("other"),
+				Metadata: &sdk.ObjectMeta{CreatedAt: optTime(time.Now().Add(-24 * time.Hour))},
+				Spec: &sdk.IamV2ApiKeySpec{
+					Description: optString(marker),
+					Owner:       &sdk.ObjectReference{Id: "sa-2"},
+					Resource:    &sdk.ObjectReference{Id: "lkc-1"},
+				},
+			},
+		}
+	}
+
+	versions := map[string]any{
+		"AWSCURRENT":  map[string]string{"user": "current", "password": "foo"},
+		"AWSPENDING":  map[string]string{"user": "pending", "password": "bar"},
+		"AWSPREVIOUS": map[string]string{"user": "previous", "password": "baz"},
+	}
+
+	tests := []struct {
+		name        string
+		secrets     mockSecretsmanagerClient
+		keys        map[string]sdk.IamV2ApiKey
+		dryRun      bool
+		wantOrphans []string
+		wantKeys    []string
+		wantErr     bool
+	}{
+		{
+			name:        "happy path",
+			secrets:     mockSecretsmanagerClient{versions: versions},
+			keys:        newKeys(),
+			wantOrphans: []string{"orphan"},
+			wantKeys:    []string{"current", "fresh", "manual", "other", "pending", "previous", "sibling"},
+			wantErr:     false,
+		},
+		{
+			name:        "happy path: dry-run",
+			secrets:     mockSecretsmanagerClient{versions: versions},
+			keys:        newKeys(),
+			dryRun:      true,
+			wantOrphans: []string{"orphan"},
+			wantKeys:    []string{"current", "fresh", "manual", "orphan", "other", "pending", "previous", "sibling"},
+			wantErr:     false,
+		},
+		{
+			name: "happy path: no pending version",
+			secrets: mockSecretsmanagerClient{
+				versions: map[string]any{"AWSCURRENT": versions["AWSCURRENT"]},
+			},
+			keys:        newKeys(),
+			wantOrphans: []string{"orphan", "pending", "previous"},
+			wantKeys:    []string{"current", "fresh", "manual", "other", "sibling"},
+			wantErr:     false,
+		},
+		{
+			name:    "unhappy path: no current version",
+			secrets: mockSecretsmanagerClient{versions: map[string]any{}},
+			keys:    newKeys(),
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: secretsmanager error",
+			secrets: mockSecretsmanagerClient{err: errors.New("foo")},
+			keys:    newKeys(),
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: current key not found",
+			secrets: mockSecretsmanagerClient{versions: versions},
+			keys:    map[string]sdk.IamV2ApiKey{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{keys: tt.keys}
+				c, err := NewServiceClient(&sdk.APIClient{APIKeysIamV2Api: m}, "admin", "admin-secret", "", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				g, err := NewGarbageCollector(
+					GarbageCollectorConfig{SecretsmanagerClient: tt.secrets, ServiceClient: c, DryRun: tt.dryRun},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := g.Collect(context.TODO(), secretARN)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					if !reflect.DeepEqual(got, tt.wantOrphans) {
+						t.Errorf("Collect() got = %v, want %v", got, tt.wantOrphans)
+					}
+					if keys := mockKeyIDs(m); !reflect.DeepEqual(keys, tt.wantKeys) {
+						t.Errorf("Collect() remaining keys = %v, want %v", keys, tt.wantKeys)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestNewGarbageCollector(t *testing.T) {
+	c, err := NewServiceClient(&sdk.APIClient{APIKeysIamV2Api: &mockAPIKeysIamV2Api{}}, "admin", "admin-secret", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewGarbageCollector(GarbageCollectorConfig{ServiceClient: c}); err == nil {
+		t.Errorf("NewGarbageCollector() error expected: secretsmanager client is not set")
+	}
+
+	if _, err := NewGarbageCollector(
+		GarbageCollectorConfig{SecretsmanagerClient: mockSecretsmanagerClient{}, ServiceClient: adminClient{}},
+	); err == nil {
+		t.Errorf("NewGarbageCollector() error expected: wrong type of the service client")
+	}
+
+	g, err := NewGarbageCollector(GarbageCollectorConfig{SecretsmanagerClient: mockSecretsmanagerClient{}, ServiceClient: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.minAge != DefaultGarbageCollectorMinAge {
+		t.Errorf("NewGarbageCollector() minAge = %v, want %v", g.minAge, DefaultGarbageCollectorMinAge)
+	}
+}
+
+func Test_withDescriptionMarker(t *testing.T) {
+	const secretARN = "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo"
+	marker := KeyDescriptionMarker(secretARN)
+
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{description: "", want: marker},
+		{description: "foo", want: "foo " + marker},
+		{description: "foo " + marker, want: "foo " + marker},
+		{description: "foo " + KeyDescriptionMarker("bar") + " baz", want: "foo baz " + marker},
+		{description: "foo [managed by aws-lambda-secret-rotation]", want: "foo " + marker},
+	}
+	for _, tt := range tests {
+		if got := withDescriptionMarker(tt.description, secretARN); got != tt.want {
+			t.Errorf("withDescriptionMarker(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestKeyDescriptionMarker(t *testing.T) {
+	got := KeyDescriptionMarker("arn:aws:secretsmanager:us-east-1:000000000000:secret:foo")
+	if !keyDescriptionMarkerRegexp.MatchString(got) || len(got) != len(keyDescriptionMarkerPrefix)+14 {
+		t.Errorf("KeyDescriptionMarker() = %q, want the prefix followed by the hash of 12 characters", got)
+	}
+	if got == KeyDescriptionMarker("arn:aws:secretsmanager:us-east-1:000000000000:secret:bar") {
+		t.Errorf("KeyDescriptionMarker() = %q, want different markers for different secrets", got)
+	}
+}
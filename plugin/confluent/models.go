@@ -1,5 +1,14 @@
 package confluent
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
 // SecretAdmin defines the secret with the db admin access details.
 type SecretAdmin struct {
 	// Confluent API Key
@@ -8,8 +17,66 @@ type SecretAdmin struct {
 	APISecret string `json:"cloud_api_secret"`
 }
 
-// SecretUser defines the secret with db user access details.
-// The map of attributes must include
+// SecretUser defines the secret with db user access details as an arbitrary JSON document.
+// The document must include the string attributes addressed by the paths, see `NewServiceClient`:
 // user 	<- API Key
 // password <- API Secret
-type SecretUser map[string]string
+// All other attributes are left intact upon rotation.
+type SecretUser map[string]any
+
+// UnmarshalJSON decodes the JSON object and keeps the numbers' literal representation.
+func (s *SecretUser) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var o map[string]any
+	if err := dec.Decode(&o); err != nil {
+		return err
+	}
+	if o == nil {
+		return errors.New("secret must be a JSON object")
+	}
+
+	*s = o
+	return nil
+}
+
+// Strings returns the secret's top-level string attributes, the attributes of other types are omitted.
+// It keeps the code written for the `SecretUser` defined as `map[string]string` prior to v0.2.0 working,
+// e.g. `s.Strings()["user"]`.
+func (s SecretUser) Strings() map[string]string {
+	o := make(map[string]string, len(s))
+	for k, v := range s {
+		if v, ok := v.(string); ok {
+			o[k] = v
+		}
+	}
+	return o
+}
+
+// NewSecretUserFromStrings converts the secret defined as `map[string]string`, the type of `SecretUser` prior to
+// v0.2.0, to `SecretUser`.
+func NewSecretUserFromStrings(m map[string]string) SecretUser {
+	o := make(SecretUser, len(m))
+	for k, v := range m {
+		o[k] = v
+	}
+	return o
+}
+
+// KeyMetadata defines the API key's details stored in the `SecretUser` next to the API key-secret pair.
+// The details are used to recreate the API key if it was deleted.
+type KeyMetadata struct {
+	ID          string               `json:"id,omitempty"`
+	DisplayName string               `json:"display_name,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Owner       *sdk.ObjectReference `json:"owner,omitempty"`
+	Resource    *sdk.ObjectReference `json:"resource,omitempty"`
+	CreatedAt   *time.Time           `json:"created_at,omitempty"`
+	// Permissions the baseline of the owner's permissions captured upon the step createSecret,
+	// it's compared with the owner's permissions upon the next rotation, see WithPermissionsComparison
+	Permissions []string `json:"permissions,omitempty"`
+	// PreviousPermissions the baseline captured by the previous rotation, i.e. stored with the secret's version
+	// staged as AWSCURRENT upon the step createSecret, see WithPermissionsComparison
+	PreviousPermissions []string `json:"previous_permissions,omitempty"`
+}
@@ -0,0 +1,465 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+// kafkaOperations the codes of the Kafka ACL operations,
+// see https://kafka.apache.org/documentation/#operations_resources_and_protocols
+var kafkaOperations = map[string]int32{
+	"read":             3,
+	"write":            4,
+	"create":           5,
+	"delete":           6,
+	"alter":            7,
+	"describe":         8,
+	"describe_configs": 10,
+	"alter_configs":    11,
+}
+
+const (
+	probeResourceTopic = "topic"
+	probeResourceGroup = "group"
+
+	kafkaErrorUnknownTopic              int16 = 3
+	kafkaErrorTopicAuthorizationFailed  int16 = 29
+	kafkaErrorGroupAuthorizationFailed  int16 = 30
+	kafkaAuthorizedOperationsNotDefined int32 = math.MinInt32
+)
+
+// errPermissionDenied indicates that the API key lacks the permissions.
+var errPermissionDenied = errors.New("permission denied")
+
+// PermissionProbe defines the operation on the Kafka resource which must be permitted to the new API key.
+type PermissionProbe struct {
+	// Operation the Kafka ACL operation, e.g. "read", or "describe"
+	Operation string
+	// ResourceType the type of Kafka resource, "topic" or "group"
+	ResourceType string
+	// ResourceName the name of the topic, or the ID of the consumer group
+	ResourceName string
+}
+
+func (p PermissionProbe) String() string {
+	return p.Operation + " " + p.ResourceType + " " + p.ResourceName
+}
+
+// ParsePermissionProbe parses the probe defined as "{operation} {resource type} {resource name}",
+// e.g. "describe topic orders", or "read group app".
+func ParsePermissionProbe(s string) (PermissionProbe, error) {
+	f := strings.Fields(s)
+	if len(f) != 3 {
+		return PermissionProbe{}, errors.New(`wrong permission probe "` + s + `": "{operation} {resource type} {name}" expected`)
+	}
+
+	p := PermissionProbe{Operation: strings.ToLower(f[0]), ResourceType: strings.ToLower(f[1]), ResourceName: f[2]}
+	if _, ok := kafkaOperations[p.Operation]; !ok {
+		return PermissionProbe{}, errors.New(`wrong permission probe "` + s + `": unknown operation`)
+	}
+	switch p.ResourceType {
+	case probeResourceTopic, probeResourceGroup:
+	default:
+		return PermissionProbe{}, errors.New(`wrong permission probe "` + s + `": topic, or group resource expected`)
+	}
+	return p, nil
+}
+
+// verifyPermissions compares the permissions of the new API key's owner with the baseline of the previous rotation
+// stored in the secret, and runs the permission probes with the new key.
+func (c dbClient) verifyPermissions(ctx context.Context, e SecretUser) error {
+	if !c.comparePermissions && len(c.permissionProbes) == 0 {
+		return nil
+	}
+
+	key, keySecret := c.apiKeyID(e), c.apiKeySecret(e)
+
+	k, err := readKey(c.wrapContext(ctx), c.c.APIKeysIamV2Api, key)
+	if err != nil {
+		return err
+	}
+
+	if c.comparePermissions {
+		meta, ok := c.metadata(e)
+		if !ok {
+			return errors.New(`API key "` + key + `": permissions baseline not found, "` + c.attributeMetadata +
+				`" field is expected to be set upon the step createSecret`)
+		}
+		baseline := meta.PreviousPermissions
+		if baseline == nil {
+			baseline = meta.Permissions
+		}
+		current, err := c.permissions(ctx, e, k.GetSpec())
+		if err != nil {
+			return err
+		}
+		if missing := difference(baseline, current); len(missing) > 0 {
+			return fmt.Errorf(
+				`%w: API key "%s" lacks the permissions of the baseline: %s`,
+				errPermissionDenied, key, strings.Join(missing, "; "),
+			)
+		}
+	}
+
+	if len(c.permissionProbes) == 0 {
+		return nil
+	}
+	spec := k.GetSpec()
+	if spec.Resource.GetKind() != resourceKindKafka {
+		log.Println(`[INFO] API key "` + key + `" is not scoped to Kafka cluster, permission probes skipped`)
+		return nil
+	}
+	bootstrapServers, _ := e.getString(c.attributeBootstrapServers)
+	return c.testPermissionProbes(ctx, bootstrapServers, key, keySecret)
+}
+
+// permissions lists the role bindings and the Kafka ACLs of the API key's owner using the admin API key.
+// The role bindings are listed if the CRN pattern is set, see WithPermissionsComparison. The ACLs are listed
+// if the key is scoped to Kafka cluster and the secret's entry contains the cluster's REST endpoint.
+func (c dbClient) permissions(ctx context.Context, e SecretUser, spec sdk.IamV2ApiKeySpec) ([]string, error) {
+	principal := "User:" + spec.Owner.GetId()
+
+	o := []string{}
+
+	if c.roleBindingsCRNPattern != "" {
+		v, err := c.listRoleBindings(ctx, principal)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, v...)
+	}
+
+	if endpoint, ok := e.getString(c.attributeRESTEndpoint); ok && spec.Resource.GetKind() == resourceKindKafka {
+		v, err := c.listACLs(ctx, endpoint, spec.Resource.GetId(), principal)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, v...)
+	}
+
+	sort.Strings(o)
+	return o, nil
+}
+
+// listRoleBindings lists the role bindings of the principal using the Confluent Cloud IAM API.
+func (c dbClient) listRoleBindings(ctx context.Context, principal string) ([]string, error) {
+	cfg := c.c.GetConfig()
+
+	q := url.Values{}
+	q.Set("principal", principal)
+	q.Set("crn_pattern", c.roleBindingsCRNPattern)
+	endpoint := strings.TrimSuffix(cfg.Servers[0].URL, "/") + "/iam/v2/role-bindings?" + q.Encode()
+
+	var o []string
+	for endpoint != "" {
+		var page struct {
+			Data []struct {
+				RoleName   string `json:"role_name"`
+				CRNPattern string `json:"crn_pattern"`
+			} `json:"data"`
+			Metadata struct {
+				Next string `json:"next"`
+			} `json:"metadata"`
+		}
+		if err := getJSON(
+			ctx, cfg.HTTPClient, endpoint, cfg.UserAgent, c.apiKey, c.apiSecret, &page,
+		); err != nil {
+			return nil, errors.New("unable to list role bindings: " + err.Error())
+		}
+
+		for _, v := range page.Data {
+			o = append(o, "role-binding: "+v.RoleName+" "+v.CRNPattern)
+		}
+		endpoint = page.Metadata.Next
+	}
+	return o, nil
+}
+
+// listACLs lists the ACLs of the principal using the Kafka REST API v3.
+func (c dbClient) listACLs(ctx context.Context, endpoint, clusterID, principal string) ([]string, error) {
+	q := url.Values{}
+	q.Set("principal", principal)
+	endpoint = strings.TrimSuffix(endpoint, "/") + "/kafka/v3/clusters/" + url.PathEscape(clusterID) + "/acls?" +
+		q.Encode()
+
+	var resp struct {
+		Data []struct {
+			ResourceType string `json:"resource_type"`
+			ResourceName string `json:"resource_name"`
+			PatternType  string `json:"pattern_type"`
+			Host         string `json:"host"`
+			Operation    string `json:"operation"`
+			Permission   string `json:"permission"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, c.httpClient, endpoint, "", c.apiKey, c.apiSecret, &resp); err != nil {
+		return nil, errors.New("unable to list ACLs: " + err.Error())
+	}
+
+	o := make([]string, len(resp.Data))
+	for i, v := range resp.Data {
+		o[i] = "acl: " + v.ResourceType + ":" + v.PatternType + ":" + v.ResourceName + " " + v.Operation + " " +
+			v.Permission + " " + v.Host
+	}
+	return o, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, endpoint, userAgent, user, password string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(user, password)
+	req.Header.Set("Accept", "application/json")
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return errors.New("unexpected response status " + resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// difference returns the elements of the sorted slice a which are not found in the sorted slice b.
+func difference(a, b []string) []string {
+	var o []string
+	for _, v := range a {
+		i := sort.SearchStrings(b, v)
+		if i == len(b) || b[i] != v {
+			o = append(o, v)
+		}
+	}
+	return o
+}
+
+// testPermissionProbes authenticates with the API key-secret pair and runs the permission probes
+// against the first reachable bootstrap server.
+func (c dbClient) testPermissionProbes(ctx context.Context, bootstrapServers, key, secret string) error {
+	if bootstrapServers == "" {
+		return errors.New(`wrong secret type: "` + c.attributeBootstrapServers + `" field not found`)
+	}
+
+	dial := func(addr string, fn func(k *kafkaConn) error) error {
+		return withKafkaConn(ctx, addr, key, secret, c.kafkaTLSConfig, fn)
+	}
+
+	var err error
+	for _, addr := range splitBootstrapServers(bootstrapServers) {
+		err = dial(
+			addr, func(k *kafkaConn) error {
+				return runPermissionProbes(k, dial, key, c.permissionProbes)
+			},
+		)
+		if err == nil || errors.Is(err, errPermissionDenied) {
+			return err
+		}
+	}
+	return err
+}
+
+// kafkaDialer connects to the broker and calls fn.
+type kafkaDialer func(addr string, fn func(k *kafkaConn) error) error
+
+func runPermissionProbes(k *kafkaConn, dial kafkaDialer, key string, probes []PermissionProbe) error {
+	var denied []string
+	for _, p := range probes {
+		var (
+			ops int32
+			err error
+		)
+		switch p.ResourceType {
+		case probeResourceTopic:
+			ops, err = kafkaTopicAuthorizedOperations(k, p.ResourceName)
+		case probeResourceGroup:
+			ops, err = kafkaGroupAuthorizedOperations(k, dial, p.ResourceName)
+		}
+		if err != nil {
+			return err
+		}
+
+		if ops == kafkaAuthorizedOperationsNotDefined || ops&(1<<kafkaOperations[p.Operation]) == 0 {
+			denied = append(denied, p.String())
+		}
+	}
+
+	if len(denied) > 0 {
+		return fmt.Errorf(`%w: API key "%s" probes failed: %s`, errPermissionDenied, key, strings.Join(denied, "; "))
+	}
+	return nil
+}
+
+// kafkaTopicAuthorizedOperations returns the bit field of the operations on the topic authorized to the client.
+// The Metadata request v8 is used, see https://cwiki.apache.org/confluence/x/hJ5ABg (KIP-430).
+func kafkaTopicAuthorizedOperations(k *kafkaConn, topic string) (int32, error) {
+	var req kafkaEncoder
+	req.putInt32(1)
+	req.putString(topic)
+	// allow_auto_topic_creation, include_cluster_authorized_operations, include_topic_authorized_operations
+	req.putBool(false)
+	req.putBool(false)
+	req.putBool(true)
+
+	resp, err := k.roundTrip(kafkaAPIKeyMetadata, 8, req.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	_ = resp.int32() // throttle_time_ms
+	resp.array(
+		func() {
+			_, _, _, _ = resp.int32(), resp.nullableString(), resp.int32(), resp.nullableString()
+		},
+	)
+	_, _ = resp.nullableString(), resp.int32() // cluster_id, controller_id
+
+	var (
+		code  int16
+		ops   = kafkaAuthorizedOperationsNotDefined
+		found bool
+	)
+	resp.array(
+		func() {
+			c, name := resp.int16(), resp.nullableString()
+			_ = resp.int8() // is_internal
+			resp.array(
+				func() {
+					_, _, _, _ = resp.int16(), resp.int32(), resp.int32(), resp.int32()
+					for i := 0; i < 3; i++ {
+						resp.array(func() { _ = resp.int32() })
+					}
+				},
+			)
+			o := resp.int32()
+			if name == topic {
+				code, ops, found = c, o, true
+			}
+		},
+	)
+	if resp.err != nil || !found {
+		return 0, errors.New("kafka Metadata response is corrupt: topic " + topic + " not found")
+	}
+
+	switch code {
+	case 0:
+		return ops, nil
+	case kafkaErrorTopicAuthorizationFailed:
+		return 0, nil
+	case kafkaErrorUnknownTopic:
+		return 0, errors.New("kafka topic " + topic + " not found")
+	default:
+		return 0, kafkaError("Metadata", code, "")
+	}
+}
+
+// kafkaGroupAuthorizedOperations returns the bit field of the operations on the consumer group authorized
+// to the client. The FindCoordinator request v1 is sent to find the group's coordinator, then the DescribeGroups
+// request v3 is sent to the coordinator, see https://cwiki.apache.org/confluence/x/hJ5ABg (KIP-430).
+func kafkaGroupAuthorizedOperations(k *kafkaConn, dial kafkaDialer, group string) (int32, error) {
+	var req kafkaEncoder
+	req.putString(group)
+	// key_type: group
+	_ = req.WriteByte(0)
+
+	resp, err := k.roundTrip(kafkaAPIKeyFindCoordinator, 1, req.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	_ = resp.int32() // throttle_time_ms
+	code, msg := resp.int16(), resp.nullableString()
+	_, host, port := resp.int32(), resp.nullableString(), resp.int32()
+	if resp.err != nil {
+		return 0, errors.New("kafka FindCoordinator response is corrupt")
+	}
+	switch code {
+	case 0:
+	case kafkaErrorGroupAuthorizationFailed:
+		return 0, nil
+	default:
+		return 0, kafkaError("FindCoordinator", code, msg)
+	}
+
+	ops := kafkaAuthorizedOperationsNotDefined
+	err = dial(
+		net.JoinHostPort(host, strconv.Itoa(int(port))), func(k *kafkaConn) error {
+			ops, err = kafkaDescribeGroupAuthorizedOperations(k, group)
+			return err
+		},
+	)
+	return ops, err
+}
+
+func kafkaDescribeGroupAuthorizedOperations(k *kafkaConn, group string) (int32, error) {
+	var req kafkaEncoder
+	req.putInt32(1)
+	req.putString(group)
+	// include_authorized_operations
+	req.putBool(true)
+
+	resp, err := k.roundTrip(kafkaAPIKeyDescribeGroups, 3, req.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	_ = resp.int32() // throttle_time_ms
+
+	var (
+		code  int16
+		ops   = kafkaAuthorizedOperationsNotDefined
+		found bool
+	)
+	resp.array(
+		func() {
+			c, id := resp.int16(), resp.nullableString()
+			// group_state, protocol_type, protocol_data
+			_, _, _ = resp.nullableString(), resp.nullableString(), resp.nullableString()
+			resp.array(
+				func() {
+					_, _, _ = resp.nullableString(), resp.nullableString(), resp.nullableString()
+					resp.bytes()
+					resp.bytes()
+				},
+			)
+			o := resp.int32()
+			if id == group {
+				code, ops, found = c, o, true
+			}
+		},
+	)
+	if resp.err != nil || !found {
+		return 0, errors.New("kafka DescribeGroups response is corrupt: group " + group + " not found")
+	}
+
+	switch code {
+	case 0:
+		return ops, nil
+	case kafkaErrorGroupAuthorizationFailed:
+		return 0, nil
+	default:
+		return 0, kafkaError("DescribeGroups", code, "")
+	}
+}
@@ -0,0 +1,408 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+// mockPermissionsServer starts the server which serves the owner's role bindings using the IAM API,
+// and the ACLs using the Kafka REST API. The ACLs are only listed to the user-password pair.
+func mockPermissionsServer(t *testing.T, user, password string, roles, acls []string) string {
+	t.Helper()
+
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				var o []map[string]string
+				switch r.URL.Path {
+				case "/iam/v2/role-bindings":
+					if r.URL.Query().Get("principal") != "User:sa-1" || r.URL.Query().Get("crn_pattern") == "" {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					for _, v := range roles {
+						o = append(o, map[string]string{"role_name": v, "crn_pattern": "crn://confluent.cloud/*"})
+					}
+				case "/kafka/v3/clusters/lkc-123/acls":
+					if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					for _, v := range acls {
+						o = append(
+							o, map[string]string{
+								"resource_type": "TOPIC", "resource_name": v, "pattern_type": "LITERAL", "host": "*",
+								"operation": "READ", "permission": "ALLOW",
+							},
+						)
+					}
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"data": o})
+			},
+		),
+	)
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func newMockPermissionsAPIClient(baseURL string, m *mockAPIKeysIamV2Api) *sdk.APIClient {
+	cfg := sdk.NewConfiguration()
+	cfg.Servers[0].URL = baseURL
+	c := sdk.NewAPIClient(cfg)
+	c.APIKeysIamV2Api = m
+	return c
+}
+
+func Test_dbClient_Test_permissions(t *testing.T) {
+	broker := mockKafkaBroker(
+		t, "foo", "bar", map[string]int32{
+			"topic orders": 1<<kafkaOperations["read"] | 1<<kafkaOperations["describe"],
+			"group app":    1 << kafkaOperations["read"],
+		},
+	)
+	server := mockPermissionsServer(t, "admin", "admin-secret", []string{"DeveloperRead"}, []string{"orders"})
+
+	baseline := []string{
+		"acl: TOPIC:LITERAL:orders READ ALLOW *",
+		"role-binding: DeveloperRead crn://confluent.cloud/*",
+	}
+
+	newKey := func(kind string) sdk.IamV2ApiKey {
+		k := newMockResourceKey("foo", "bar", kind)
+		k.Spec.Owner = &sdk.ObjectReference{Id: "sa-1"}
+		return k
+	}
+
+	tests := []struct {
+		name    string
+		key     sdk.IamV2ApiKey
+		opts    []Option
+		secret  *SecretUser
+		wantErr error
+	}{
+		{
+			name: "happy path: probes permitted",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{
+				WithPermissionProbes(
+					PermissionProbe{Operation: "describe", ResourceType: "topic", ResourceName: "orders"},
+					PermissionProbe{Operation: "read", ResourceType: "group", ResourceName: "app"},
+				),
+			},
+			secret:  &SecretUser{"user": "foo", "password": "bar", "bootstrap_servers": broker},
+			wantErr: nil,
+		},
+		{
+			name: "unhappy path: topic operation denied",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{
+				WithPermissionProbes(PermissionProbe{Operation: "write", ResourceType: "topic", ResourceName: "orders"}),
+			},
+			secret:  &SecretUser{"user": "foo", "password": "bar", "bootstrap_servers": broker},
+			wantErr: errPermissionDenied,
+		},
+		{
+			name: "unhappy path: topic access denied",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{
+				WithPermissionProbes(
+					PermissionProbe{Operation: "describe", ResourceType: "topic", ResourceName: "payments"},
+				),
+			},
+			secret:  &SecretUser{"user": "foo", "password": "bar", "bootstrap_servers": broker},
+			wantErr: errPermissionDenied,
+		},
+		{
+			name: "unhappy path: group access denied",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{
+				WithPermissionProbes(PermissionProbe{Operation: "read", ResourceType: "group", ResourceName: "etl"}),
+			},
+			secret:  &SecretUser{"user": "foo", "password": "bar", "bootstrap_servers": broker},
+			wantErr: errPermissionDenied,
+		},
+		{
+			name: "unhappy path: topic not found",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{
+				WithPermissionProbes(
+					PermissionProbe{Operation: "describe", ResourceType: "topic", ResourceName: "unknown"},
+				),
+			},
+			secret:  &SecretUser{"user": "foo", "password": "bar", "bootstrap_servers": broker},
+			wantErr: errors.New("kafka topic unknown not found"),
+		},
+		{
+			name: "happy path: probes skipped for the key not scoped to Kafka cluster",
+			key:  newKey(resourceKindCloud),
+			opts: []Option{
+				WithPermissionProbes(PermissionProbe{Operation: "write", ResourceType: "topic", ResourceName: "orders"}),
+			},
+			secret:  &SecretUser{"user": "foo", "password": "bar"},
+			wantErr: nil,
+		},
+		{
+			name: "happy path: baseline permissions kept",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{WithPermissionsComparison("crn://confluent.cloud/*")},
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "bootstrap_servers": broker, "rest_endpoint": server,
+				"api_key_metadata": map[string]any{"permissions": baseline},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "unhappy path: no baseline",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{WithPermissionsComparison("crn://confluent.cloud/*")},
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "bootstrap_servers": broker, "rest_endpoint": server,
+			},
+			wantErr: errors.New(
+				`API key "foo": permissions baseline not found, "api_key_metadata" field is expected to be set ` +
+					`upon the step createSecret`,
+			),
+		},
+		{
+			name: "unhappy path: baseline permission lost",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{WithPermissionsComparison("crn://confluent.cloud/*")},
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "bootstrap_servers": broker, "rest_endpoint": server,
+				"api_key_metadata": map[string]any{
+					"permissions": append(baseline, "role-binding: CloudClusterAdmin crn://confluent.cloud/*"),
+				},
+			},
+			wantErr: errPermissionDenied,
+		},
+		{
+			name: "unhappy path: ACLs cannot be listed",
+			key:  newKey(resourceKindKafka),
+			opts: []Option{WithPermissionsComparison("")},
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "bootstrap_servers": broker, "rest_endpoint": server + "/foo",
+				"api_key_metadata": map[string]any{"permissions": baseline},
+			},
+			wantErr: errors.New("unable to list ACLs: unexpected response status 404 Not Found"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{tt.key.GetId(): tt.key}}
+				c, err := NewServiceClient(
+					newMockPermissionsAPIClient(server, m), "admin", "admin-secret", "", "",
+					append(tt.opts, WithKafkaTLSConfig(nil), WithBackoff(0, 0))...,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+				defer cancel()
+
+				err = c.Test(ctx, tt.secret)
+				switch {
+				case tt.wantErr == nil && err != nil:
+					t.Errorf("Test() unexpected error = %v", err)
+				case tt.wantErr == errPermissionDenied && !errors.Is(err, errPermissionDenied):
+					t.Errorf("Test() error = %v, want %v", err, tt.wantErr)
+				case tt.wantErr != nil && tt.wantErr != errPermissionDenied &&
+					(err == nil || err.Error() != tt.wantErr.Error()):
+					t.Errorf("Test() error = %v, want %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Create_permissionsBaseline(t *testing.T) {
+	server := mockPermissionsServer(t, "admin", "admin-secret", []string{"DeveloperRead"}, []string{"orders"})
+
+	wantPermissions := []string{
+		"acl: TOPIC:LITERAL:orders READ ALLOW *",
+		"role-binding: DeveloperRead crn://confluent.cloud/*",
+	}
+
+	tests := []struct {
+		name                    string
+		secret                  *SecretUser
+		wantPermissions         []string
+		wantPreviousPermissions []string
+	}{
+		{
+			name:            "happy path: baseline captured upon the first rotation",
+			secret:          &SecretUser{"user": "foo", "password": "bar", "rest_endpoint": server},
+			wantPermissions: wantPermissions,
+		},
+		{
+			name: "happy path: baseline of the previous rotation carried",
+			secret: &SecretUser{
+				"user": "foo", "password": "bar", "rest_endpoint": server,
+				"api_key_metadata": map[string]any{"permissions": []string{"role-binding: Operator crn://foo"}},
+			},
+			wantPermissions:         wantPermissions,
+			wantPreviousPermissions: []string{"role-binding: Operator crn://foo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{
+					keys: map[string]sdk.IamV2ApiKey{
+						"foo": {
+							Id: optString("foo"),
+							Spec: &sdk.IamV2ApiKeySpec{
+								Owner:    &sdk.ObjectReference{Id: "sa-1"},
+								Resource: &sdk.ObjectReference{Id: "lkc-123", Kind: optString(resourceKindKafka)},
+							},
+						},
+					},
+				}
+				c, err := NewServiceClient(
+					newMockPermissionsAPIClient(server, m), "admin", "admin-secret", "", "",
+					WithPermissionsComparison("crn://confluent.cloud/*"), WithBackoff(0, 0),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := c.Create(context.TODO(), tt.secret); err != nil {
+					t.Fatalf("Create() unexpected error = %v", err)
+				}
+
+				got, _ := c.(*dbClient).metadata(*tt.secret)
+				if !reflect.DeepEqual(got.Permissions, tt.wantPermissions) {
+					t.Errorf("Create() permissions = %v, want %v", got.Permissions, tt.wantPermissions)
+				}
+				if !reflect.DeepEqual(got.PreviousPermissions, tt.wantPreviousPermissions) {
+					t.Errorf(
+						"Create() previous permissions = %v, want %v", got.PreviousPermissions,
+						tt.wantPreviousPermissions,
+					)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_permissionsDrift(t *testing.T) {
+	// the role binding CloudClusterAdmin is removed between the first and the second rotation
+	serverFirst := mockPermissionsServer(t, "admin", "admin-secret", []string{"CloudClusterAdmin", "DeveloperRead"}, nil)
+	serverSecond := mockPermissionsServer(t, "admin", "admin-secret", []string{"DeveloperRead"}, nil)
+
+	rotate := func(server string, secret *SecretUser) error {
+		m := &mockAPIKeysIamV2Api{
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo": {
+					Id: optString("foo"),
+					Spec: &sdk.IamV2ApiKeySpec{
+						Owner:    &sdk.ObjectReference{Id: "sa-1"},
+						Resource: &sdk.ObjectReference{Id: "cloud", Kind: optString(resourceKindCloud)},
+					},
+				},
+			},
+		}
+		c, err := NewServiceClient(
+			newMockPermissionsAPIClient(server, m), "admin", "admin-secret", "", "",
+			WithPermissionsComparison("crn://confluent.cloud/*"), WithBackoff(0, 0),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Create(context.TODO(), secret); err != nil {
+			t.Fatalf("Create() unexpected error = %v", err)
+		}
+
+		// the mock creates the key without the spec of the current key
+		id, _ := secret.getString("user")
+		m.keys[id] = m.keys["foo"]
+		return c.Test(context.TODO(), secret)
+	}
+
+	// the first rotation's pending secret becomes the secret's version staged as AWSCURRENT
+	secret := &SecretUser{"user": "foo", "password": "bar"}
+	if err := rotate(serverFirst, secret); err != nil {
+		t.Fatalf("Test() unexpected error upon the first rotation = %v", err)
+	}
+
+	b, err := json.Marshal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current SecretUser
+	if err := json.Unmarshal(b, &current); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rotate(serverSecond, &current); !errors.Is(err, errPermissionDenied) {
+		t.Errorf("Test() error upon the second rotation = %v, want %v", err, errPermissionDenied)
+	}
+}
+
+func TestParsePermissionProbe(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    PermissionProbe
+		wantErr bool
+	}{
+		{
+			s:    "describe topic orders",
+			want: PermissionProbe{Operation: "describe", ResourceType: "topic", ResourceName: "orders"},
+		},
+		{
+			s:    " READ  Group App ",
+			want: PermissionProbe{Operation: "read", ResourceType: "group", ResourceName: "App"},
+		},
+		{s: "read topic", wantErr: true},
+		{s: "foo topic orders", wantErr: true},
+		{s: "read cluster kafka-cluster", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePermissionProbe(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePermissionProbe(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParsePermissionProbe(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_difference(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want []string
+	}{
+		{a: []string{"a", "b"}, b: []string{"a", "b", "c"}, want: nil},
+		{a: []string{"a", "b", "d"}, b: []string{"b", "c"}, want: []string{"a", "d"}},
+		{a: nil, b: []string{"a"}, want: nil},
+		{a: []string{"a"}, b: nil, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		if got := difference(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("difference(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewServiceClient_permissionsComparison(t *testing.T) {
+	if _, err := NewServiceClient(
+		&sdk.APIClient{}, "admin", "admin-secret", "", "",
+		WithPermissionsComparison(""), WithMetadataAttribute(""),
+	); err == nil {
+		t.Errorf("NewServiceClient() error expected: metadata attribute is not set")
+	}
+}
@@ -0,0 +1,130 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultBackoffInitialInterval = time.Second
+	defaultBackoffMaxInterval     = 30 * time.Second
+
+	// defaultPropagationTimeout limits the wait if the context has no deadline.
+	defaultPropagationTimeout = 5 * time.Minute
+)
+
+// KeyNotReadyError indicates that the API key did not become usable before the context deadline.
+// AWS Secrets Manager retries the rotation step which returned the error.
+type KeyNotReadyError struct {
+	APIKey string
+	Err    error
+}
+
+func (e KeyNotReadyError) Error() string {
+	return `API key "` + e.APIKey + `" is not ready: ` + e.Err.Error()
+}
+
+func (e KeyNotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// errKeyRejected indicates that the resource rejected the new API key, e.g. because the key has not propagated yet.
+var errKeyRejected = errors.New("API key rejected")
+
+// errProbeTransient indicates that the readiness probe was rate limited, or failed because of the server.
+var errProbeTransient = errors.New("transient failure")
+
+// probeStatusError classifies the error of the readiness probe authenticated with the new API key given the response
+// status: the key is rejected upon 401 and 403, the probe failed transiently upon 429 and 5xx.
+func probeStatusError(statusCode int, err error) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %s", errKeyRejected, err)
+	case statusCode == http.StatusTooManyRequests ||
+		statusCode >= http.StatusInternalServerError && statusCode != http.StatusNotImplemented:
+		return fmt.Errorf("%w: %s", errProbeTransient, err)
+	default:
+		return err
+	}
+}
+
+// isRetryableProbeError checks if the readiness probe can be retried, i.e. the new API key was rejected,
+// or the probe failed transiently, or because of the network. The errors caused by the configuration, e.g. the missing
+// secret's attribute, the admin API key rejected by the Confluent Cloud API, or the unknown host, are not retried.
+func isRetryableProbeError(err error) bool {
+	if errors.Is(err, errKeyRejected) || errors.Is(err, errProbeTransient) {
+		return true
+	}
+
+	var errDNS *net.DNSError
+	if errors.As(err, &errDNS) {
+		return !errDNS.IsNotFound
+	}
+	var errAddr *net.AddrError
+	if errors.As(err, &errAddr) {
+		return false
+	}
+
+	var errOp *net.OpError
+	var errNetwork net.Error
+	return errors.As(err, &errOp) || errors.As(err, &errNetwork) && errNetwork.Timeout() ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff defines the exponential backoff, the zero value makes a single attempt.
+type backoff struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+}
+
+func defaultBackoff() backoff {
+	return backoff{initialInterval: defaultBackoffInitialInterval, maxInterval: defaultBackoffMaxInterval}
+}
+
+// waitForKey polls the readiness of the API key until the probe succeeds.
+// The attempts stop when the next attempt would exceed the context deadline, the probe's error is returned right away
+// unless it's retryable, see isRetryableProbeError.
+func (b backoff) waitForKey(ctx context.Context, apiKey string, probe func(ctx context.Context) error) error {
+	if _, ok := ctx.Deadline(); !ok && b.initialInterval > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultPropagationTimeout)
+		defer cancel()
+	}
+
+	interval := b.initialInterval
+	for {
+		err := probe(ctx)
+		if err == nil {
+			return nil
+		}
+		if !isRetryableProbeError(err) {
+			return err
+		}
+
+		if interval <= 0 {
+			return KeyNotReadyError{APIKey: apiKey, Err: err}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < interval {
+			return KeyNotReadyError{APIKey: apiKey, Err: err}
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return KeyNotReadyError{APIKey: apiKey, Err: err}
+		case <-t.C:
+		}
+
+		interval *= 2
+		if interval > b.maxInterval {
+			interval = b.maxInterval
+		}
+	}
+}
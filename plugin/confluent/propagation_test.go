@@ -0,0 +1,173 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_backoff_waitForKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		backoff      backoff
+		timeout      time.Duration
+		readyAfter   int
+		err          error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "happy path: key is ready",
+			backoff:      backoff{initialInterval: time.Millisecond, maxInterval: 2 * time.Millisecond},
+			timeout:      time.Second,
+			readyAfter:   1,
+			wantAttempts: 1,
+			wantErr:      false,
+		},
+		{
+			name:         "happy path: key becomes ready after the third attempt",
+			backoff:      backoff{initialInterval: time.Millisecond, maxInterval: 2 * time.Millisecond},
+			timeout:      time.Second,
+			readyAfter:   3,
+			wantAttempts: 3,
+			wantErr:      false,
+		},
+		{
+			name:         "happy path: probe failed transiently",
+			backoff:      backoff{initialInterval: time.Millisecond, maxInterval: 2 * time.Millisecond},
+			timeout:      time.Second,
+			readyAfter:   2,
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection reset by peer")},
+			wantAttempts: 2,
+			wantErr:      false,
+		},
+		{
+			name:         "unhappy path: permanent error",
+			backoff:      backoff{initialInterval: time.Millisecond, maxInterval: 2 * time.Millisecond},
+			timeout:      time.Second,
+			readyAfter:   3,
+			err:          errors.New(`wrong secret type: "bootstrap_servers" field not found`),
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "unhappy path: unknown host",
+			backoff:      backoff{initialInterval: time.Millisecond, maxInterval: 2 * time.Millisecond},
+			timeout:      time.Second,
+			readyAfter:   3,
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "foo", IsNotFound: true}},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "unhappy path: no polling",
+			backoff:      backoff{},
+			timeout:      time.Second,
+			readyAfter:   2,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "unhappy path: deadline is reached",
+			backoff:      backoff{initialInterval: 20 * time.Millisecond, maxInterval: 40 * time.Millisecond},
+			timeout:      50 * time.Millisecond,
+			readyAfter:   10,
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.TODO(), tt.timeout)
+				defer cancel()
+
+				var attempts int
+				err := tt.backoff.waitForKey(
+					ctx, "foo", func(ctx context.Context) error {
+						attempts++
+						if attempts < tt.readyAfter {
+							if tt.err != nil {
+								return tt.err
+							}
+							return probeStatusError(http.StatusUnauthorized, errors.New("unauthorized"))
+						}
+						return nil
+					},
+				)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("waitForKey() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if attempts != tt.wantAttempts {
+					t.Errorf("waitForKey() attempts = %d, want %d", attempts, tt.wantAttempts)
+				}
+				var e KeyNotReadyError
+				if wantNotReady := tt.wantErr && tt.err == nil; errors.As(err, &e) != wantNotReady ||
+					(wantNotReady && e.APIKey != "foo") {
+					t.Errorf("waitForKey() error = %v, want KeyNotReadyError %v", err, wantNotReady)
+				}
+			},
+		)
+	}
+}
+
+func Test_isRetryableProbeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "new key rejected",
+			err:  probeStatusError(http.StatusForbidden, errors.New("forbidden")),
+			want: true,
+		},
+		{
+			name: "rate limited",
+			err:  probeStatusError(http.StatusTooManyRequests, errors.New("too many requests")),
+			want: true,
+		},
+		{
+			name: "server error",
+			err:  probeStatusError(http.StatusServiceUnavailable, errors.New("service unavailable")),
+			want: true,
+		},
+		{
+			name: "resource not found",
+			err:  probeStatusError(http.StatusNotFound, errors.New("not found")),
+			want: false,
+		},
+		{
+			name: "connection refused",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			want: true,
+		},
+		{
+			name: "unknown host",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Name: "foo", IsNotFound: true}},
+			want: false,
+		},
+		{
+			name: "missing port",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: &net.AddrError{Err: "missing port in address", Addr: "foo"}},
+			want: false,
+		},
+		{
+			name: "admin API key rejected",
+			err:  errors.New("401 Unauthorized"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := isRetryableProbeError(tt.err); got != tt.want {
+					t.Errorf("isRetryableProbeError() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,206 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines the retries of the failed Confluent Cloud API requests.
+// The request is retried if it was rate limited, i.e. the response status is 429, provided that the request's body
+// can be replayed. The idempotent requests are also retried upon the 5xx response statuses and the network errors.
+// The delay follows the header Retry-After if it's set, otherwise it grows exponentially with jitter.
+// The request is not retried if the delay exceeds the context's deadline, e.g. the AWS Lambda's deadline.
+type RetryPolicy struct {
+	// MaxRetries the maximal number of retries, zero disables retries.
+	MaxRetries int
+
+	// InitialInterval the delay before the first retry, the delay doubles with every retry.
+	InitialInterval time.Duration
+
+	// MaxInterval the maximal delay between retries.
+	MaxInterval time.Duration
+}
+
+// DefaultRetryPolicy returns the default RetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: 5, InitialInterval: 500 * time.Millisecond, MaxInterval: 10 * time.Second}
+}
+
+// delay returns the jittered delay before the retry, the attempt starts from zero.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.InitialInterval
+	for i := 0; i < attempt && d < p.MaxInterval; i++ {
+		d *= 2
+	}
+	if d > p.MaxInterval {
+		d = p.MaxInterval
+	}
+	if d <= 0 {
+		return 0
+	}
+	// "equal jitter": the delay is randomised within its upper half
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sleep waits for the delay d, it returns false if the delay exceeds the context's deadline, or the context is done.
+func (p RetryPolicy) sleep(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+type contextKeyNoRetry struct{}
+
+// withoutRetries marks the context of the requests which are retried by the caller, e.g. the creation of the API key,
+// hence the retryTransport does not retry them.
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyNoRetry{}, true)
+}
+
+// retryTransport retries the failed requests following the RetryPolicy.
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if noRetry, _ := ctx.Value(contextKeyNoRetry{}).(bool); noRetry {
+		return t.next.RoundTrip(req)
+	}
+	r := req
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(r)
+		if attempt >= t.policy.MaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		d, ok := t.retryDelay(r, resp, err, attempt)
+		if !ok {
+			return resp, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+			return resp, err
+		}
+
+		next := r
+		if r.Body != nil && r.Body != http.NoBody {
+			body, e := r.GetBody()
+			if e != nil {
+				return resp, err
+			}
+			next = r.Clone(ctx)
+			next.Body = body
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		if !t.policy.sleep(ctx, d) {
+			return nil, ctx.Err()
+		}
+		r = next
+	}
+}
+
+// retryDelay returns the delay before the retry, and false if the request shall not be retried.
+func (t retryTransport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+
+	switch {
+	case err != nil:
+		if !isIdempotent(req.Method) {
+			return 0, false
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented:
+		if !isIdempotent(req.Method) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, true
+		}
+	}
+	return t.policy.delay(attempt), true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses the header Retry-After defined either as the number of seconds, or as the HTTP-date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// isRetryableCreateError checks if the failed creation request can be retried, i.e. it was either rate limited,
+// or failed because of the server, or network error.
+func isRetryableCreateError(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if resp == nil {
+		var errNetwork *url.Error
+		return errors.As(err, &errNetwork)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented
+}
+
+// createRetryDelay returns the delay before the retry of the failed creation request,
+// it follows the header Retry-After if it's set.
+func (p RetryPolicy) createRetryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return d
+		}
+	}
+	return p.delay(attempt)
+}
@@ -0,0 +1,189 @@
+package confluent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_retryTransport_RoundTrip(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, InitialInterval: time.Millisecond, MaxInterval: 2 * time.Millisecond}
+
+	tests := []struct {
+		name         string
+		method       string
+		policy       RetryPolicy
+		statuses     []int
+		retryAfter   string
+		timeout      time.Duration
+		noRetry      bool
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			name:         "happy path: no retry needed",
+			method:       http.MethodGet,
+			policy:       policy,
+			statuses:     []int{http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 1,
+		},
+		{
+			name:         "happy path: idempotent request retried upon 5xx",
+			method:       http.MethodGet,
+			policy:       policy,
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 3,
+		},
+		{
+			name:         "happy path: rate limited creation retried, the body replayed",
+			method:       http.MethodPost,
+			policy:       policy,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusAccepted},
+			retryAfter:   "0",
+			wantStatus:   http.StatusAccepted,
+			wantAttempts: 2,
+		},
+		{
+			name:         "unhappy path: creation is not retried upon 5xx",
+			method:       http.MethodPost,
+			policy:       policy,
+			statuses:     []int{http.StatusInternalServerError, http.StatusAccepted},
+			wantStatus:   http.StatusInternalServerError,
+			wantAttempts: 1,
+		},
+		{
+			name:         "unhappy path: client error is not retried",
+			method:       http.MethodDelete,
+			policy:       policy,
+			statuses:     []int{http.StatusForbidden, http.StatusOK},
+			wantStatus:   http.StatusForbidden,
+			wantAttempts: 1,
+		},
+		{
+			name:         "unhappy path: max retries reached",
+			method:       http.MethodGet,
+			policy:       policy,
+			statuses:     []int{500, 502, 503, 504, 500},
+			wantStatus:   http.StatusGatewayTimeout,
+			wantAttempts: 4,
+		},
+		{
+			name:         "unhappy path: Retry-After exceeds the deadline",
+			method:       http.MethodGet,
+			policy:       policy,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "60",
+			timeout:      time.Second,
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 1,
+		},
+		{
+			name:         "unhappy path: request retried by the caller",
+			method:       http.MethodPost,
+			policy:       policy,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusAccepted},
+			retryAfter:   "0",
+			noRetry:      true,
+			wantStatus:   http.StatusTooManyRequests,
+			wantAttempts: 1,
+		},
+		{
+			name:         "unhappy path: retries disabled",
+			method:       http.MethodGet,
+			policy:       RetryPolicy{},
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var attempts int
+				ts := httptest.NewServer(
+					http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							if b, _ := io.ReadAll(r.Body); r.Method == http.MethodPost && string(b) != "foo" {
+								t.Errorf("request body = %q, want %q", b, "foo")
+							}
+							status := tt.statuses[attempts]
+							attempts++
+							if tt.retryAfter != "" {
+								w.Header().Set("Retry-After", tt.retryAfter)
+							}
+							w.WriteHeader(status)
+						},
+					),
+				)
+				defer ts.Close()
+
+				ctx := context.TODO()
+				if tt.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+					defer cancel()
+				}
+				if tt.noRetry {
+					ctx = withoutRetries(ctx)
+				}
+
+				req, err := http.NewRequestWithContext(ctx, tt.method, ts.URL, strings.NewReader("foo"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c := &http.Client{Transport: retryTransport{next: http.DefaultTransport, policy: tt.policy}}
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = resp.Body.Close()
+
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("RoundTrip() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+				}
+				if attempts != tt.wantAttempts {
+					t.Errorf("RoundTrip() attempts = %d, want %d", attempts, tt.wantAttempts)
+				}
+			},
+		)
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	tests := []struct {
+		v      string
+		want   time.Duration
+		wantOK bool
+	}{
+		{v: "", want: 0, wantOK: false},
+		{v: "5", want: 5 * time.Second, wantOK: true},
+		{v: "-1", want: 0, wantOK: false},
+		{v: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, wantOK: true},
+		{v: "foo", want: 0, wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.v)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.v, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second}
+	for attempt, want := range []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second,
+		time.Second,
+	} {
+		if got := p.delay(attempt); got < want/2 || got > want {
+			t.Errorf("delay(%d) = %v, want within [%v, %v]", attempt, got, want/2, want)
+		}
+	}
+}
@@ -2,16 +2,161 @@ package confluent
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"reflect"
+	"text/template"
+	"time"
 
 	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
 	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
 )
 
+// Option defines the optional configuration of the `ServiceClient`.
+type Option func(*dbClient)
+
+// WithGracePeriod sets the period to keep the previous API key active after it was replaced.
+// By default, the previous API key is deleted right after the new key is staged as AWSCURRENT.
+// Otherwise, it is deleted upon the step setSecret of the next rotation, provided that the current key
+// was created at least the grace period ago. The rotation does not fail if the grace period has not passed,
+// the key is left to the `GarbageCollector` instead.
+func WithGracePeriod(d time.Duration) Option {
+	return func(c *dbClient) {
+		c.gracePeriod = d
+	}
+}
+
+// WithBootstrapServersAttribute sets the attribute of the `SecretUser` with the Kafka cluster's bootstrap servers.
+// The bootstrap servers are used to test the API key scoped to the Kafka cluster, "bootstrap_servers" by default.
+func WithBootstrapServersAttribute(attribute string) Option {
+	return func(c *dbClient) {
+		c.attributeBootstrapServers = attribute
+	}
+}
+
+// WithSchemaRegistryURLAttribute sets the attribute of the `SecretUser` with the Schema Registry's endpoint.
+// The endpoint is used to test the API key scoped to the Schema Registry, "schema_registry_url" by default.
+func WithSchemaRegistryURLAttribute(attribute string) Option {
+	return func(c *dbClient) {
+		c.attributeSchemaRegistryURL = attribute
+	}
+}
+
+// WithKeysAttribute sets the attribute of the `SecretUser` with the list of API keys to rotate at once.
+// Every element of the list is an object with the API key-secret pair and the resource-specific attributes,
+// the paths of the attributes are relative to the element. By default, the secret holds a single API key.
+func WithKeysAttribute(attribute string) Option {
+	return func(c *dbClient) {
+		c.attributeKeys = attribute
+	}
+}
+
+// WithMetadataAttribute sets the attribute of the `SecretUser` to store the API key's details, see `KeyMetadata`.
+// The details are used to recreate the API key if it was deleted, "api_key_metadata" by default.
+// The path of the attribute is relative to the element of the list of keys, see WithKeysAttribute.
+func WithMetadataAttribute(attribute string) Option {
+	return func(c *dbClient) {
+		c.attributeMetadata = attribute
+	}
+}
+
+// WithKafkaTLSConfig sets the TLS configuration to connect to the Kafka cluster, nil disables TLS.
+func WithKafkaTLSConfig(cfg *tls.Config) Option {
+	return func(c *dbClient) {
+		c.kafkaTLSConfig = cfg
+	}
+}
+
+// WithHTTPClient sets the http client to connect to the Schema Registry.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *dbClient) {
+		c.httpClient = client
+	}
+}
+
+// WithDisplayNameTemplate sets the text/template of the new API key's display name, see `KeyTemplateData`
+// for the available variables, e.g. "{{.SecretName}} {{.VersionID}}".
+// By default, the display name of the current API key is copied.
+func WithDisplayNameTemplate(tmpl string) Option {
+	return func(c *dbClient) {
+		c.displayNameTemplate = c.parseTemplate("display_name", tmpl)
+	}
+}
+
+// WithDescriptionTemplate sets the text/template of the new API key's description, see `KeyTemplateData`
+// for the available variables, e.g. "rotated by {{.SecretName}} at {{.Timestamp}}".
+// By default, the description of the current API key is copied. The secret's KeyDescriptionMarker is appended in any
+// case.
+func WithDescriptionTemplate(tmpl string) Option {
+	return func(c *dbClient) {
+		c.descriptionTemplate = c.parseTemplate("description", tmpl)
+	}
+}
+
+// WithPermissionsComparison activates the comparison of the permissions of the new API key's owner with the
+// baseline of the previous rotation upon the step testSecret. The baseline is the permissions of the current key's
+// owner captured upon the step createSecret of every rotation, it's stored in the pending secret as part of
+// `KeyMetadata`, hence it's stored with the secret's version staged as AWSCURRENT once the rotation finishes.
+// The baseline of the previous rotation is carried to the pending secret upon the step createSecret, the baseline
+// captured by the rotation itself is used if the secret has none, e.g. upon the first rotation. The step testSecret
+// fails if the baseline is not found, or any permission of the baseline is missing. The permissions include:
+//   - the owner's role bindings matching the crnPattern, e.g. "crn://confluent.cloud/organization=xxx/*",
+//     they are listed using the Confluent Cloud IAM API if crnPattern is not empty;
+//   - the owner's ACLs of the Kafka cluster the key is scoped to, they are listed using the Kafka REST API if the
+//     secret includes the REST endpoint, see WithRESTEndpointAttribute.
+//
+// Both are listed using the admin API key-secret pair. Note that the metadata attribute must be set,
+// see WithMetadataAttribute.
+func WithPermissionsComparison(crnPattern string) Option {
+	return func(c *dbClient) {
+		c.comparePermissions = true
+		c.roleBindingsCRNPattern = crnPattern
+	}
+}
+
+// WithRESTEndpointAttribute sets the attribute of the `SecretUser` with the Kafka cluster's REST endpoint.
+// The endpoint is used to list the ACLs of the API key's owner, "rest_endpoint" by default.
+func WithRESTEndpointAttribute(attribute string) Option {
+	return func(c *dbClient) {
+		c.attributeRESTEndpoint = attribute
+	}
+}
+
+// WithPermissionProbes sets the operations which must be permitted to the new API key scoped to Kafka cluster.
+// The probes run upon the step testSecret, the step fails if any operation is denied, see ParsePermissionProbe.
+func WithPermissionProbes(probes ...PermissionProbe) Option {
+	return func(c *dbClient) {
+		c.permissionProbes = probes
+	}
+}
+
+// WithRetryPolicy sets the policy to retry the creation of the new API key, DefaultRetryPolicy by default.
+// Note that the other requests are retried by the API client, see `APIClientConfig`.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *dbClient) {
+		c.retry = p
+	}
+}
+
+// WithBackoff sets the exponential backoff to poll the readiness of the new API key,
+// the interval doubles starting from initialInterval and is capped by maxInterval.
+// The zero initialInterval disables polling, i.e. the readiness is checked once.
+func WithBackoff(initialInterval, maxInterval time.Duration) Option {
+	return func(c *dbClient) {
+		c.backoff = backoff{initialInterval: initialInterval, maxInterval: maxInterval}
+	}
+}
+
 // NewServiceClient initiates the `ServiceClient` to rotate credentials for Confluent Kafka user.
+// The attributes of the `SecretUser` are addressed by the paths: either the name of the top-level attribute, e.g. "user",
+// or the JSON pointer, e.g. "/kafka/sasl/username". The API key and secret are addressed by attributeKey and
+// attributeSecret, "user" and "password" by default.
 func NewServiceClient(
-	client *sdk.APIClient, apiKey, apiSecret, attributeKey, attributeSecret string,
+	client *sdk.APIClient, apiKey, apiSecret, attributeKey, attributeSecret string, opts ...Option,
 ) (lambda.ServiceClient, error) {
 	if apiKey == "" || apiSecret == "" {
 		return nil, errors.New("confluent API key-secret pair must be provided")
@@ -22,28 +167,77 @@ func NewServiceClient(
 	if attributeSecret == "" {
 		attributeSecret = "password"
 	}
-	return &dbClient{
-		c:               client,
-		attributeKey:    attributeKey,
-		attributeSecret: attributeSecret,
-		apiKey:          apiKey,
-		apiSecret:       apiSecret,
-	}, nil
+	c := &dbClient{
+		c:                          client,
+		attributeKey:               attributeKey,
+		attributeSecret:            attributeSecret,
+		attributeMetadata:          "api_key_metadata",
+		attributeBootstrapServers:  "bootstrap_servers",
+		attributeSchemaRegistryURL: "schema_registry_url",
+		attributeRESTEndpoint:      "rest_endpoint",
+		apiKey:                     apiKey,
+		apiSecret:                  apiSecret,
+		kafkaTLSConfig:             &tls.Config{MinVersion: tls.VersionTLS12},
+		httpClient:                 &http.Client{Timeout: defaultConnectionTimeout},
+		backoff:                    defaultBackoff(),
+		retry:                      DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.errOptions != nil {
+		return nil, c.errOptions
+	}
+	if c.comparePermissions && c.attributeMetadata == "" {
+		return nil, errors.New("metadata attribute must be set to compare the permissions")
+	}
+	return c, nil
 }
 
 type dbClient struct {
-	apiKey          string
-	apiSecret       string
-	attributeKey    string
-	attributeSecret string
-	c               *sdk.APIClient
+	apiKey                     string
+	apiSecret                  string
+	attributeKey               string
+	attributeSecret            string
+	attributeKeys              string
+	attributeMetadata          string
+	attributeBootstrapServers  string
+	attributeSchemaRegistryURL string
+	attributeRESTEndpoint      string
+	comparePermissions         bool
+	roleBindingsCRNPattern     string
+	permissionProbes           []PermissionProbe
+	gracePeriod                time.Duration
+	kafkaTLSConfig             *tls.Config
+	httpClient                 *http.Client
+	backoff                    backoff
+	retry                      RetryPolicy
+	displayNameTemplate        *template.Template
+	descriptionTemplate        *template.Template
+	errOptions                 error
+	c                          *sdk.APIClient
+}
+
+// parseTemplate parses the template, the error is reported by NewServiceClient.
+func (c *dbClient) parseTemplate(name, tmpl string) *template.Template {
+	t, err := template.New(name).Parse(tmpl)
+	if err != nil {
+		c.errOptions = errors.New("wrong " + name + " template: " + err.Error())
+		return nil
+	}
+	return t
 }
 
 func (c dbClient) wrapContext(ctx context.Context) context.Context {
+	return withBasicAuth(ctx, c.apiKey, c.apiSecret)
+}
+
+// withBasicAuth sets the API key-secret pair to authenticate the Confluent API requests.
+func withBasicAuth(ctx context.Context, apiKey, apiSecret string) context.Context {
 	return context.WithValue(
 		ctx, sdk.ContextBasicAuth, sdk.BasicAuth{
-			UserName: c.apiKey,
-			Password: c.apiSecret,
+			UserName: apiKey,
+			Password: apiSecret,
 		},
 	)
 }
@@ -51,115 +245,506 @@ func (c dbClient) wrapContext(ctx context.Context) context.Context {
 func (c dbClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
 	ctx = c.wrapContext(ctx)
 
-	if err := c.Test(ctx, secretCurrent); err != nil {
+	if err := c.validate(secretCurrent); err != nil {
 		return errors.New("current secret error: " + err.Error())
 	}
 
-	if err := c.Test(ctx, secretPending); err != nil {
+	if err := c.validate(secretPending); err != nil {
 		return errors.New("pending secret error: " + err.Error())
 	}
 
 	current := *(secretCurrent.(*SecretUser))
 	pending := *(secretPending.(*SecretUser))
 
-	if current[c.attributeKey] == pending[c.attributeKey] {
-		return errors.New(`API key "` + c.attributeKey + `" shall be modified`)
+	currentEntries, _ := c.entries(current)
+	pendingEntries, _ := c.entries(pending)
+	if len(currentEntries) != len(pendingEntries) {
+		return errors.New("number of API keys in the current and pending secrets shall match")
 	}
 
-	if current[c.attributeSecret] == pending[c.attributeSecret] {
-		return errors.New(`API secret "` + c.attributeSecret + `" shall be modified`)
+	for i := range currentEntries {
+		if c.apiKeyID(currentEntries[i]) == c.apiKeyID(pendingEntries[i]) {
+			return errors.New(`API key "` + c.attributeKey + `" shall be modified`)
+		}
+
+		if c.apiKeySecret(currentEntries[i]) == c.apiKeySecret(pendingEntries[i]) {
+			return errors.New(`API secret "` + c.attributeSecret + `" shall be modified`)
+		}
 	}
 
 	if err := c.additionalAttributesMatchError(current, pending); err != nil {
 		return err
 	}
 
-	return deleteKey(ctx, c.c.APIKeysIamV2Api, current[c.attributeKey])
+	if secretPrevious == nil {
+		return nil
+	}
+
+	previous, ok := secretPrevious.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the previous secret")
+	}
+
+	return c.deletePreviousKeys(ctx, current, pending, *previous)
 }
 
-func (c dbClient) additionalAttributesMatchError(current SecretUser, pending SecretUser) error {
-	currentID := current[c.attributeKey]
-	currentSecret := current[c.attributeSecret]
-	pendingID := pending[c.attributeKey]
-	pendingSecret := pending[c.attributeSecret]
+// deletePreviousKeys deletes the API keys of the secret's version staged as AWSPREVIOUS
+// if the grace period passed since the current keys were created. Otherwise, the key is skipped
+// to be deleted by the `GarbageCollector` once it's referenced by none of the secret's versions.
+func (c dbClient) deletePreviousKeys(ctx context.Context, current, pending, previous SecretUser) error {
+	previousEntries, err := c.entries(previous)
+	if err != nil {
+		// the previous version of a different layout is not managed by the client
+		return nil
+	}
+
+	currentEntries, _ := c.entries(current)
+	pendingEntries, _ := c.entries(pending)
+
+	active := map[string]struct{}{}
+	for _, e := range append(currentEntries, pendingEntries...) {
+		active[c.apiKeyID(e)] = struct{}{}
+	}
+
+	for i, e := range previousEntries {
+		id := c.apiKeyID(e)
+		if _, ok := active[id]; ok || id == "" {
+			continue
+		}
+
+		if c.gracePeriod > 0 && i < len(currentEntries) {
+			currentKey, err := readKey(ctx, c.c.APIKeysIamV2Api, c.apiKeyID(currentEntries[i]))
+			if err != nil {
+				return err
+			}
+			if createdAt, ok := currentKey.Metadata.GetCreatedAtOk(); ok &&
+				time.Since(*createdAt) < c.gracePeriod {
+				log.Println(
+					`[INFO] grace period of the previous API key "` + id + `" has not passed, it ends at ` +
+						createdAt.Add(c.gracePeriod).Format(time.RFC3339) +
+						`, the key's deletion is deferred to the garbage collector`,
+				)
+				continue
+			}
+		}
+
+		if err := deleteKey(ctx, c.c.APIKeysIamV2Api, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finalize deletes the API keys of the secret's version staged as AWSPREVIOUS unless the grace period is set.
+func (c dbClient) Finalize(ctx context.Context, secretCurrent, secretPrevious any) error {
+	if c.gracePeriod > 0 || secretPrevious == nil {
+		return nil
+	}
+
+	current, ok := secretCurrent.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
 
-	current[c.attributeKey] = ""
-	current[c.attributeSecret] = ""
-	pending[c.attributeKey] = ""
-	pending[c.attributeSecret] = ""
+	previous, ok := secretPrevious.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the previous secret")
+	}
 
-	additionalAttrMatch := reflect.DeepEqual(current, pending)
+	return c.deletePreviousKeys(c.wrapContext(ctx), *current, *current, *previous)
+}
 
-	current[c.attributeKey] = currentID
-	current[c.attributeSecret] = currentSecret
-	pending[c.attributeKey] = pendingID
-	pending[c.attributeSecret] = pendingSecret
+// additionalAttributesMatchError compares the documents of the current and pending secrets
+// except for the API key-secret pairs and the keys' metadata.
+func (c dbClient) additionalAttributesMatchError(current SecretUser, pending SecretUser) error {
+	current, pending = current.clone(), pending.clone()
+	for _, s := range []SecretUser{current, pending} {
+		entries, err := c.entries(s)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := e.set(c.attributeKey, ""); err != nil {
+				return err
+			}
+			if err := e.set(c.attributeSecret, ""); err != nil {
+				return err
+			}
+			if c.attributeMetadata != "" {
+				if err := e.set(c.attributeMetadata, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
 
-	if !additionalAttrMatch {
+	if !reflect.DeepEqual(current, pending) {
 		return errors.New("additional attributes of the current and pending secrets shall match")
 	}
 	return nil
 }
 
 func deleteKey(ctx context.Context, c sdk.APIKeysIamV2Api, id string) error {
-	_, err := c.DeleteIamV2ApiKey(ctx, id).Execute()
-	return err
+	r, err := c.DeleteIamV2ApiKey(ctx, id).Execute()
+	if err != nil && !isNotFound(r) {
+		return err
+	}
+	return nil
 }
 
+// isNotFound checks if the Confluent API responded that the requested object does not exist.
+func isNotFound(r *http.Response) bool {
+	return r != nil && r.StatusCode == http.StatusNotFound
+}
+
+// Test authenticates with every API key of the secret against the resource the key is scoped to:
+// the Confluent Cloud API for Cloud keys, the Kafka cluster for Kafka cluster keys,
+// and the Schema Registry for Schema Registry keys.
+// The attempts are repeated with the backoff until the key becomes usable, or the context deadline is reached.
 func (c dbClient) Test(ctx context.Context, secret any) error {
-	ctx = c.wrapContext(ctx)
+	if err := c.validate(secret); err != nil {
+		return err
+	}
+
+	entries, _ := c.entries(*(secret.(*SecretUser)))
+	for _, e := range entries {
+		if err := c.waitForKey(ctx, e); err != nil {
+			return err
+		}
+		if err := c.verifyPermissions(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForKey polls the readiness of the API key from the secret's entry.
+func (c dbClient) waitForKey(ctx context.Context, e SecretUser) error {
+	return c.backoff.waitForKey(
+		ctx, c.apiKeyID(e), func(ctx context.Context) error {
+			return c.testConnection(ctx, e)
+		},
+	)
+}
+
+// testConnection reads the API key's details and authenticates with the key against its resource.
+func (c dbClient) testConnection(ctx context.Context, e SecretUser) error {
+	key, keySecret := c.apiKeyID(e), c.apiKeySecret(e)
+
+	k, err := readKey(c.wrapContext(ctx), c.c.APIKeysIamV2Api, key)
+	if err != nil {
+		return err
+	}
+
+	switch k.GetSpec().Resource.GetKind() {
+	case resourceKindKafka:
+		bootstrapServers, _ := e.getString(c.attributeBootstrapServers)
+		return c.testKafkaConnection(ctx, bootstrapServers, key, keySecret)
+	case resourceKindSchemaRegistry:
+		endpoint, _ := e.getString(c.attributeSchemaRegistryURL)
+		return c.testSchemaRegistryConnection(ctx, endpoint, key, keySecret)
+	case resourceKindCloud, "":
+		return c.testCloudConnection(ctx, key, keySecret)
+	default:
+		log.Println(
+			`[WARN] API key "` + key + `" is scoped to the resource of unsupported kind "` +
+				k.GetSpec().Resource.GetKind() + `", connection test skipped`,
+		)
+		return nil
+	}
+}
+
+// validate checks if every entry of the secret contains the API key-secret pair.
+func (c dbClient) validate(secret any) error {
 	s, ok := secret.(*SecretUser)
 	if !ok {
 		return errors.New("wrong secret type")
 	}
-	if _, ok := (*s)[c.attributeKey]; !ok {
-		return errors.New(`wrong secret type: "` + c.attributeKey + `" field not found`)
+	entries, err := c.entries(*s)
+	if err != nil {
+		return err
 	}
-	if _, ok := (*s)[c.attributeSecret]; !ok {
-		return errors.New(`wrong secret type: "` + c.attributeSecret + `" field not found`)
+	for _, e := range entries {
+		if _, ok := e.getString(c.attributeKey); !ok {
+			return errors.New(`wrong secret type: "` + c.attributeKey + `" string field not found`)
+		}
+		if _, ok := e.getString(c.attributeSecret); !ok {
+			return errors.New(`wrong secret type: "` + c.attributeSecret + `" string field not found`)
+		}
 	}
 	return nil
 }
 
-func (c dbClient) Create(ctx context.Context, secret any) error {
-	ctx = c.wrapContext(ctx)
+// entries returns the entries of the secret with the API keys.
+// The secret is the single entry unless the keys attribute is set, see `WithKeysAttribute`.
+// The entries share the content with the secret.
+func (c dbClient) entries(s SecretUser) ([]SecretUser, error) {
+	if c.attributeKeys == "" {
+		return []SecretUser{s}, nil
+	}
 
-	sT, ok := secret.(*SecretUser)
+	v, ok := s.get(c.attributeKeys)
 	if !ok {
-		return errors.New("wrong secret type")
+		return nil, errors.New(`wrong secret type: "` + c.attributeKeys + `" field not found`)
+	}
+
+	list, ok := v.([]any)
+	if !ok || len(list) == 0 {
+		return nil, errors.New(`wrong secret type: "` + c.attributeKeys + `" shall be a non-empty list of objects`)
+	}
+
+	o := make([]SecretUser, len(list))
+	for i, el := range list {
+		m, ok := el.(map[string]any)
+		if !ok {
+			return nil, errors.New(`wrong secret type: "` + c.attributeKeys + `" shall be a non-empty list of objects`)
+		}
+		o[i] = m
 	}
+	return o, nil
+}
+
+// apiKeyID returns the API key from the secret's entry.
+func (c dbClient) apiKeyID(e SecretUser) string {
+	o, _ := e.getString(c.attributeKey)
+	return o
+}
 
-	s := *sT
-	id, ok := s[c.attributeKey]
+// apiKeySecret returns the API secret from the secret's entry.
+func (c dbClient) apiKeySecret(e SecretUser) string {
+	o, _ := e.getString(c.attributeSecret)
+	return o
+}
+
+// Create creates the new API keys for the owners and resources of the current keys,
+// and waits for the new keys to become usable.
+// The keys created upon the call are deleted if any step fails, i.e. the keys are rotated all at once.
+func (c dbClient) Create(ctx context.Context, secret any) (err error) {
+	s, ok := secret.(*SecretUser)
 	if !ok {
-		return errors.New(`wrong secret type: "` + c.attributeKey + `" field not found`)
+		return errors.New("wrong secret type")
 	}
 
-	currentKey, err := readKey(ctx, c.c.APIKeysIamV2Api, id)
+	entries, err := c.entries(*s)
 	if err != nil {
 		return err
 	}
 
-	spec := currentKey.GetSpec()
-	spec.SetSecret("")
+	for _, e := range entries {
+		if _, ok := e.getString(c.attributeKey); !ok {
+			return errors.New(`wrong secret type: "` + c.attributeKey + `" string field not found`)
+		}
+	}
+
+	ctxAuth := c.wrapContext(ctx)
 
-	createdKey, err := createKey(ctx, c.c.APIKeysIamV2Api, &spec)
-	if err != nil {
-		return err
+	var created []*sdk.IamV2ApiKey
+	defer func() {
+		if err != nil {
+			for _, k := range created {
+				if e := deleteKey(ctxAuth, c.c.APIKeysIamV2Api, k.GetId()); e != nil {
+					err = errors.New(err.Error() + `; API key "` + k.GetId() + `" cleanup error: ` + e.Error())
+				}
+			}
+		}
+	}()
+
+	now := time.Now()
+
+	specs := make([]sdk.IamV2ApiKeySpec, len(entries))
+	baselines := make([][]string, len(entries))
+	previousBaselines := make([][]string, len(entries))
+	for i, e := range entries {
+		if specs[i], err = c.currentKeySpec(ctxAuth, e); err != nil {
+			return err
+		}
+
+		// the permissions of the current key's owner are captured upon every rotation before the new key is created,
+		// the baseline of the previous rotation is carried to be compared with upon the step testSecret
+		if c.comparePermissions {
+			if meta, ok := c.metadata(e); ok {
+				previousBaselines[i] = meta.Permissions
+			}
+			if baselines[i], err = c.permissions(ctx, e, specs[i]); err != nil {
+				return err
+			}
+		}
+
+		if err := c.applyKeyTemplates(ctx, &specs[i], now); err != nil {
+			return err
+		}
+
+		createdKey, err := createKey(ctxAuth, c.c.APIKeysIamV2Api, &specs[i], c.retry)
+		if err != nil {
+			return err
+		}
+		created = append(created, createdKey)
+	}
+
+	for i, e := range entries {
+		if err := e.set(c.attributeKey, created[i].GetId()); err != nil {
+			return err
+		}
+		if err := e.set(c.attributeSecret, created[i].Spec.GetSecret()); err != nil {
+			return err
+		}
 	}
 
-	s[c.attributeKey] = createdKey.GetId()
+	for _, e := range entries {
+		if err := c.waitForKey(ctx, e); err != nil {
+			return err
+		}
+	}
 
-	sp, _ := createdKey.GetSpecOk()
-	s[c.attributeSecret] = sp.GetSecret()
+	for i, e := range entries {
+		if err := c.setMetadata(e, specs[i], created[i], now, baselines[i], previousBaselines[i]); err != nil {
+			return err
+		}
+	}
 
-	secret = &s
 	return nil
 }
 
-func createKey(ctx context.Context, c sdk.APIKeysIamV2Api, spec *sdk.IamV2ApiKeySpec) (*sdk.IamV2ApiKey, error) {
-	r := c.CreateIamV2ApiKey(ctx).IamV2ApiKey(sdk.IamV2ApiKey{Spec: spec})
-	key, _, err := r.Execute()
+// currentKeySpec returns the spec to create the new API key based on the current key.
+// The spec is rebuilt from the metadata stored in the secret's entry if the current key does not exist.
+func (c dbClient) currentKeySpec(ctx context.Context, e SecretUser) (sdk.IamV2ApiKeySpec, error) {
+	id := c.apiKeyID(e)
+
+	currentKey, err := readKey(ctx, c.c.APIKeysIamV2Api, id)
+	switch {
+	case err == nil:
+		spec := currentKey.GetSpec()
+		spec.SetSecret("")
+		return spec, nil
+	case !errors.Is(err, errKeyNotFound):
+		return sdk.IamV2ApiKeySpec{}, err
+	}
+
+	meta, ok := c.metadata(e)
+	if !ok || meta.Owner == nil {
+		return sdk.IamV2ApiKeySpec{}, errors.New(
+			`API key "` + id + `" not found, and it cannot be recreated: "` + c.attributeMetadata +
+				`" field with the owner not found`,
+		)
+	}
+
+	log.Println(`[WARN] API key "` + id + `" not found, the new key is created using the metadata stored in the secret`)
+
+	spec := sdk.IamV2ApiKeySpec{Owner: meta.Owner, Resource: meta.Resource}
+	if meta.DisplayName != "" {
+		spec.SetDisplayName(meta.DisplayName)
+	}
+	if meta.Description != "" {
+		spec.SetDescription(meta.Description)
+	}
+	return spec, nil
+}
+
+// metadata reads the API key's metadata from the secret's entry.
+func (c dbClient) metadata(e SecretUser) (KeyMetadata, bool) {
+	var o KeyMetadata
+	if c.attributeMetadata == "" {
+		return o, false
+	}
+
+	v, ok := e.get(c.attributeMetadata)
+	if !ok {
+		return o, false
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return o, false
+	}
+	if err := json.Unmarshal(b, &o); err != nil {
+		return o, false
+	}
+	return o, true
+}
+
+// setMetadata writes the new API key's metadata to the secret's entry.
+// The creation time defaults to createdAt if the API does not return it.
+func (c dbClient) setMetadata(
+	e SecretUser, spec sdk.IamV2ApiKeySpec, key *sdk.IamV2ApiKey, createdAt time.Time,
+	permissions, previousPermissions []string,
+) error {
+	if c.attributeMetadata == "" {
+		return nil
+	}
+
+	meta := key.GetMetadata()
+	if v, ok := meta.GetCreatedAtOk(); ok {
+		createdAt = *v
+	}
+	createdAt = createdAt.UTC()
+
+	b, err := json.Marshal(
+		KeyMetadata{
+			ID:                  key.GetId(),
+			DisplayName:         spec.GetDisplayName(),
+			Description:         spec.GetDescription(),
+			Owner:               spec.Owner,
+			Resource:            spec.Resource,
+			CreatedAt:           &createdAt,
+			Permissions:         permissions,
+			PreviousPermissions: previousPermissions,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	var o SecretUser
+	if err := json.Unmarshal(b, &o); err != nil {
+		return err
+	}
+	return e.set(c.attributeMetadata, map[string]any(o))
+}
+
+// createKey creates the API key, the description is marked with the KeyDescriptionMarker of the rotated secret.
+// The creation is retried following the retry policy if the request was rate limited, or failed because of the server,
+// or network error. The request is retried by createKey only, not by the http transport, see withoutRetries.
+// The key's secret is only returned upon creation, hence the keys created by the failed request are deleted:
+// the keys of the same owner and resource with the same display name and description which were not listed right
+// before the request. The creation is not retried if the key's owner is not set.
+func createKey(
+	ctx context.Context, c sdk.APIKeysIamV2Api, spec *sdk.IamV2ApiKeySpec, retry RetryPolicy,
+) (*sdk.IamV2ApiKey, error) {
+	event, _ := lambda.EventFromContext(ctx)
+	spec.SetDescription(withDescriptionMarker(spec.GetDescription(), event.SecretARN))
+
+	retryable := retry.MaxRetries > 0 && spec.Owner.GetId() != ""
+
+	var (
+		key  sdk.IamV2ApiKey
+		resp *http.Response
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		var existing map[string]struct{}
+		if retryable {
+			if existing, err = listKeyIDs(ctx, c, *spec); err != nil {
+				return nil, err
+			}
+		}
+
+		key, resp, err = c.CreateIamV2ApiKey(withoutRetries(ctx)).IamV2ApiKey(sdk.IamV2ApiKey{Spec: spec}).Execute()
+		if err == nil || !retryable || !isRetryableCreateError(ctx, resp, err) {
+			break
+		}
+
+		// the rate limited request is rejected before the key is created
+		if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+			if e := deleteCreatedKeys(ctx, c, *spec, existing); e != nil {
+				return nil, errors.New(err.Error() + "; cleanup error: " + e.Error())
+			}
+		}
+		if attempt >= retry.MaxRetries || !retry.sleep(ctx, retry.createRetryDelay(resp, attempt)) {
+			break
+		}
+		log.Println("[WARN] API key creation failed, retry: " + err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -176,10 +761,58 @@ func createKey(ctx context.Context, c sdk.APIKeysIamV2Api, spec *sdk.IamV2ApiKey
 	return &key, err
 }
 
+// listKeyIDs lists the IDs of the API keys of the spec's owner and resource.
+func listKeyIDs(ctx context.Context, c sdk.APIKeysIamV2Api, spec sdk.IamV2ApiKeySpec) (map[string]struct{}, error) {
+	keys, err := listKeys(ctx, c, spec.Owner.GetId(), spec.Resource.GetId())
+	if err != nil {
+		return nil, err
+	}
+	o := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		o[k.GetId()] = struct{}{}
+	}
+	return o, nil
+}
+
+// deleteCreatedKeys deletes the keys which were created using the spec, but not listed in existing.
+// The keys of the same owner and resource created using other specs, e.g. upon rotation of other secrets,
+// are kept because their description carries the marker of the other secret.
+func deleteCreatedKeys(
+	ctx context.Context, c sdk.APIKeysIamV2Api, spec sdk.IamV2ApiKeySpec, existing map[string]struct{},
+) error {
+	keys, err := listKeys(ctx, c, spec.Owner.GetId(), spec.Resource.GetId())
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		id := k.GetId()
+		if _, ok := existing[id]; ok {
+			continue
+		}
+		kSpec := k.GetSpec()
+		if kSpec.GetDescription() != spec.GetDescription() || kSpec.GetDisplayName() != spec.GetDisplayName() {
+			continue
+		}
+		if err := deleteKey(ctx, c, id); err != nil {
+			return err
+		}
+		log.Println(`[INFO] API key "` + id + `" created by the failed request deleted`)
+	}
+
+	return nil
+}
+
+// errKeyNotFound indicates that the API key does not exist.
+var errKeyNotFound = errors.New("API key not found")
+
 func readKey(ctx context.Context, c sdk.APIKeysIamV2Api, id string) (*sdk.IamV2ApiKey, error) {
 	r := c.GetIamV2ApiKey(ctx, id)
-	key, _, err := r.Execute()
+	key, resp, err := r.Execute()
 	if err != nil {
+		if isNotFound(resp) {
+			return nil, fmt.Errorf("%w: %s", errKeyNotFound, id)
+		}
 		return nil, err
 	}
 	if _, ok := key.GetIdOk(); !ok {
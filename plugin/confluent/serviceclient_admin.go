@@ -0,0 +1,144 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+// NewAdminServiceClient initiates the `ServiceClient` to rotate the Confluent Cloud API key stored as `SecretAdmin`.
+// The current key is used to authenticate the requests to create the new key and to delete the current key
+// once it's moved to the stage AWSPREVIOUS, the new key is used to authenticate the requests upon the step testSecret.
+func NewAdminServiceClient(client *sdk.APIClient) (lambda.ServiceClient, error) {
+	if client == nil {
+		return nil, errors.New("confluent API client must be provided")
+	}
+	return &adminClient{c: client, backoff: defaultBackoff(), retry: DefaultRetryPolicy()}, nil
+}
+
+type adminClient struct {
+	c       *sdk.APIClient
+	backoff backoff
+	retry   RetryPolicy
+}
+
+// Create creates the new API key of the current key's owner and waits for it to become usable.
+// The new key is deleted if it does not become usable.
+func (c adminClient) Create(ctx context.Context, secret any) (err error) {
+	s, ok := secret.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	ctx = withBasicAuth(ctx, s.APIKey, s.APISecret)
+
+	currentKey, err := readKey(ctx, c.c.APIKeysIamV2Api, s.APIKey)
+	if err != nil {
+		return err
+	}
+
+	spec := currentKey.GetSpec()
+	if _, ok := spec.GetOwnerOk(); !ok {
+		return errors.New("existing API Key is corrupt: owner is not set")
+	}
+	spec.SetSecret("")
+
+	createdKey, err := createKey(ctx, c.c.APIKeysIamV2Api, &spec, c.retry)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if e := deleteKey(ctx, c.c.APIKeysIamV2Api, createdKey.GetId()); e != nil {
+				err = errors.New(err.Error() + `; API key "` + createdKey.GetId() + `" cleanup error: ` + e.Error())
+			}
+		}
+	}()
+
+	pending := SecretAdmin{APIKey: createdKey.GetId(), APISecret: createdKey.Spec.GetSecret()}
+	if err := c.waitForKey(ctx, &pending); err != nil {
+		return err
+	}
+
+	*s = pending
+
+	return nil
+}
+
+func (c adminClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	current, ok := secretCurrent.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
+
+	pending, ok := secretPending.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if current.APIKey == pending.APIKey {
+		return errors.New("API key shall be modified")
+	}
+
+	ctx = withBasicAuth(ctx, current.APIKey, current.APISecret)
+
+	currentKey, err := readKey(ctx, c.c.APIKeysIamV2Api, current.APIKey)
+	if err != nil {
+		return errors.New("current secret error: " + err.Error())
+	}
+
+	pendingKey, err := readKey(ctx, c.c.APIKeysIamV2Api, pending.APIKey)
+	if err != nil {
+		return errors.New("pending secret error: " + err.Error())
+	}
+
+	if currentKey.GetSpec().Owner.GetId() != pendingKey.GetSpec().Owner.GetId() {
+		return errors.New("owners of the current and pending API keys shall match")
+	}
+
+	return nil
+}
+
+func (c adminClient) Test(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	return c.waitForKey(ctx, s)
+}
+
+// waitForKey polls the readiness of the API key by authenticating with it to read its own details.
+func (c adminClient) waitForKey(ctx context.Context, s *SecretAdmin) error {
+	return c.backoff.waitForKey(
+		ctx, s.APIKey, func(ctx context.Context) error {
+			return probeKey(ctx, c.c.APIKeysIamV2Api, s.APIKey, s.APISecret)
+		},
+	)
+}
+
+// Finalize deletes the API key of the secret's version staged as AWSPREVIOUS.
+func (c adminClient) Finalize(ctx context.Context, secretCurrent, secretPrevious any) error {
+	current, ok := secretCurrent.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
+
+	if secretPrevious == nil {
+		return nil
+	}
+
+	previous, ok := secretPrevious.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the previous secret")
+	}
+
+	if previous.APIKey == "" || previous.APIKey == current.APIKey {
+		return nil
+	}
+
+	return deleteKey(withBasicAuth(ctx, current.APIKey, current.APISecret), c.c.APIKeysIamV2Api, previous.APIKey)
+}
@@ -0,0 +1,350 @@
+package confluent
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+func newMockAdminKey(id, secret, owner string) sdk.IamV2ApiKey {
+	return sdk.IamV2ApiKey{
+		Id: optString(id),
+		Spec: &sdk.IamV2ApiKeySpec{
+			Secret:      optString(secret),
+			DisplayName: optString("admin"),
+			Owner:       &sdk.ObjectReference{Id: owner},
+		},
+	}
+}
+
+func Test_adminClient_Create(t *testing.T) {
+	tests := []struct {
+		name         string
+		keys         map[string]sdk.IamV2ApiKey
+		secret       any
+		want         any
+		wantAuthKeys []string
+		wantErr      bool
+	}{
+		{
+			name:         "happy path",
+			keys:         map[string]sdk.IamV2ApiKey{"foo": newMockAdminKey("foo", "bar", "sa-1")},
+			secret:       &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			want:         &SecretAdmin{APIKey: mockIDNew, APISecret: mockSecretNew},
+			wantAuthKeys: []string{"foo", "foo", "foo", mockIDNew},
+			wantErr:      false,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			keys:    map[string]sdk.IamV2ApiKey{},
+			secret:  SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: current key not found",
+			keys:    map[string]sdk.IamV2ApiKey{},
+			secret:  &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: current key without owner",
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo": {Id: optString("foo"), Spec: &sdk.IamV2ApiKeySpec{Secret: optString("bar")}},
+			},
+			secret:  &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{keys: tt.keys}
+				c, err := NewAdminServiceClient(&sdk.APIClient{APIKeysIamV2Api: m})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Create(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					if !reflect.DeepEqual(tt.secret, tt.want) {
+						t.Errorf("Create() got = %v, want %v", tt.secret, tt.want)
+					}
+					if !reflect.DeepEqual(m.authKeys, tt.wantAuthKeys) {
+						t.Errorf("Create() authenticated with = %v, want %v", m.authKeys, tt.wantAuthKeys)
+					}
+				}
+			},
+		)
+	}
+}
+
+// mockNotReadyAPIKeysIamV2Api the API where the new keys never become usable.
+type mockNotReadyAPIKeysIamV2Api struct {
+	*mockAPIKeysIamV2Api
+}
+
+func (m mockNotReadyAPIKeysIamV2Api) GetIamV2ApiKey(ctx context.Context, id string) sdk.ApiGetIamV2ApiKeyRequest {
+	if id == mockIDNew {
+		id = ""
+	}
+	return m.mockAPIKeysIamV2Api.GetIamV2ApiKey(ctx, id)
+}
+
+func Test_adminClient_Create_cleanup(t *testing.T) {
+	m := &mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{"foo": newMockAdminKey("foo", "bar", "sa-1")}}
+	c, err := NewAdminServiceClient(&sdk.APIClient{APIKeysIamV2Api: mockNotReadyAPIKeysIamV2Api{m}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*adminClient).backoff = backoff{}
+
+	secret := &SecretAdmin{APIKey: "foo", APISecret: "bar"}
+	if err := c.Create(context.TODO(), secret); err == nil {
+		t.Fatal("Create() expected error")
+	}
+
+	if _, ok := m.keys[mockIDNew]; ok {
+		t.Errorf("Create() shall delete the new key which did not become usable")
+	}
+	if !reflect.DeepEqual(secret, &SecretAdmin{APIKey: "foo", APISecret: "bar"}) {
+		t.Errorf("Create() shall not mutate the secret upon failure, got = %v", secret)
+	}
+}
+
+func Test_adminClient_Set(t *testing.T) {
+	tests := []struct {
+		name          string
+		keys          map[string]sdk.IamV2ApiKey
+		secretCurrent any
+		secretPending any
+		wantErr       bool
+	}{
+		{
+			name: "happy path",
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo":     newMockAdminKey("foo", "bar", "sa-1"),
+				"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-1"),
+			},
+			secretCurrent: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			secretPending: &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr:       false,
+		},
+		{
+			name: "unhappy path: owners do not match",
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo":     newMockAdminKey("foo", "bar", "sa-1"),
+				"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-2"),
+			},
+			secretCurrent: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			secretPending: &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr:       true,
+		},
+		{
+			name: "unhappy path: pending key not found",
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo": newMockAdminKey("foo", "bar", "sa-1"),
+			},
+			secretCurrent: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			secretPending: &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr:       true,
+		},
+		{
+			name: "unhappy path: current key not found",
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-1"),
+			},
+			secretCurrent: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			secretPending: &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: api keys match",
+			keys:          map[string]sdk.IamV2ApiKey{"foo": newMockAdminKey("foo", "bar", "sa-1")},
+			secretCurrent: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			secretPending: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong type of the current secret",
+			secretCurrent: "foo",
+			secretPending: &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong type of the pending secret",
+			secretCurrent: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			secretPending: "foo-new",
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{keys: tt.keys}
+				c := adminClient{c: &sdk.APIClient{APIKeysIamV2Api: m}}
+				err := c.Set(context.TODO(), tt.secretCurrent, tt.secretPending, nil)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					for _, k := range m.authKeys {
+						if k != "foo" {
+							t.Errorf("Set() shall authenticate with the current key, got %s", k)
+						}
+					}
+				}
+			},
+		)
+	}
+}
+
+func Test_adminClient_Test(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string]sdk.IamV2ApiKey
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			keys:    map[string]sdk.IamV2ApiKey{"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-1")},
+			secret:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: key not found",
+			keys:    map[string]sdk.IamV2ApiKey{},
+			secret:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			keys:    map[string]sdk.IamV2ApiKey{},
+			secret:  "foo-new",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{keys: tt.keys}
+				c := adminClient{c: &sdk.APIClient{APIKeysIamV2Api: m}}
+				if err := c.Test(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(m.authKeys, []string{"foo-new"}) {
+					t.Errorf("Test() shall authenticate with the new key, got %v", m.authKeys)
+				}
+			},
+		)
+	}
+}
+
+func Test_adminClient_Finalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		m              *mockAPIKeysIamV2Api
+		secretCurrent  any
+		secretPrevious any
+		wantKeys       []string
+		wantErr        bool
+	}{
+		{
+			name: "happy path",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{
+					"foo":     newMockAdminKey("foo", "bar", "sa-1"),
+					"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-1"),
+				},
+			},
+			secretCurrent:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			secretPrevious: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantKeys:       []string{"foo-new"},
+			wantErr:        false,
+		},
+		{
+			name: "happy path: no previous version",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-1")},
+			},
+			secretCurrent:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			secretPrevious: nil,
+			wantKeys:       []string{"foo-new"},
+			wantErr:        false,
+		},
+		{
+			name: "happy path: previous matches current",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{"foo-new": newMockAdminKey("foo-new", "bar-new", "sa-1")},
+			},
+			secretCurrent:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			secretPrevious: &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			wantKeys:       []string{"foo-new"},
+			wantErr:        false,
+		},
+		{
+			name: "unhappy path: deletion error",
+			m: &mockAPIKeysIamV2Api{
+				deleteKeyExecuteError: true,
+				keys:                  map[string]sdk.IamV2ApiKey{},
+			},
+			secretCurrent:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			secretPrevious: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantErr:        true,
+		},
+		{
+			name:           "unhappy path: wrong type of the current secret",
+			m:              &mockAPIKeysIamV2Api{},
+			secretCurrent:  "foo-new",
+			secretPrevious: &SecretAdmin{APIKey: "foo", APISecret: "bar"},
+			wantErr:        true,
+		},
+		{
+			name:           "unhappy path: wrong type of the previous secret",
+			m:              &mockAPIKeysIamV2Api{},
+			secretCurrent:  &SecretAdmin{APIKey: "foo-new", APISecret: "bar-new"},
+			secretPrevious: "foo",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := adminClient{c: &sdk.APIClient{APIKeysIamV2Api: tt.m}}
+				err := c.Finalize(context.TODO(), tt.secretCurrent, tt.secretPrevious)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Finalize() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					if keys := mockKeyIDs(tt.m); !reflect.DeepEqual(keys, tt.wantKeys) {
+						t.Errorf("Finalize() remaining keys = %v, want %v", keys, tt.wantKeys)
+					}
+					for _, k := range tt.m.authKeys {
+						if k != "foo-new" {
+							t.Errorf("Finalize() shall authenticate with the current key, got %s", k)
+						}
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestNewAdminServiceClient(t *testing.T) {
+	if _, err := NewAdminServiceClient(nil); err == nil {
+		t.Errorf("NewAdminServiceClient() error expected for nil client")
+	}
+
+	client := &sdk.APIClient{APIKeysIamV2Api: &mockAPIKeysIamV2Api{}}
+	got, err := NewAdminServiceClient(client)
+	if err != nil {
+		t.Errorf("NewAdminServiceClient() unexpected error = %v", err)
+	}
+	want := &adminClient{c: client, backoff: defaultBackoff(), retry: DefaultRetryPolicy()}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewAdminServiceClient() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+package confluent
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+)
+
+func newMockKeysSecret(entries ...map[string]any) *SecretUser {
+	keys := make([]any, len(entries))
+	for i, e := range entries {
+		keys[i] = e
+	}
+	return &SecretUser{"keys": keys, "app": "foo"}
+}
+
+func newMockKeysClient(m *mockAPIKeysIamV2Api) dbClient {
+	return dbClient{
+		attributeKey:    "api_key",
+		attributeSecret: "api_secret",
+		attributeKeys:   "/keys",
+		c:               &sdk.APIClient{APIKeysIamV2Api: m},
+	}
+}
+
+func Test_dbClient_Create_keys(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        *mockAPIKeysIamV2Api
+		secret   *SecretUser
+		want     *SecretUser
+		wantKeys []string
+		wantErr  bool
+	}{
+		{
+			name: "happy path",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{
+					"foo": {Id: optString("foo"), Spec: &sdk.IamV2ApiKeySpec{}},
+					"bar": {Id: optString("bar"), Spec: &sdk.IamV2ApiKeySpec{}},
+				},
+			},
+			secret: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret", "schema_registry_url": "https://sr"},
+			),
+			want: newMockKeysSecret(
+				map[string]any{"api_key": mockIDNew, "api_secret": mockSecretNew},
+				map[string]any{
+					"api_key": mockIDNew + "-2", "api_secret": mockSecretNew, "schema_registry_url": "https://sr",
+				},
+			),
+			wantKeys: []string{"bar", mockIDNew, mockIDNew + "-2", "foo"},
+			wantErr:  false,
+		},
+		{
+			name: "unhappy path: second creation failed, first new key deleted",
+			m: &mockAPIKeysIamV2Api{
+				createKeyErrorAt: 2,
+				keys: map[string]sdk.IamV2ApiKey{
+					"foo": {Id: optString("foo"), Spec: &sdk.IamV2ApiKeySpec{}},
+					"bar": {Id: optString("bar"), Spec: &sdk.IamV2ApiKeySpec{}},
+				},
+			},
+			secret: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret"},
+			),
+			wantKeys: []string{"bar", "foo"},
+			wantErr:  true,
+		},
+		{
+			name: "unhappy path: second current key not found, first new key deleted",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{
+					"foo": {Id: optString("foo"), Spec: &sdk.IamV2ApiKeySpec{}},
+				},
+			},
+			secret: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret"},
+			),
+			wantKeys: []string{"foo"},
+			wantErr:  true,
+		},
+		{
+			name: "unhappy path: empty list of keys",
+			m:    &mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{}},
+			secret: &SecretUser{
+				"keys": []any{},
+			},
+			wantKeys: nil,
+			wantErr:  true,
+		},
+		{
+			name: "unhappy path: list element is not an object",
+			m:    &mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{}},
+			secret: &SecretUser{
+				"keys": []any{"foo"},
+			},
+			wantKeys: nil,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := newMockKeysClient(tt.m)
+				if err := c.Create(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(tt.secret, tt.want) {
+					t.Errorf("Create() got = %v, want %v", tt.secret, tt.want)
+				}
+				if keys := mockKeyIDs(tt.m); !reflect.DeepEqual(keys, tt.wantKeys) {
+					t.Errorf("Create() keys = %v, want %v", keys, tt.wantKeys)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Set_keys(t *testing.T) {
+	tests := []struct {
+		name           string
+		secretCurrent  *SecretUser
+		secretPending  *SecretUser
+		secretPrevious any
+		wantKeys       []string
+		wantErr        bool
+	}{
+		{
+			name: "happy path",
+			secretCurrent: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret", "schema_registry_url": "https://sr"},
+			),
+			secretPending: newMockKeysSecret(
+				map[string]any{"api_key": "foo-new", "api_secret": "foo-secret-new"},
+				map[string]any{"api_key": "bar-new", "api_secret": "bar-secret-new", "schema_registry_url": "https://sr"},
+			),
+			secretPrevious: newMockKeysSecret(
+				map[string]any{"api_key": "foo-prev", "api_secret": "foo-secret-prev"},
+				map[string]any{"api_key": "bar-prev", "api_secret": "bar-secret-prev", "schema_registry_url": "https://sr"},
+			),
+			wantKeys: []string{"bar", "foo"},
+			wantErr:  false,
+		},
+		{
+			name: "happy path: previous version of a different layout",
+			secretCurrent: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+			),
+			secretPending: newMockKeysSecret(
+				map[string]any{"api_key": "foo-new", "api_secret": "foo-secret-new"},
+			),
+			secretPrevious: &SecretUser{"api_key": "bar-prev", "api_secret": "bar-secret-prev"},
+			wantKeys:       []string{"bar", "bar-prev", "foo", "foo-prev"},
+			wantErr:        false,
+		},
+		{
+			name: "unhappy path: one key not modified",
+			secretCurrent: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret"},
+			),
+			secretPending: newMockKeysSecret(
+				map[string]any{"api_key": "foo-new", "api_secret": "foo-secret-new"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret"},
+			),
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: number of keys does not match",
+			secretCurrent: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+				map[string]any{"api_key": "bar", "api_secret": "bar-secret"},
+			),
+			secretPending: newMockKeysSecret(
+				map[string]any{"api_key": "foo-new", "api_secret": "foo-secret-new"},
+			),
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: additional attributes of the entry do not match",
+			secretCurrent: newMockKeysSecret(
+				map[string]any{"api_key": "foo", "api_secret": "foo-secret", "schema_registry_url": "https://sr"},
+			),
+			secretPending: newMockKeysSecret(
+				map[string]any{"api_key": "foo-new", "api_secret": "foo-secret-new", "schema_registry_url": "https://x"},
+			),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{
+					keys: map[string]sdk.IamV2ApiKey{
+						"foo": {}, "bar": {}, "foo-prev": {}, "bar-prev": {},
+					},
+				}
+				c := newMockKeysClient(m)
+				err := c.Set(context.TODO(), tt.secretCurrent, tt.secretPending, tt.secretPrevious)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					if keys := mockKeyIDs(m); !reflect.DeepEqual(keys, tt.wantKeys) {
+						t.Errorf("Set() keys = %v, want %v", keys, tt.wantKeys)
+					}
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Test_keys(t *testing.T) {
+	m := &mockAPIKeysIamV2Api{
+		keys: map[string]sdk.IamV2ApiKey{
+			"foo": {Id: optString("foo"), Spec: &sdk.IamV2ApiKeySpec{}},
+		},
+	}
+	c := newMockKeysClient(m)
+
+	secret := newMockKeysSecret(
+		map[string]any{"api_key": "foo", "api_secret": "foo-secret"},
+		map[string]any{"api_key": "bar", "api_secret": "bar-secret"},
+	)
+	if err := c.Test(context.TODO(), secret); err == nil {
+		t.Errorf("Test() error expected: the second key does not exist")
+	}
+
+	m.keys["bar"] = sdk.IamV2ApiKey{Id: optString("bar"), Spec: &sdk.IamV2ApiKeySpec{}}
+	if err := c.Test(context.TODO(), secret); err != nil {
+		t.Errorf("Test() unexpected error = %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+func newMockMetadataClient(m *mockAPIKeysIamV2Api) dbClient {
+	return dbClient{
+		attributeKey:      "user",
+		attributeSecret:   "password",
+		attributeMetadata: "api_key_metadata",
+		c:                 &sdk.APIClient{APIKeysIamV2Api: m},
+	}
+}
+
+func Test_dbClient_Create_metadata(t *testing.T) {
+	owner := &sdk.ObjectReference{Id: "sa-1", Kind: optString("ServiceAccount")}
+	resource := &sdk.ObjectReference{Id: "lkc-1", Kind: optString(resourceKindKafka)}
+
+	tests := []struct {
+		name         string
+		keys         map[string]sdk.IamV2ApiKey
+		secret       *SecretUser
+		wantMetadata KeyMetadata
+		wantErr      bool
+	}{
+		{
+			name: "happy path: metadata is written",
+			keys: map[string]sdk.IamV2ApiKey{
+				"foo": {
+					Id: optString("foo"),
+					Spec: &sdk.IamV2ApiKeySpec{
+						DisplayName: optString("app"), Owner: owner, Resource: resource,
+					},
+				},
+			},
+			secret: &SecretUser{"user": "foo", "password": "bar"},
+			wantMetadata: KeyMetadata{
+				ID: mockIDNew, DisplayName: "app", Description: KeyDescriptionMarker(""), Owner: owner, Resource: resource,
+			},
+			wantErr: false,
+		},
+		{
+			name: "happy path: current key deleted, recreated using metadata",
+			keys: map[string]sdk.IamV2ApiKey{},
+			secret: &SecretUser{
+				"user": "foo", "password": "bar",
+				"api_key_metadata": map[string]any{
+					"display_name": "app",
+					"owner":        map[string]any{"id": "sa-1", "kind": "ServiceAccount"},
+					"resource":     map[string]any{"id": "lkc-1", "kind": resourceKindKafka},
+				},
+			},
+			wantMetadata: KeyMetadata{
+				ID: mockIDNew, DisplayName: "app", Description: KeyDescriptionMarker(""), Owner: owner, Resource: resource,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: current key deleted, no metadata",
+			keys:    map[string]sdk.IamV2ApiKey{},
+			secret:  &SecretUser{"user": "foo", "password": "bar"},
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: current key deleted, no owner in metadata",
+			keys: map[string]sdk.IamV2ApiKey{},
+			secret: &SecretUser{
+				"user": "foo", "password": "bar",
+				"api_key_metadata": map[string]any{"display_name": "app"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := newMockMetadataClient(&mockAPIKeysIamV2Api{keys: tt.keys})
+				if err := c.Create(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				if c.apiKeyID(*tt.secret) != mockIDNew {
+					t.Errorf("Create() new key was not stored")
+				}
+
+				got, ok := c.metadata(*tt.secret)
+				if !ok {
+					t.Fatalf("Create() metadata not found")
+				}
+				if got.CreatedAt == nil {
+					t.Errorf("Create() metadata creation time is not set")
+				}
+				got.CreatedAt = nil
+
+				if !reflect.DeepEqual(got, tt.wantMetadata) {
+					gotJSON, _ := json.Marshal(got)
+					wantJSON, _ := json.Marshal(tt.wantMetadata)
+					t.Errorf("Create() metadata = %s, want %s", gotJSON, wantJSON)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Create_templates(t *testing.T) {
+	event := lambda.RotationEvent{
+		SecretARN:          "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8",
+		ClientRequestToken: "ebd8a3b0-7a8b-4b0c-9d5e-2b9f4c7c1d2e",
+	}
+
+	tests := []struct {
+		name                string
+		opts                []Option
+		ctx                 context.Context
+		wantDisplayName     string
+		wantDescription     string
+		wantNewClientErr    bool
+		wantCreateErr       bool
+		wantDescriptionLike *regexp.Regexp
+	}{
+		{
+			name: "happy path: templates applied",
+			opts: []Option{
+				WithDisplayNameTemplate("{{.SecretName}} {{.VersionID}}"),
+				WithDescriptionTemplate("{{.Description}}; previous: {{.DisplayName}}"),
+			},
+			ctx:             lambda.ContextWithEvent(context.TODO(), event),
+			wantDisplayName: "foo/bar ebd8a3b0-7a8b-4b0c-9d5e-2b9f4c7c1d2e",
+			wantDescription: "qux; previous: app " + KeyDescriptionMarker(event.SecretARN),
+		},
+		{
+			name:                "happy path: timestamp",
+			opts:                []Option{WithDescriptionTemplate("rotated at {{.Timestamp}}")},
+			ctx:                 lambda.ContextWithEvent(context.TODO(), event),
+			wantDisplayName:     "app",
+			wantDescriptionLike: regexp.MustCompile(`^rotated at \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z `),
+		},
+		{
+			name:            "happy path: no event in the context",
+			opts:            []Option{WithDisplayNameTemplate("{{.SecretName}}app")},
+			ctx:             context.TODO(),
+			wantDisplayName: "app",
+			wantDescription: "qux " + KeyDescriptionMarker(""),
+		},
+		{
+			name:             "unhappy path: faulty template",
+			opts:             []Option{WithDisplayNameTemplate("{{.SecretName")},
+			wantNewClientErr: true,
+		},
+		{
+			name:          "unhappy path: unknown variable",
+			opts:          []Option{WithDescriptionTemplate("{{.Foo}}")},
+			ctx:           context.TODO(),
+			wantCreateErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m := &mockAPIKeysIamV2Api{
+					keys: map[string]sdk.IamV2ApiKey{
+						"foo": {
+							Id: optString("foo"),
+							Spec: &sdk.IamV2ApiKeySpec{
+								DisplayName: optString("app"),
+								Description: optString("qux " + KeyDescriptionMarker(event.SecretARN)),
+								Owner:       &sdk.ObjectReference{Id: "sa-1"},
+							},
+						},
+					},
+				}
+				opts := append([]Option{WithBackoff(0, 0)}, tt.opts...)
+				c, err := NewServiceClient(&sdk.APIClient{APIKeysIamV2Api: m}, "admin", "admin-secret", "", "", opts...)
+				if (err != nil) != tt.wantNewClientErr {
+					t.Fatalf("NewServiceClient() error = %v, wantErr %v", err, tt.wantNewClientErr)
+				}
+				if tt.wantNewClientErr {
+					return
+				}
+
+				secret := &SecretUser{"user": "foo", "password": "bar"}
+				if err := c.Create(tt.ctx, secret); (err != nil) != tt.wantCreateErr {
+					t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantCreateErr)
+				}
+				if tt.wantCreateErr {
+					return
+				}
+
+				got, _ := c.(*dbClient).metadata(*secret)
+				if got.DisplayName != tt.wantDisplayName {
+					t.Errorf("Create() display name = %q, want %q", got.DisplayName, tt.wantDisplayName)
+				}
+				if tt.wantDescriptionLike != nil {
+					if !tt.wantDescriptionLike.MatchString(got.Description) {
+						t.Errorf("Create() description = %q, want like %q", got.Description, tt.wantDescriptionLike)
+					}
+				} else if got.Description != tt.wantDescription {
+					t.Errorf("Create() description = %q, want %q", got.Description, tt.wantDescription)
+				}
+			},
+		)
+	}
+}
+
+func Test_secretName(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{arn: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo/bar-5BKPC8", want: "foo/bar"},
+		{arn: "arn:aws:secretsmanager:us-east-1:000000000000:secret:foo", want: "foo"},
+		{arn: "foo/bar", want: "foo/bar"},
+		{arn: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := secretName(tt.arn); got != tt.want {
+			t.Errorf("secretName(%q) = %q, want %q", tt.arn, got, tt.want)
+		}
+	}
+}
+
+func Test_dbClient_Set_metadata(t *testing.T) {
+	c := newMockMetadataClient(&mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{}})
+
+	current := &SecretUser{"user": "foo", "password": "bar", "host": "localhost"}
+	pending := &SecretUser{
+		"user": "foo-new", "password": "bar-new", "host": "localhost",
+		"api_key_metadata": map[string]any{"owner": map[string]any{"id": "sa-1"}},
+	}
+
+	if err := c.Set(context.TODO(), current, pending, nil); err != nil {
+		t.Errorf("Set() metadata shall be excluded from the comparison, error = %v", err)
+	}
+}
@@ -2,10 +2,15 @@ package confluent
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
 	"testing"
+	"time"
 
 	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
 	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
@@ -22,12 +27,30 @@ type mockAPIKeysIamV2Api struct {
 	generateCorruptSecret bool
 	createKeyExecuteError bool
 	deleteKeyExecuteError bool
-	keys                  map[string]sdk.IamV2ApiKey
+	// createKeyErrorAt the number of the creation request to fail, starting from one
+	createKeyErrorAt int
+	// created the number of the creation requests
+	created int
+	keys    map[string]sdk.IamV2ApiKey
+	// authKeys API keys used to authenticate the requests
+	authKeys []string
+}
+
+func (m *mockAPIKeysIamV2Api) recordAuth(ctx context.Context) {
+	if v, ok := ctx.Value(sdk.ContextBasicAuth).(sdk.BasicAuth); ok {
+		m.authKeys = append(m.authKeys, v.UserName)
+	}
 }
 
 func (m *mockAPIKeysIamV2Api) CreateIamV2ApiKey(ctx context.Context) sdk.ApiCreateIamV2ApiKeyRequest {
+	m.recordAuth(ctx)
+	m.created++
+	id := mockIDNew
+	if m.created > 1 {
+		id += "-" + strconv.Itoa(m.created)
+	}
 	o := sdk.IamV2ApiKey{
-		Id: optString(mockIDNew),
+		Id: optString(id),
 		Spec: &sdk.IamV2ApiKeySpec{
 			Secret: optString(mockSecretNew),
 		},
@@ -43,10 +66,14 @@ func (m *mockAPIKeysIamV2Api) CreateIamV2ApiKey(ctx context.Context) sdk.ApiCrea
 	if m.generateCorruptSpec {
 		o.Spec = nil
 	}
+	failed := m.createKeyExecuteError || m.created == m.createKeyErrorAt
+	if !failed && m.keys != nil {
+		m.keys[id] = o
+	}
 	return sdk.ApiCreateIamV2ApiKeyRequest{
 		ApiService: &mockAPIKeysIamV2Api{
-			createKeyExecuteError: m.createKeyExecuteError,
-			keys:                  map[string]sdk.IamV2ApiKey{mockIDNew: o},
+			createKeyExecuteError: failed,
+			keys:                  map[string]sdk.IamV2ApiKey{id: o},
 		},
 	}
 }
@@ -57,10 +84,14 @@ func (m *mockAPIKeysIamV2Api) CreateIamV2ApiKeyExecute(r sdk.ApiCreateIamV2ApiKe
 	if m.createKeyExecuteError {
 		return sdk.IamV2ApiKey{}, nil, errors.New("test-error")
 	}
-	return m.keys[mockIDNew], nil, nil
+	for _, v := range m.keys {
+		return v, nil, nil
+	}
+	return sdk.IamV2ApiKey{}, nil, errors.New("test-error")
 }
 
 func (m *mockAPIKeysIamV2Api) DeleteIamV2ApiKey(ctx context.Context, id string) sdk.ApiDeleteIamV2ApiKeyRequest {
+	m.recordAuth(ctx)
 	delete(m.keys, id)
 	return sdk.ApiDeleteIamV2ApiKeyRequest{
 		ApiService: m,
@@ -75,6 +106,7 @@ func (m *mockAPIKeysIamV2Api) DeleteIamV2ApiKeyExecute(r sdk.ApiDeleteIamV2ApiKe
 }
 
 func (m *mockAPIKeysIamV2Api) GetIamV2ApiKey(ctx context.Context, id string) sdk.ApiGetIamV2ApiKeyRequest {
+	m.recordAuth(ctx)
 	v, ok := m.keys[id]
 	if !ok {
 		return sdk.ApiGetIamV2ApiKeyRequest{
@@ -92,17 +124,23 @@ func (m *mockAPIKeysIamV2Api) GetIamV2ApiKeyExecute(r sdk.ApiGetIamV2ApiKeyReque
 	for _, v := range m.keys {
 		return v, nil, nil
 	}
-	return sdk.IamV2ApiKey{}, nil, errors.New("not found")
+	return sdk.IamV2ApiKey{}, &http.Response{StatusCode: http.StatusNotFound}, errors.New("not found")
 }
 
 func (m *mockAPIKeysIamV2Api) ListIamV2ApiKeys(ctx context.Context) sdk.ApiListIamV2ApiKeysRequest {
-	panic("not implemented")
+	m.recordAuth(ctx)
+	return sdk.ApiListIamV2ApiKeysRequest{ApiService: m}
 }
 
+// ListIamV2ApiKeysExecute lists all keys ignoring the filters.
 func (m *mockAPIKeysIamV2Api) ListIamV2ApiKeysExecute(r sdk.ApiListIamV2ApiKeysRequest) (
 	sdk.IamV2ApiKeyList, *http.Response, error,
 ) {
-	panic("not implemented")
+	var o []sdk.IamV2ApiKey
+	for _, id := range mockKeyIDs(m) {
+		o = append(o, m.keys[id])
+	}
+	return sdk.IamV2ApiKeyList{Data: o}, nil, nil
 }
 
 func (m *mockAPIKeysIamV2Api) UpdateIamV2ApiKey(ctx context.Context, id string) sdk.ApiUpdateIamV2ApiKeyRequest {
@@ -161,6 +199,33 @@ func Test_dbClient_Create(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "happy path: nested attributes",
+			fields: fields{
+				KeyUser:     "/kafka/sasl/username",
+				KeyPassword: "/kafka/sasl/password",
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{
+						keys: map[string]sdk.IamV2ApiKey{
+							"bar": {
+								Id: optString("bar"),
+								Spec: &sdk.IamV2ApiKeySpec{
+									Secret:      optString(mockSecret),
+									DisplayName: optString(mockDisplayName),
+								},
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ctx: context.TODO(),
+				secret: &SecretUser{
+					"kafka": map[string]any{"sasl": map[string]any{"username": "bar", "password": mockSecret}},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "unhappy path: ID of new key is corrupt",
 			fields: fields{
@@ -361,7 +426,7 @@ func Test_dbClient_Create(t *testing.T) {
 
 				if !tt.wantErr {
 					s := *(tt.args.secret.(*SecretUser))
-					if mockIDNew != s["user"] || mockSecretNew != s["password"] {
+					if mockIDNew != c.apiKeyID(s) || mockSecretNew != c.apiKeySecret(s) {
 						t.Errorf("Create() newly generated secret was not stored correctly")
 					}
 				}
@@ -370,6 +435,29 @@ func Test_dbClient_Create(t *testing.T) {
 	}
 }
 
+func Test_deleteCreatedKeys(t *testing.T) {
+	marker := KeyDescriptionMarker("arn:aws:secretsmanager:us-east-1:000000000000:secret:foo")
+	m := &mockAPIKeysIamV2Api{
+		keys: map[string]sdk.IamV2ApiKey{
+			"current": newMockGCKey("current", "app "+marker, time.Hour),
+			"created": newMockGCKey("created", "app "+marker, 0),
+			"sibling": newMockGCKey(
+				"sibling", "app "+KeyDescriptionMarker("arn:aws:secretsmanager:us-east-1:000000000000:secret:bar"), 0,
+			),
+			"manual": newMockGCKey("manual", "app", 0),
+		},
+	}
+
+	spec := *m.keys["created"].Spec
+	if err := deleteCreatedKeys(context.TODO(), m, spec, map[string]struct{}{"current": {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, want := mockKeyIDs(m), []string{"current", "manual", "sibling"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("deleteCreatedKeys() remaining keys = %v, want %v", keys, want)
+	}
+}
+
 func optString(s string) *string {
 	return &s
 }
@@ -378,6 +466,7 @@ func Test_dbClient_Set(t *testing.T) {
 	type fields struct {
 		KeyUser     string
 		KeyPassword string
+		gracePeriod time.Duration
 		c           *sdk.APIClient
 	}
 	type args struct {
@@ -387,10 +476,11 @@ func Test_dbClient_Set(t *testing.T) {
 		secretPrevious any
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name     string
+		fields   fields
+		args     args
+		wantKeys []string
+		wantErr  bool
 	}{
 		{
 			name: "happy path",
@@ -410,7 +500,151 @@ func Test_dbClient_Set(t *testing.T) {
 				secretCurrent: &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092"},
 				secretPending: &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
 			},
-			wantErr: false,
+			wantKeys: []string{"foo"},
+			wantErr:  false,
+		},
+		{
+			name: "happy path: previous key deleted",
+			fields: fields{
+				KeyUser:     "user",
+				KeyPassword: "password",
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{
+						keys: map[string]sdk.IamV2ApiKey{
+							"foo":      {Id: optString("foo")},
+							"foo-prev": {Id: optString("foo-prev")},
+						},
+					},
+				},
+			},
+			args: args{
+				ctx:            context.TODO(),
+				secretCurrent:  &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092"},
+				secretPending:  &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
+				secretPrevious: &SecretUser{"user": "foo-prev", "password": "bar-prev", "host": "localhost:9092"},
+			},
+			wantKeys: []string{"foo"},
+			wantErr:  false,
+		},
+		{
+			name: "happy path: previous key deleted after grace period",
+			fields: fields{
+				KeyUser:     "user",
+				KeyPassword: "password",
+				gracePeriod: time.Hour,
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{
+						keys: map[string]sdk.IamV2ApiKey{
+							"foo": {
+								Id:       optString("foo"),
+								Metadata: &sdk.ObjectMeta{CreatedAt: optTime(time.Now().Add(-2 * time.Hour))},
+							},
+							"foo-prev": {Id: optString("foo-prev")},
+						},
+					},
+				},
+			},
+			args: args{
+				ctx:            context.TODO(),
+				secretCurrent:  &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092"},
+				secretPending:  &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
+				secretPrevious: &SecretUser{"user": "foo-prev", "password": "bar-prev", "host": "localhost:9092"},
+			},
+			wantKeys: []string{"foo"},
+			wantErr:  false,
+		},
+		{
+			name: "happy path: grace period of the previous key has not passed, deletion deferred",
+			fields: fields{
+				KeyUser:     "user",
+				KeyPassword: "password",
+				gracePeriod: time.Hour,
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{
+						keys: map[string]sdk.IamV2ApiKey{
+							"foo": {
+								Id:       optString("foo"),
+								Metadata: &sdk.ObjectMeta{CreatedAt: optTime(time.Now().Add(-time.Minute))},
+							},
+							"foo-prev": {Id: optString("foo-prev")},
+						},
+					},
+				},
+			},
+			args: args{
+				ctx:            context.TODO(),
+				secretCurrent:  &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092"},
+				secretPending:  &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
+				secretPrevious: &SecretUser{"user": "foo-prev", "password": "bar-prev", "host": "localhost:9092"},
+			},
+			wantKeys: []string{"foo", "foo-prev"},
+			wantErr:  false,
+		},
+		{
+			name: "happy path: nested attributes",
+			fields: fields{
+				KeyUser:     "/kafka/sasl/username",
+				KeyPassword: "/kafka/sasl/password",
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{
+						keys: map[string]sdk.IamV2ApiKey{
+							"foo": {},
+						},
+					},
+				},
+			},
+			args: args{
+				ctx: context.TODO(),
+				secretCurrent: &SecretUser{
+					"kafka": map[string]any{
+						"sasl": map[string]any{"username": "foo", "password": "bar"}, "port": json.Number("9092"),
+					},
+					"tls": true,
+				},
+				secretPending: &SecretUser{
+					"kafka": map[string]any{
+						"sasl": map[string]any{"username": "foo-new", "password": "bar-new"}, "port": json.Number("9092"),
+					},
+					"tls": true,
+				},
+			},
+			wantKeys: []string{"foo"},
+			wantErr:  false,
+		},
+		{
+			name: "unhappy path: nested additional attributes do not match",
+			fields: fields{
+				KeyUser:     "/kafka/sasl/username",
+				KeyPassword: "/kafka/sasl/password",
+			},
+			args: args{
+				ctx: context.TODO(),
+				secretCurrent: &SecretUser{
+					"kafka": map[string]any{
+						"sasl": map[string]any{"username": "foo", "password": "bar"}, "port": json.Number("9092"),
+					},
+				},
+				secretPending: &SecretUser{
+					"kafka": map[string]any{
+						"sasl": map[string]any{"username": "foo-new", "password": "bar-new"}, "port": json.Number("9093"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: wrong type of the previous secret",
+			fields: fields{
+				KeyUser:     "user",
+				KeyPassword: "password",
+			},
+			args: args{
+				ctx:            context.TODO(),
+				secretCurrent:  &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092"},
+				secretPending:  &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
+				secretPrevious: "foo-prev",
+			},
+			wantErr: true,
 		},
 		{
 			name: "unhappy path: wrong type of the current secret",
@@ -491,11 +725,10 @@ func Test_dbClient_Set(t *testing.T) {
 				},
 			},
 			args: args{
-				ctx: context.TODO(),
-				secretCurrent: &SecretUser{
-					"user": "foo", "password": "bar", "host": "localhost:29092", "client_id": "quxx",
-				},
-				secretPending: &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
+				ctx:            context.TODO(),
+				secretCurrent:  &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092"},
+				secretPending:  &SecretUser{"user": "foo-new", "password": "bar-new", "host": "localhost:9092"},
+				secretPrevious: &SecretUser{"user": "foo-prev", "password": "bar-prev", "host": "localhost:9092"},
 			},
 			wantErr: true,
 		},
@@ -506,6 +739,7 @@ func Test_dbClient_Set(t *testing.T) {
 				c := dbClient{
 					attributeKey:    tt.fields.KeyUser,
 					attributeSecret: tt.fields.KeyPassword,
+					gracePeriod:     tt.fields.gracePeriod,
 					c:               tt.fields.c,
 				}
 
@@ -516,11 +750,110 @@ func Test_dbClient_Set(t *testing.T) {
 					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
 				}
 				if !tt.wantErr {
-					id := (*tt.args.secretCurrent.(*SecretUser))[tt.fields.KeyUser]
-					if _, _, e := c.c.APIKeysIamV2Api.GetIamV2ApiKey(
-						context.TODO(), id,
-					).Execute(); e == nil || e.Error() != "not found" {
-						t.Errorf("Set() did not delete current key as expected")
+					if keys := mockKeyIDs(c.c.APIKeysIamV2Api); !reflect.DeepEqual(keys, tt.wantKeys) {
+						t.Errorf("Set() remaining keys = %v, want %v", keys, tt.wantKeys)
+					}
+				}
+			},
+		)
+	}
+}
+
+func mockKeyIDs(m sdk.APIKeysIamV2Api) []string {
+	var o []string
+	for k := range m.(*mockAPIKeysIamV2Api).keys {
+		o = append(o, k)
+	}
+	sort.Strings(o)
+	return o
+}
+
+func optTime(t time.Time) *time.Time {
+	return &t
+}
+
+func Test_dbClient_Finalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		gracePeriod    time.Duration
+		m              *mockAPIKeysIamV2Api
+		secretCurrent  any
+		secretPrevious any
+		wantKeys       []string
+		wantErr        bool
+	}{
+		{
+			name: "happy path",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{"foo": {}, "foo-new": {}},
+			},
+			secretCurrent:  &SecretUser{"user": "foo-new", "password": "bar-new"},
+			secretPrevious: &SecretUser{"user": "foo", "password": "bar"},
+			wantKeys:       []string{"foo-new"},
+			wantErr:        false,
+		},
+		{
+			name:        "happy path: grace period set",
+			gracePeriod: time.Hour,
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{"foo": {}, "foo-new": {}},
+			},
+			secretCurrent:  &SecretUser{"user": "foo-new", "password": "bar-new"},
+			secretPrevious: &SecretUser{"user": "foo", "password": "bar"},
+			wantKeys:       []string{"foo", "foo-new"},
+			wantErr:        false,
+		},
+		{
+			name: "happy path: no previous version",
+			m: &mockAPIKeysIamV2Api{
+				keys: map[string]sdk.IamV2ApiKey{"foo-new": {}},
+			},
+			secretCurrent:  &SecretUser{"user": "foo-new", "password": "bar-new"},
+			secretPrevious: nil,
+			wantKeys:       []string{"foo-new"},
+			wantErr:        false,
+		},
+		{
+			name: "unhappy path: deletion error",
+			m: &mockAPIKeysIamV2Api{
+				deleteKeyExecuteError: true,
+				keys:                  map[string]sdk.IamV2ApiKey{"foo": {}, "foo-new": {}},
+			},
+			secretCurrent:  &SecretUser{"user": "foo-new", "password": "bar-new"},
+			secretPrevious: &SecretUser{"user": "foo", "password": "bar"},
+			wantErr:        true,
+		},
+		{
+			name:           "unhappy path: wrong type of the current secret",
+			m:              &mockAPIKeysIamV2Api{},
+			secretCurrent:  "foo-new",
+			secretPrevious: &SecretUser{"user": "foo", "password": "bar"},
+			wantErr:        true,
+		},
+		{
+			name:           "unhappy path: wrong type of the previous secret",
+			m:              &mockAPIKeysIamV2Api{},
+			secretCurrent:  &SecretUser{"user": "foo-new", "password": "bar-new"},
+			secretPrevious: "foo",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := dbClient{
+					attributeKey:    "user",
+					attributeSecret: "password",
+					gracePeriod:     tt.gracePeriod,
+					c:               &sdk.APIClient{APIKeysIamV2Api: tt.m},
+				}
+				err := c.Finalize(context.TODO(), tt.secretCurrent, tt.secretPrevious)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Finalize() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					if keys := mockKeyIDs(tt.m); !reflect.DeepEqual(keys, tt.wantKeys) {
+						t.Errorf("Finalize() remaining keys = %v, want %v", keys, tt.wantKeys)
 					}
 				}
 			},
@@ -549,6 +882,13 @@ func Test_dbClient_Test(t *testing.T) {
 			fields: fields{
 				KeyUser:     "user",
 				KeyPassword: "password",
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{
+						keys: map[string]sdk.IamV2ApiKey{
+							"foo": {Id: optString("foo"), Spec: &sdk.IamV2ApiKeySpec{Secret: optString("bar")}},
+						},
+					},
+				},
 			},
 			args: args{
 				ctx:    context.TODO(),
@@ -556,6 +896,21 @@ func Test_dbClient_Test(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "unhappy path: key not found",
+			fields: fields{
+				KeyUser:     "user",
+				KeyPassword: "password",
+				c: &sdk.APIClient{
+					APIKeysIamV2Api: &mockAPIKeysIamV2Api{keys: map[string]sdk.IamV2ApiKey{}},
+				},
+			},
+			args: args{
+				ctx:    context.TODO(),
+				secret: &SecretUser{"user": "foo", "password": "bar", "host": "localhost:9092", "attr01": "baz"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "unhappy path: wrong secret type",
 			fields: fields{
@@ -635,10 +990,18 @@ func TestNewServiceClient(t *testing.T) {
 				attributeSecret: "bar",
 			},
 			want: &dbClient{
-				apiKey:          "key",
-				apiSecret:       "secret",
-				attributeKey:    "foo",
-				attributeSecret: "bar",
+				apiKey:                     "key",
+				apiSecret:                  "secret",
+				attributeKey:               "foo",
+				attributeSecret:            "bar",
+				attributeMetadata:          "api_key_metadata",
+				attributeBootstrapServers:  "bootstrap_servers",
+				attributeSchemaRegistryURL: "schema_registry_url",
+				attributeRESTEndpoint:      "rest_endpoint",
+				kafkaTLSConfig:             &tls.Config{MinVersion: tls.VersionTLS12},
+				httpClient:                 &http.Client{Timeout: defaultConnectionTimeout},
+				backoff:                    defaultBackoff(),
+				retry:                      DefaultRetryPolicy(),
 				c: &sdk.APIClient{
 					APIKeysIamV2Api: &mockAPIKeysIamV2Api{},
 				},
@@ -656,10 +1019,18 @@ func TestNewServiceClient(t *testing.T) {
 				attributeSecret: "bar",
 			},
 			want: &dbClient{
-				attributeKey:    "user",
-				attributeSecret: "bar",
-				apiKey:          "key",
-				apiSecret:       "secret",
+				attributeKey:               "user",
+				attributeSecret:            "bar",
+				apiKey:                     "key",
+				apiSecret:                  "secret",
+				attributeMetadata:          "api_key_metadata",
+				attributeBootstrapServers:  "bootstrap_servers",
+				attributeSchemaRegistryURL: "schema_registry_url",
+				attributeRESTEndpoint:      "rest_endpoint",
+				kafkaTLSConfig:             &tls.Config{MinVersion: tls.VersionTLS12},
+				httpClient:                 &http.Client{Timeout: defaultConnectionTimeout},
+				backoff:                    defaultBackoff(),
+				retry:                      DefaultRetryPolicy(),
 				c: &sdk.APIClient{
 					APIKeysIamV2Api: &mockAPIKeysIamV2Api{},
 				},
@@ -677,10 +1048,18 @@ func TestNewServiceClient(t *testing.T) {
 				attributeKey: "foo",
 			},
 			want: &dbClient{
-				apiKey:          "key",
-				apiSecret:       "secret",
-				attributeKey:    "foo",
-				attributeSecret: "password",
+				apiKey:                     "key",
+				apiSecret:                  "secret",
+				attributeKey:               "foo",
+				attributeSecret:            "password",
+				attributeMetadata:          "api_key_metadata",
+				attributeBootstrapServers:  "bootstrap_servers",
+				attributeSchemaRegistryURL: "schema_registry_url",
+				attributeRESTEndpoint:      "rest_endpoint",
+				kafkaTLSConfig:             &tls.Config{MinVersion: tls.VersionTLS12},
+				httpClient:                 &http.Client{Timeout: defaultConnectionTimeout},
+				backoff:                    defaultBackoff(),
+				retry:                      DefaultRetryPolicy(),
 				c: &sdk.APIClient{
 					APIKeysIamV2Api: &mockAPIKeysIamV2Api{},
 				},
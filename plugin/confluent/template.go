@@ -0,0 +1,95 @@
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"text/template"
+	"time"
+
+	sdk "github.com/confluentinc/ccloud-sdk-go-v2/apikeys/v2"
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+// KeyTemplateData defines the variables of the templates of the new API key's display name and description,
+// see WithDisplayNameTemplate and WithDescriptionTemplate.
+type KeyTemplateData struct {
+	// SecretARN the ARN of the rotated secret
+	SecretARN string
+	// SecretName the name of the rotated secret
+	SecretName string
+	// VersionID the ID of the secret's version to rotate to, i.e. ClientRequestToken
+	VersionID string
+	// Timestamp the time of the key creation, RFC3339 formatted
+	Timestamp string
+	// DisplayName the display name of the current API key
+	DisplayName string
+	// Description the description of the current API key without the marker, see KeyDescriptionMarker.
+	// Note that the template which extends the description, e.g. "{{.Description}} rotated at {{.Timestamp}}",
+	// makes it grow upon every rotation because the new key becomes the current key of the next rotation.
+	Description string
+}
+
+func newKeyTemplateData(ctx context.Context, spec sdk.IamV2ApiKeySpec, now time.Time) KeyTemplateData {
+	event, _ := lambda.EventFromContext(ctx)
+	return KeyTemplateData{
+		SecretARN:   event.SecretARN,
+		SecretName:  secretName(event.SecretARN),
+		VersionID:   event.ClientRequestToken,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+		DisplayName: spec.GetDisplayName(),
+		Description: strings.TrimSpace(keyDescriptionMarkerRegexp.ReplaceAllString(spec.GetDescription(), "")),
+	}
+}
+
+// secretName extracts the secret's name from its ARN, the random suffix added by AWS is trimmed.
+// The input is returned as is if it's not an ARN.
+func secretName(arn string) string {
+	const prefix = ":secret:"
+	i := strings.Index(arn, prefix)
+	if !strings.HasPrefix(arn, "arn:") || i < 0 {
+		return arn
+	}
+
+	name := arn[i+len(prefix):]
+	// AWS appends the suffix of the form "-" followed by six random characters
+	if j := len(name) - 7; j > 0 && name[j] == '-' {
+		name = name[:j]
+	}
+	return name
+}
+
+// applyKeyTemplates sets the display name and description of the new API key's spec using the templates.
+func (c dbClient) applyKeyTemplates(ctx context.Context, spec *sdk.IamV2ApiKeySpec, now time.Time) error {
+	if c.displayNameTemplate == nil && c.descriptionTemplate == nil {
+		return nil
+	}
+
+	data := newKeyTemplateData(ctx, *spec, now)
+
+	if c.displayNameTemplate != nil {
+		v, err := executeTemplate(c.displayNameTemplate, data)
+		if err != nil {
+			return err
+		}
+		spec.SetDisplayName(v)
+	}
+
+	if c.descriptionTemplate != nil {
+		v, err := executeTemplate(c.descriptionTemplate, data)
+		if err != nil {
+			return err
+		}
+		spec.SetDescription(v)
+	}
+
+	return nil
+}
+
+func executeTemplate(t *template.Template, data KeyTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	dbclient "github.com/kislerdm/aws-lambda-secret-rotation/plugin/mongodb"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretRotation "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+func main() {
+	secretAdminARN := os.Getenv("ADMIN_SECRET_ARN")
+	if secretAdminARN == "" {
+		log.Fatalln("ADMIN_SECRET_ARN env. variable must be set")
+	}
+
+	cfgSecretsManager, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	clientSecretsManager := secretsmanager.NewFromConfig(cfgSecretsManager)
+
+	v, err := clientSecretsManager.GetSecretValue(
+		context.Background(), &secretsmanager.GetSecretValueInput{SecretId: &secretAdminARN},
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var adminSecret dbclient.SecretAdmin
+	if err := secretRotation.ExtractSecretObject(v, &adminSecret); err != nil {
+		log.Fatalln(err)
+	}
+
+	var opts []dbclient.Option
+	if v, ok := os.LookupEnv("TLS"); ok {
+		opts = append(opts, dbclient.WithTLS(secretRotation.StrToBool(v)))
+	}
+	if v := os.Getenv("SSL_ROOT_CERT"); v != "" {
+		rootCA := []byte(v)
+		if !strings.HasPrefix(v, "-----BEGIN") {
+			if rootCA, err = os.ReadFile(v); err != nil {
+				log.Fatalf("unable to read SSL_ROOT_CERT, %v", err)
+			}
+		}
+		opts = append(opts, dbclient.WithRootCA(rootCA))
+	}
+	if v := os.Getenv("CONNECT_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("unable to parse CONNECT_TIMEOUT, %v", err)
+		}
+		opts = append(opts, dbclient.WithConnectTimeout(timeout))
+	}
+
+	var s dbclient.SecretUser
+	handler, err := secretRotation.NewHandler(
+		secretRotation.Config{
+			SecretsmanagerClient: clientSecretsManager,
+			ServiceClient:        dbclient.NewServiceClient(adminSecret, opts...),
+			SecretObj:            &s,
+			Debug:                secretRotation.StrToBool(os.Getenv("DEBUG")),
+		},
+	)
+	if err != nil {
+		log.Fatalf("unable to init lambda handler to rotate secret, %v", err)
+	}
+
+	lambda.Start(handler)
+}
@@ -0,0 +1,124 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultConnectTimeout the default timeout to establish the connection.
+const DefaultConnectTimeout = 10 * time.Second
+
+// defaultPort the default port of the database.
+const defaultPort = 27017
+
+// defaultAuthSource the default database the users are defined in.
+const defaultAuthSource = "admin"
+
+// ConnConfig defines the configuration of the connection.
+type ConnConfig struct {
+	// URI connection string without credentials
+	URI        string
+	User       string
+	Password   string
+	AuthSource string
+
+	// TLS whether the connection is encrypted, the server's certificate is verified.
+	// The TLS options of the URI are used otherwise.
+	TLS bool
+
+	// RootCA PEM encoded certificates of the CAs to verify the server's certificate,
+	// the system's certificates pool is used if empty.
+	RootCA []byte
+
+	// ConnectTimeout the timeout to establish the connection and to select the server.
+	ConnectTimeout time.Duration
+}
+
+// Client runs the database commands.
+type Client interface {
+	// RunCommand runs the command against the database db and decodes the response into result.
+	RunCommand(ctx context.Context, db string, cmd any, result any) error
+	Disconnect(ctx context.Context) error
+}
+
+// Connector opens the connection.
+type Connector interface {
+	Open(ctx context.Context, cfg ConnConfig) (Client, error)
+}
+
+// ConnectorFunc the function which implements Connector.
+type ConnectorFunc func(ctx context.Context, cfg ConnConfig) (Client, error)
+
+func (f ConnectorFunc) Open(ctx context.Context, cfg ConnConfig) (Client, error) {
+	return f(ctx, cfg)
+}
+
+// MongoConnector the Connector which uses the driver go.mongodb.org/mongo-driver.
+type MongoConnector struct{}
+
+func (MongoConnector) Open(ctx context.Context, cfg ConnConfig) (Client, error) {
+	o := options.Client().
+		ApplyURI(cfg.URI).
+		SetAuth(
+			options.Credential{
+				Username:   cfg.User,
+				Password:   cfg.Password,
+				AuthSource: cfg.AuthSource,
+			},
+		).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.ConnectTimeout)
+
+	if cfg.TLS {
+		c := &tls.Config{MinVersion: tls.VersionTLS12}
+		if len(cfg.RootCA) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(cfg.RootCA) {
+				return nil, errors.New("unable to add root CA to the certificates pool")
+			}
+			c.RootCAs = pool
+		}
+		o.SetTLSConfig(c)
+	}
+
+	client, err := mongo.Connect(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	return mongoClient{client}, nil
+}
+
+type mongoClient struct {
+	*mongo.Client
+}
+
+func (c mongoClient) RunCommand(ctx context.Context, db string, cmd any, result any) error {
+	return c.Database(db).RunCommand(ctx, cmd).Decode(result)
+}
+
+// uri returns the connection string of the user's secret.
+func uri(s *SecretUser) string {
+	if s.URI != "" {
+		return s.URI
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	o := "mongodb://" + net.JoinHostPort(s.Host, strconv.Itoa(port)) + "/"
+	if s.ReplicaSet != "" {
+		o += "?replicaSet=" + url.QueryEscape(s.ReplicaSet)
+	}
+	return o
+}
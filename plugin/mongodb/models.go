@@ -0,0 +1,41 @@
+package mongodb
+
+// SecretAdmin defines the secret with the access details of the admin user which is authorized to run `updateUser`,
+// e.g. the user with the role `userAdminAnyDatabase`.
+type SecretAdmin struct {
+	// User admin user
+	User string `json:"user"`
+	// Password admin user's password
+	Password string `json:"password"`
+	// AuthSource database the admin user is defined in, "admin" by default
+	AuthSource string `json:"auth_source,omitempty"`
+	// URI connection string without credentials, the connection attributes of SecretUser are used if empty
+	URI string `json:"uri,omitempty"`
+}
+
+// SecretUser defines the secret with the db user access details.
+type SecretUser struct {
+	// User database user
+	User string `json:"user"`
+	// Password user's password
+	Password string `json:"password"`
+	// URI connection string without credentials, e.g. mongodb://node-1:27017,node-2:27017/?replicaSet=rs0,
+	// it takes precedence over the attributes host, port and replica_set
+	URI string `json:"uri,omitempty"`
+	// Host database host
+	Host string `json:"host,omitempty"`
+	// Port database port, 27017 by default
+	Port int `json:"port,omitempty"`
+	// ReplicaSet name of the replica set, optional
+	ReplicaSet string `json:"replica_set,omitempty"`
+	// AuthSource database the user is defined in, "admin" by default
+	AuthSource string `json:"auth_source,omitempty"`
+	// Roles roles which must be granted to the user, they are verified upon the step testSecret, optional
+	Roles []Role `json:"roles,omitempty"`
+}
+
+// Role defines the user's role.
+type Role struct {
+	Role string `json:"role" bson:"role"`
+	DB   string `json:"db" bson:"db"`
+}
@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestServiceClient_mongodb runs the rotation against the local MongoDB defined by the env. variables:
+// MONGODB_HOST, MONGODB_PORT, MONGODB_USER and MONGODB_PASSWORD.
+func TestServiceClient_mongodb(t *testing.T) {
+	host := os.Getenv("MONGODB_HOST")
+	if host == "" {
+		t.Skip("MONGODB_HOST is not set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("MONGODB_PORT"))
+
+	admin := SecretAdmin{User: os.Getenv("MONGODB_USER"), Password: os.Getenv("MONGODB_PASSWORD")}
+	secret := &SecretUser{
+		User: "rotation", Password: "initial", Host: host, Port: port, AuthSource: "app",
+		Roles: []Role{{Role: "readWrite", DB: "app"}},
+	}
+
+	c := NewServiceClient(admin, WithTLS(false))
+
+	client, err := MongoConnector{}.Open(context.TODO(), c.(*dbClient).adminConnConfig(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Disconnect(context.TODO()) }()
+
+	dropUser := func() {
+		var result bson.M
+		_ = client.RunCommand(context.TODO(), "app", bson.D{{Key: "dropUser", Value: "rotation"}}, &result)
+	}
+	dropUser()
+	defer dropUser()
+
+	var result bson.M
+	if err := client.RunCommand(
+		context.TODO(), "app", bson.D{
+			{Key: "createUser", Value: "rotation"},
+			{Key: "pwd", Value: "initial"},
+			{Key: "roles", Value: bson.A{bson.M{"role": "readWrite", "db": "app"}}},
+		}, &result,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	current := *secret
+	if err := c.Create(context.TODO(), secret); err != nil {
+		t.Fatalf("Create() unexpected error = %v", err)
+	}
+	if err := c.Set(context.TODO(), &current, secret, nil); err != nil {
+		t.Fatalf("Set() unexpected error = %v", err)
+	}
+	if err := c.Test(context.TODO(), secret); err != nil {
+		t.Fatalf("Test() unexpected error = %v", err)
+	}
+	if err := c.Test(context.TODO(), &current); err == nil {
+		t.Errorf("Test() the password of the current secret must be changed")
+	}
+}
@@ -0,0 +1,227 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// NewServiceClient initiates the `ServiceClient` to rotate the MongoDB user's password.
+// The admin user is used to set the user's password upon the step setSecret.
+func NewServiceClient(admin SecretAdmin, opts ...Option) lambda.ServiceClient {
+	c := &dbClient{
+		admin:          admin,
+		connector:      MongoConnector{},
+		tls:            true,
+		connectTimeout: DefaultConnectTimeout,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Option defines the option of the ServiceClient.
+type Option func(c *dbClient)
+
+// WithConnector sets the Connector to open the database connections, MongoConnector by default.
+func WithConnector(connector Connector) Option {
+	return func(c *dbClient) {
+		c.connector = connector
+	}
+}
+
+// WithTLS sets whether the connections are encrypted, true by default.
+// The TLS options of the connection string are used if false.
+func WithTLS(enabled bool) Option {
+	return func(c *dbClient) {
+		c.tls = enabled
+	}
+}
+
+// WithRootCA sets the PEM encoded certificates of the CAs to verify the server's certificate,
+// e.g. the DocumentDB certificates bundle. The system's certificates are used by default.
+func WithRootCA(pem []byte) Option {
+	return func(c *dbClient) {
+		c.rootCA = pem
+	}
+}
+
+// WithConnectTimeout sets the timeout to establish the database connections, DefaultConnectTimeout by default.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *dbClient) {
+		c.connectTimeout = timeout
+	}
+}
+
+type dbClient struct {
+	admin SecretAdmin
+
+	connector      Connector
+	tls            bool
+	rootCA         []byte
+	connectTimeout time.Duration
+}
+
+// Create validates the secret and generates the new password,
+// the user's credentials are replaced by `updateUser` upon the step setSecret.
+func (c dbClient) Create(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if err := validate(s); err != nil {
+		return err
+	}
+
+	password, err := lambda.GeneratePassword(passwordLength)
+	if err != nil {
+		return err
+	}
+
+	s.Password = password
+
+	return nil
+}
+
+// Set sets the pending password of the user by running `updateUser` with the admin user.
+func (c dbClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	s, ok := secretPending.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if s.Password == "" {
+		return errors.New("pending secret is corrupt: password is empty")
+	}
+
+	if err := validate(s); err != nil {
+		return err
+	}
+
+	client, err := c.connector.Open(ctx, c.adminConnConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	var result bson.M
+	return client.RunCommand(
+		ctx, authSource(s.AuthSource), bson.D{{Key: "updateUser", Value: s.User}, {Key: "pwd", Value: s.Password}},
+		&result,
+	)
+}
+
+// Test verifies that the secret authenticates the user with SCRAM, and that the authenticated user and its roles
+// match the secret using `connectionStatus`.
+func (c dbClient) Test(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if err := validate(s); err != nil {
+		return err
+	}
+
+	client, err := c.connector.Open(ctx, c.connConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	db := authSource(s.AuthSource)
+
+	var status connectionStatus
+	if err := client.RunCommand(ctx, db, bson.D{{Key: "connectionStatus", Value: 1}}, &status); err != nil {
+		return err
+	}
+
+	if !status.authenticated(s.User, db) {
+		return errors.New(`user "` + s.User + `" is not authenticated to "` + db + `"`)
+	}
+
+	for _, want := range s.Roles {
+		if !status.granted(want) {
+			return errors.New(`role "` + want.Role + `" on "` + want.DB + `" is not granted to "` + s.User + `"`)
+		}
+	}
+
+	return nil
+}
+
+// connectionStatus defines the response of the command `connectionStatus`.
+type connectionStatus struct {
+	AuthInfo struct {
+		AuthenticatedUsers []struct {
+			User string `bson:"user"`
+			DB   string `bson:"db"`
+		} `bson:"authenticatedUsers"`
+		AuthenticatedUserRoles []Role `bson:"authenticatedUserRoles"`
+	} `bson:"authInfo"`
+}
+
+func (s connectionStatus) authenticated(user, db string) bool {
+	for _, u := range s.AuthInfo.AuthenticatedUsers {
+		if u.User == user && u.DB == db {
+			return true
+		}
+	}
+	return false
+}
+
+func (s connectionStatus) granted(role Role) bool {
+	for _, r := range s.AuthInfo.AuthenticatedUserRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func validate(s *SecretUser) error {
+	if s.User == "" || (s.URI == "" && s.Host == "") {
+		return errors.New("wrong secret content: user, and uri or host must be set")
+	}
+	return nil
+}
+
+func authSource(db string) string {
+	if db == "" {
+		return defaultAuthSource
+	}
+	return db
+}
+
+// connConfig returns the configuration of the user's connection.
+func (c dbClient) connConfig(s *SecretUser) ConnConfig {
+	return ConnConfig{
+		URI:            uri(s),
+		User:           s.User,
+		Password:       s.Password,
+		AuthSource:     authSource(s.AuthSource),
+		TLS:            c.tls,
+		RootCA:         c.rootCA,
+		ConnectTimeout: c.connectTimeout,
+	}
+}
+
+// adminConnConfig returns the configuration of the admin's connection,
+// the user's connection string is used if the admin secret's uri is not set.
+func (c dbClient) adminConnConfig(s *SecretUser) ConnConfig {
+	o := c.connConfig(s)
+	o.User = c.admin.User
+	o.Password = c.admin.Password
+	o.AuthSource = authSource(c.admin.AuthSource)
+	if c.admin.URI != "" {
+		o.URI = c.admin.URI
+	}
+	return o
+}
+
+// passwordLength the length of the user's password, it's used to derive the SCRAM credentials.
+const passwordLength = 32
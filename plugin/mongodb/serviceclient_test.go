@@ -0,0 +1,313 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// mockClient the Client which responds to the commands with fn, the commands are recorded.
+type mockClient struct {
+	cfg      ConnConfig
+	fn       func(db string, cmd bson.D) (bson.M, error)
+	commands *[]string
+}
+
+func (c mockClient) RunCommand(_ context.Context, db string, cmd any, result any) error {
+	d := cmd.(bson.D)
+	*c.commands = append(*c.commands, c.cfg.User+"@"+db+": "+d[0].Key)
+
+	resp, err := c.fn(db, d)
+	if err != nil {
+		return err
+	}
+
+	b, err := bson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(b, result)
+}
+
+func (c mockClient) Disconnect(context.Context) error {
+	return nil
+}
+
+// newMockConnector returns the Connector which opens mockClient, the commands of the users which are not listed fail
+// to authenticate.
+func newMockConnector(
+	fn map[string]func(db string, cmd bson.D) (bson.M, error), commands *[]string, cfgs *[]ConnConfig,
+) Connector {
+	return ConnectorFunc(
+		func(_ context.Context, cfg ConnConfig) (Client, error) {
+			if cfgs != nil {
+				*cfgs = append(*cfgs, cfg)
+			}
+			f, ok := fn[cfg.User]
+			if !ok {
+				f = func(string, bson.D) (bson.M, error) {
+					return nil, errors.New("(AuthenticationFailed) Authentication failed.")
+				}
+			}
+			return mockClient{cfg: cfg, fn: f, commands: commands}, nil
+		},
+	)
+}
+
+func respondConnectionStatus(user, db string, roles ...Role) func(string, bson.D) (bson.M, error) {
+	return func(string, bson.D) (bson.M, error) {
+		return bson.M{
+			"authInfo": bson.M{
+				"authenticatedUsers":     bson.A{bson.M{"user": user, "db": db}},
+				"authenticatedUserRoles": roles,
+			},
+			"ok": 1,
+		}, nil
+	}
+}
+
+func Test_dbClient_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path: host",
+			secret:  &SecretUser{User: "qux", Password: "quxx", Host: "localhost"},
+			wantErr: false,
+		},
+		{
+			name:    "happy path: uri",
+			secret:  &SecretUser{User: "qux", Password: "quxx", URI: "mongodb://node-1,node-2/?replicaSet=rs0"},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: missing host",
+			secret:  &SecretUser{User: "qux", Password: "quxx"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{User: "qux", Password: "quxx", Host: "localhost"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := NewServiceClient(SecretAdmin{}).Create(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && tt.secret.(*SecretUser).Password == "quxx" {
+					t.Errorf("Create() failed to mutate a SecretUser obj")
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Set(t *testing.T) {
+	updateUser := func(wantDB string) func(db string, cmd bson.D) (bson.M, error) {
+		return func(db string, cmd bson.D) (bson.M, error) {
+			want := bson.D{{Key: "updateUser", Value: "qux"}, {Key: "pwd", Value: "new"}}
+			if db != wantDB || !reflect.DeepEqual(cmd, want) {
+				return nil, errors.New("unexpected command")
+			}
+			return bson.M{"ok": 1}, nil
+		}
+	}
+
+	tests := []struct {
+		name          string
+		admin         SecretAdmin
+		fn            map[string]func(db string, cmd bson.D) (bson.M, error)
+		secretPending any
+		wantCfg       ConnConfig
+		wantErr       bool
+	}{
+		{
+			name:          "happy path: defaults",
+			admin:         SecretAdmin{User: "admin", Password: "bar"},
+			fn:            map[string]func(db string, cmd bson.D) (bson.M, error){"admin": updateUser("admin")},
+			secretPending: &SecretUser{User: "qux", Password: "new", Host: "localhost"},
+			wantCfg: ConnConfig{
+				URI: "mongodb://localhost:27017/", User: "admin", Password: "bar", AuthSource: "admin", TLS: true,
+				ConnectTimeout: DefaultConnectTimeout,
+			},
+			wantErr: false,
+		},
+		{
+			name:  "happy path: replica set, authSource set",
+			admin: SecretAdmin{User: "admin", Password: "bar", AuthSource: "users"},
+			fn:    map[string]func(db string, cmd bson.D) (bson.M, error){"admin": updateUser("app")},
+			secretPending: &SecretUser{
+				User: "qux", Password: "new", Host: "node-1", Port: 27018, ReplicaSet: "rs0", AuthSource: "app",
+			},
+			wantCfg: ConnConfig{
+				URI: "mongodb://node-1:27018/?replicaSet=rs0", User: "admin", Password: "bar", AuthSource: "users",
+				TLS: true, ConnectTimeout: DefaultConnectTimeout,
+			},
+			wantErr: false,
+		},
+		{
+			name:  "happy path: admin's uri",
+			admin: SecretAdmin{User: "admin", Password: "bar", URI: "mongodb+srv://cluster.example.com/"},
+			fn:    map[string]func(db string, cmd bson.D) (bson.M, error){"admin": updateUser("admin")},
+			secretPending: &SecretUser{
+				User: "qux", Password: "new", URI: "mongodb://node-1,node-2/?replicaSet=rs0",
+			},
+			wantCfg: ConnConfig{
+				URI: "mongodb+srv://cluster.example.com/", User: "admin", Password: "bar", AuthSource: "admin",
+				TLS: true, ConnectTimeout: DefaultConnectTimeout,
+			},
+			wantErr: false,
+		},
+		{
+			name:          "unhappy path: admin not authenticated",
+			admin:         SecretAdmin{User: "admin", Password: "bar"},
+			secretPending: &SecretUser{User: "qux", Password: "new", Host: "localhost"},
+			wantCfg: ConnConfig{
+				URI: "mongodb://localhost:27017/", User: "admin", Password: "bar", AuthSource: "admin", TLS: true,
+				ConnectTimeout: DefaultConnectTimeout,
+			},
+			wantErr: true,
+		},
+		{
+			name:          "unhappy path: empty password",
+			secretPending: &SecretUser{User: "qux", Host: "localhost"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong secret type",
+			secretPending: SecretUser{},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var (
+					commands []string
+					cfgs     []ConnConfig
+				)
+				c := NewServiceClient(tt.admin, WithConnector(newMockConnector(tt.fn, &commands, &cfgs)))
+				if err := c.Set(context.TODO(), nil, tt.secretPending, nil); (err != nil) != tt.wantErr {
+					t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				if tt.wantCfg.URI != "" && (len(cfgs) != 1 || !reflect.DeepEqual(cfgs[0], tt.wantCfg)) {
+					t.Errorf("Set() connection = %+v, want %+v", cfgs, tt.wantCfg)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Test(t *testing.T) {
+	roles := []Role{{Role: "readWrite", DB: "app"}}
+
+	tests := []struct {
+		name    string
+		fn      map[string]func(db string, cmd bson.D) (bson.M, error)
+		secret  any
+		wantErr bool
+	}{
+		{
+			name: "happy path",
+			fn: map[string]func(db string, cmd bson.D) (bson.M, error){
+				"qux": respondConnectionStatus("qux", "admin", Role{Role: "read", DB: "foo"}),
+			},
+			secret:  &SecretUser{User: "qux", Password: "new", Host: "localhost"},
+			wantErr: false,
+		},
+		{
+			name: "happy path: roles granted",
+			fn: map[string]func(db string, cmd bson.D) (bson.M, error){
+				"qux": respondConnectionStatus("qux", "app", Role{Role: "read", DB: "foo"}, roles[0]),
+			},
+			secret:  &SecretUser{User: "qux", Password: "new", Host: "localhost", AuthSource: "app", Roles: roles},
+			wantErr: false,
+		},
+		{
+			name: "unhappy path: role not granted",
+			fn: map[string]func(db string, cmd bson.D) (bson.M, error){
+				"qux": respondConnectionStatus("qux", "app", Role{Role: "read", DB: "app"}),
+			},
+			secret:  &SecretUser{User: "qux", Password: "new", Host: "localhost", AuthSource: "app", Roles: roles},
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: wrong authSource",
+			fn: map[string]func(db string, cmd bson.D) (bson.M, error){
+				"qux": respondConnectionStatus("qux", "admin"),
+			},
+			secret:  &SecretUser{User: "qux", Password: "new", Host: "localhost", AuthSource: "app"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: failed to authenticate",
+			secret:  &SecretUser{User: "qux", Password: "new", Host: "localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var commands []string
+				c := NewServiceClient(SecretAdmin{}, WithConnector(newMockConnector(tt.fn, &commands, nil)))
+				if err := c.Test(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				for _, cmd := range commands {
+					if !strings.HasPrefix(cmd, "qux@") || !strings.HasSuffix(cmd, ": connectionStatus") {
+						t.Errorf("Test() unexpected command %s", cmd)
+					}
+				}
+			},
+		)
+	}
+}
+
+func Test_uri(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret *SecretUser
+		want   string
+	}{
+		{
+			name:   "uri",
+			secret: &SecretUser{URI: "mongodb://node-1,node-2/?replicaSet=rs0", Host: "localhost"},
+			want:   "mongodb://node-1,node-2/?replicaSet=rs0",
+		},
+		{
+			name:   "host",
+			secret: &SecretUser{Host: "localhost"},
+			want:   "mongodb://localhost:27017/",
+		},
+		{
+			name:   "host, port and replica set",
+			secret: &SecretUser{Host: "::1", Port: 27018, ReplicaSet: "rs 0"},
+			want:   "mongodb://[::1]:27018/?replicaSet=rs+0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := uri(tt.secret); got != tt.want {
+					t.Errorf("uri() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DefaultTLS the default TLS mode of the database connection: the server's certificate is verified.
+const DefaultTLS = "true"
+
+// DefaultConnectTimeout the default timeout to establish the database connection.
+const DefaultConnectTimeout = 10 * time.Second
+
+// defaultPort the default port of the database.
+const defaultPort = 3306
+
+// ConnConfig defines the configuration of the database connection.
+type ConnConfig struct {
+	Host         string
+	Port         int
+	User         string
+	Password     string
+	DatabaseName string
+
+	// TLS the TLS mode: "true", "false", "skip-verify", or "preferred",
+	// see https://github.com/go-sql-driver/mysql#tls
+	TLS string
+
+	// RootCA PEM encoded certificates of the CAs to verify the server's certificate,
+	// the system's certificates pool is used if empty.
+	RootCA []byte
+
+	// ConnectTimeout the timeout to establish the connection.
+	ConnectTimeout time.Duration
+}
+
+// Connector opens the database connection.
+type Connector interface {
+	Open(cfg ConnConfig) (*sql.DB, error)
+}
+
+// ConnectorFunc the function which implements Connector.
+type ConnectorFunc func(cfg ConnConfig) (*sql.DB, error)
+
+func (f ConnectorFunc) Open(cfg ConnConfig) (*sql.DB, error) {
+	return f(cfg)
+}
+
+// MySQLConnector the Connector which uses the driver github.com/go-sql-driver/mysql.
+type MySQLConnector struct{}
+
+func (MySQLConnector) Open(cfg ConnConfig) (*sql.DB, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	c := mysql.NewConfig()
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	c.User = cfg.User
+	c.Passwd = cfg.Password
+	c.DBName = cfg.DatabaseName
+	c.Timeout = cfg.ConnectTimeout
+	c.TLSConfig = cfg.TLS
+
+	if len(cfg.RootCA) > 0 && cfg.TLS == "true" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.RootCA) {
+			return nil, errors.New("unable to add root CA to the certificates pool")
+		}
+		c.TLS = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	conn, err := mysql.NewConnector(c)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(conn), nil
+}
@@ -0,0 +1,28 @@
+module github.com/kislerdm/aws-lambda-secret-rotation/plugin/mysql
+
+go 1.19
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/aws/aws-lambda-go v1.37.0
+	github.com/aws/aws-sdk-go-v2/config v1.18.8
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.18.1
+	github.com/go-sql-driver/mysql v1.7.0
+	github.com/kislerdm/aws-lambda-secret-rotation v0.1.1
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.17.3 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.13.8 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.12.21 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.1.27 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.4.21 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/ini v1.3.28 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.9.21 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.12.0 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.14.0 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.18.0 // indirect
+	github.com/aws/smithy-go v1.13.5 // indirect
+)
+
+replace github.com/kislerdm/aws-lambda-secret-rotation => ../..
@@ -0,0 +1,35 @@
+package mysql
+
+// SecretAdmin defines the secret with the access details of the admin user which is authorized to alter the user,
+// e.g. the RDS master user.
+type SecretAdmin struct {
+	// User admin user
+	User string `json:"user"`
+	// Password admin user's password
+	Password string `json:"password"`
+	// Host database host, the host of SecretUser is used if empty
+	Host string `json:"host,omitempty"`
+	// Port database port, the port of SecretUser is used if empty
+	Port int `json:"port,omitempty"`
+	// TLS TLS mode of the connection, the TLS mode of SecretUser is used if empty
+	TLS string `json:"tls,omitempty"`
+}
+
+// SecretUser defines the secret with db user access details.
+type SecretUser struct {
+	// User database user
+	User string `json:"user"`
+	// Password user's password
+	Password string `json:"password"`
+	// Host database host
+	Host string `json:"host"`
+	// Port database port, 3306 by default
+	Port int `json:"port,omitempty"`
+	// DatabaseName database name, optional
+	DatabaseName string `json:"dbname,omitempty"`
+	// UserHost the host part of the user's account name, "%" by default, see
+	// https://dev.mysql.com/doc/refman/8.0/en/account-names.html
+	UserHost string `json:"user_host,omitempty"`
+	// TLS TLS mode of the connection, see WithTLS
+	TLS string `json:"tls,omitempty"`
+}
@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// TestServiceClient_mysql runs the rotation against the local MySQL defined by the env. variables:
+// MYSQL_HOST, MYSQL_PORT, MYSQL_USER and MYSQL_PASSWORD.
+func TestServiceClient_mysql(t *testing.T) {
+	host := os.Getenv("MYSQL_HOST")
+	if host == "" {
+		t.Skip("MYSQL_HOST is not set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("MYSQL_PORT"))
+
+	admin := SecretAdmin{User: os.Getenv("MYSQL_USER"), Password: os.Getenv("MYSQL_PASSWORD")}
+
+	c := NewServiceClient(admin, WithTLS("false")).(*dbClient)
+
+	db, err := MySQLConnector{}.Open(
+		ConnConfig{Host: host, Port: port, User: admin.User, Password: admin.Password, TLS: "false"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	dropUser(t, db, "rotation")
+	defer dropUser(t, db, "rotation")
+	if _, err := db.Exec("CREATE USER 'rotation'@'%' IDENTIFIED BY 'initial'"); err != nil {
+		t.Fatal(err)
+	}
+
+	current := &SecretUser{User: "rotation", Password: "initial", Host: host, Port: port, TLS: "false"}
+	secret := *current
+
+	if err := c.Create(context.TODO(), &secret); err != nil {
+		t.Fatalf("Create() unexpected error = %v", err)
+	}
+	if err := c.Set(context.TODO(), current, &secret, nil); err != nil {
+		t.Fatalf("Set() unexpected error = %v", err)
+	}
+	if err := c.Test(context.TODO(), &secret); err != nil {
+		t.Fatalf("Test() unexpected error = %v", err)
+	}
+
+	// the current password is retained until the step finishSecret
+	if err := c.Test(context.TODO(), current); err != nil {
+		t.Errorf("Test() current password unexpected error = %v", err)
+	}
+
+	if err := c.Finalize(context.TODO(), &secret, current); err != nil {
+		t.Fatalf("Finalize() unexpected error = %v", err)
+	}
+	if err := c.Test(context.TODO(), current); err == nil {
+		t.Errorf("Test() current password must be discarded upon Finalize()")
+	}
+	if err := c.Test(context.TODO(), &secret); err != nil {
+		t.Errorf("Test() unexpected error = %v", err)
+	}
+}
+
+func dropUser(t *testing.T, db *sql.DB, user string) {
+	t.Helper()
+	account, err := quoteLiteral(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DROP USER IF EXISTS " + account + "@'%'"); err != nil {
+		t.Fatal(err)
+	}
+}
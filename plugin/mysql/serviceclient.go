@@ -0,0 +1,325 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+// NewServiceClient initiates the `ServiceClient` to rotate the MySQL user's password.
+// The admin user is used to set the user's password upon the step setSecret.
+func NewServiceClient(admin SecretAdmin, opts ...Option) lambda.ServiceClient {
+	c := &dbClient{
+		admin:          admin,
+		dualPassword:   true,
+		connector:      MySQLConnector{},
+		tls:            DefaultTLS,
+		connectTimeout: DefaultConnectTimeout,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Option defines the option of the ServiceClient.
+type Option func(c *dbClient)
+
+// WithDualPassword sets whether the dual passwords are used if the server supports them, true by default.
+// The current password is retained as the secondary password upon the step setSecret, and discarded after the step
+// finishSecret. The password is changed with plain `ALTER USER` otherwise.
+func WithDualPassword(enabled bool) Option {
+	return func(c *dbClient) {
+		c.dualPassword = enabled
+	}
+}
+
+// WithConnector sets the Connector to open the database connections, MySQLConnector by default.
+func WithConnector(connector Connector) Option {
+	return func(c *dbClient) {
+		c.connector = connector
+	}
+}
+
+// WithTLS sets the TLS mode of the database connections, DefaultTLS by default.
+// It's overwritten by the secret's attribute `tls`.
+func WithTLS(mode string) Option {
+	return func(c *dbClient) {
+		c.tls = mode
+	}
+}
+
+// WithRootCA sets the PEM encoded certificates of the CAs to verify the database server's certificate,
+// e.g. the RDS certificates bundle. The system's certificates are used by default.
+func WithRootCA(pem []byte) Option {
+	return func(c *dbClient) {
+		c.rootCA = pem
+	}
+}
+
+// WithConnectTimeout sets the timeout to establish the database connections, DefaultConnectTimeout by default.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *dbClient) {
+		c.connectTimeout = timeout
+	}
+}
+
+type dbClient struct {
+	admin        SecretAdmin
+	dualPassword bool
+
+	connector      Connector
+	tls            string
+	rootCA         []byte
+	connectTimeout time.Duration
+}
+
+// Create generates the new password of the pending secret without connecting to the server,
+// the account is altered upon the step setSecret.
+func (c dbClient) Create(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if s.User == "" || s.Host == "" {
+		return errors.New("wrong secret content: user and host must be set")
+	}
+
+	password, err := lambda.GeneratePassword(passwordLength)
+	if err != nil {
+		return err
+	}
+
+	s.Password = password
+
+	return nil
+}
+
+// Set sets the pending password of the user using the admin user. If the server supports the dual passwords, the
+// current password is retained as the secondary password, hence the secret's version staged as AWSCURRENT stays valid
+// until the step finishSecret.
+// The step is skipped if the pending password is set already, i.e. the step can be safely retried.
+func (c dbClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	s, ok := secretPending.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if s.Password == "" {
+		return errors.New("pending secret is corrupt: password is empty")
+	}
+
+	user, err := account(s)
+	if err != nil {
+		return err
+	}
+	password, err := quoteLiteral(s.Password)
+	if err != nil {
+		return err
+	}
+
+	// the pending password must not be retained as the secondary password if the step is retried
+	if err := c.Test(ctx, s); err == nil {
+		return nil
+	}
+
+	db, err := c.openDBConnection(c.adminConnConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	dual, err := c.isDualPassword(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	statement := "ALTER USER " + user + " IDENTIFIED BY " + password
+	if dual {
+		statement += " RETAIN CURRENT PASSWORD"
+	}
+
+	_, err = db.ExecContext(ctx, statement)
+	return err
+}
+
+// Test verifies that the secret authenticates the user to the database: the current user and the database
+// must match the secret.
+func (c dbClient) Test(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	db, err := c.openDBConnection(c.connConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	var user, dbname string
+	if err := db.QueryRowContext(ctx, "SELECT CURRENT_USER(), COALESCE(DATABASE(), '')").Scan(
+		&user, &dbname,
+	); err != nil {
+		return err
+	}
+	if i := strings.LastIndex(user, "@"); i > -1 {
+		user = user[:i]
+	}
+	if user != s.User || dbname != s.DatabaseName {
+		return errors.New(
+			`connected as "` + user + `" to "` + dbname + `", want "` + s.User + `" to "` + s.DatabaseName + `"`,
+		)
+	}
+
+	return nil
+}
+
+// Finalize discards the secondary password of the user after the new password was promoted to AWSCURRENT
+// if the server supports the dual passwords.
+func (c dbClient) Finalize(ctx context.Context, secretCurrent, secretPrevious any) error {
+	s, ok := secretCurrent.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
+
+	user, err := account(s)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.openDBConnection(c.adminConnConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	dual, err := c.isDualPassword(ctx, db)
+	if err != nil || !dual {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "ALTER USER "+user+" DISCARD OLD PASSWORD")
+	return err
+}
+
+// isDualPassword reports whether the dual passwords are enabled and supported by the server.
+func (c dbClient) isDualPassword(ctx context.Context, db *sql.DB) (bool, error) {
+	if !c.dualPassword {
+		return false, nil
+	}
+
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return false, err
+	}
+	return supportsDualPassword(version), nil
+}
+
+// supportsDualPassword reports whether the server of the given version supports the dual passwords,
+// i.e. MySQL 8.0.14, or newer. MariaDB does not support them.
+func supportsDualPassword(version string) bool {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return false
+	}
+
+	if i := strings.IndexAny(version, "-+ "); i > -1 {
+		version = version[:i]
+	}
+
+	var v [3]int
+	for i, p := range strings.SplitN(version, ".", 3) {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return false
+		}
+		v[i] = n
+	}
+
+	for i, want := range [3]int{8, 0, 14} {
+		if v[i] != want {
+			return v[i] > want
+		}
+	}
+	return true
+}
+
+// account returns the quoted account name of the user, e.g. 'myuser'@'%'.
+func account(s *SecretUser) (string, error) {
+	host := s.UserHost
+	if host == "" {
+		host = "%"
+	}
+	user, err := quoteLiteral(s.User)
+	if err != nil {
+		return "", err
+	}
+	if host, err = quoteLiteral(host); err != nil {
+		return "", err
+	}
+	return user + "@" + host, nil
+}
+
+// quoteLiteral quotes the string literal, see https://dev.mysql.com/doc/refman/8.0/en/string-literals.html.
+// The quote is doubled which is valid in any SQL mode, the backslash is rejected because it's either an escape
+// character, or a literal character depending on the SQL mode NO_BACKSLASH_ESCAPES.
+func quoteLiteral(v string) (string, error) {
+	if strings.Contains(v, `\`) {
+		return "", errors.New("backslash is not supported in the user's name, host and password")
+	}
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+}
+
+// connConfig returns the configuration of the user's connection, the secret's attributes overwrite
+// the plugin configuration.
+func (c dbClient) connConfig(s *SecretUser) ConnConfig {
+	o := ConnConfig{
+		Host:           s.Host,
+		Port:           s.Port,
+		User:           s.User,
+		Password:       s.Password,
+		DatabaseName:   s.DatabaseName,
+		TLS:            c.tls,
+		RootCA:         c.rootCA,
+		ConnectTimeout: c.connectTimeout,
+	}
+	if s.TLS != "" {
+		o.TLS = s.TLS
+	}
+	return o
+}
+
+// adminConnConfig returns the configuration of the admin's connection,
+// the unset attributes of the admin secret default to the user's secret.
+func (c dbClient) adminConnConfig(s *SecretUser) ConnConfig {
+	o := c.connConfig(s)
+	o.User = c.admin.User
+	o.Password = c.admin.Password
+	o.DatabaseName = ""
+	if c.admin.Host != "" {
+		o.Host = c.admin.Host
+	}
+	if c.admin.Port > 0 {
+		o.Port = c.admin.Port
+	}
+	if c.admin.TLS != "" {
+		o.TLS = c.admin.TLS
+	}
+	return o
+}
+
+func (c dbClient) openDBConnection(cfg ConnConfig) (*sql.DB, error) {
+	if cfg.User == "" || cfg.Host == "" {
+		return nil, errors.New("failed to connect: host and user must be set")
+	}
+	return c.connector.Open(cfg)
+}
+
+// passwordLength the length of the generated password of the MySQL account.
+const passwordLength = 32
@@ -0,0 +1,363 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const placeholderPassword = "quxx"
+
+// newMockConnector returns the Connector to open the mock database connections with the expectations set per user.
+// The connection of the users which are not listed fails to authenticate.
+func newMockConnector(t *testing.T, expect map[string]func(m sqlmock.Sqlmock)) Connector {
+	t.Helper()
+	return ConnectorFunc(
+		func(cfg ConnConfig) (*sql.DB, error) {
+			db, m, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fn, ok := expect[cfg.User]; ok {
+				fn(m)
+			} else {
+				m.ExpectQuery(regexp.QuoteMeta("SELECT CURRENT_USER()")).
+					WillReturnError(errors.New("Error 1045: Access denied for user " + cfg.User))
+				m.ExpectClose()
+			}
+
+			t.Cleanup(
+				func() {
+					if err := m.ExpectationsWereMet(); err != nil {
+						t.Error(err)
+					}
+				},
+			)
+			return db, nil
+		},
+	)
+}
+
+func expectVersion(version string) func(m sqlmock.Sqlmock) {
+	return func(m sqlmock.Sqlmock) {
+		m.ExpectQuery(regexp.QuoteMeta("SELECT VERSION()")).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+	}
+}
+
+func expectIdentity(user, dbname string) func(m sqlmock.Sqlmock) {
+	return func(m sqlmock.Sqlmock) {
+		m.ExpectQuery(regexp.QuoteMeta("SELECT CURRENT_USER(), COALESCE(DATABASE(), '')")).
+			WillReturnRows(sqlmock.NewRows([]string{"user", "dbname"}).AddRow(user, dbname))
+		m.ExpectClose()
+	}
+}
+
+func Test_dbClient_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			secret:  &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost"},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: missing host",
+			secret:  &SecretUser{User: "qux", Password: placeholderPassword},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := NewServiceClient(SecretAdmin{}).Create(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && tt.secret.(*SecretUser).Password == placeholderPassword {
+					t.Errorf("Create() failed to mutate a SecretUser obj")
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Set(t *testing.T) {
+	secret := &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz"}
+
+	expectAlterUser := func(version, statement string) func(m sqlmock.Sqlmock) {
+		return func(m sqlmock.Sqlmock) {
+			if version != "" {
+				expectVersion(version)(m)
+			}
+			m.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectClose()
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		expect        map[string]func(m sqlmock.Sqlmock)
+		secretPending any
+		wantErr       bool
+	}{
+		{
+			name: "happy path: MySQL 8, current password retained",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": expectAlterUser(
+					"8.0.32", `ALTER USER 'qux'@'%' IDENTIFIED BY 'quxx' RETAIN CURRENT PASSWORD`,
+				),
+			},
+			secretPending: secret,
+			wantErr:       false,
+		},
+		{
+			name: "happy path: MySQL 5.7",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": expectAlterUser("5.7.41-log", `ALTER USER 'qux'@'%' IDENTIFIED BY 'quxx'`),
+			},
+			secretPending: secret,
+			wantErr:       false,
+		},
+		{
+			name: "happy path: MariaDB",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": expectAlterUser("10.11.2-MariaDB-1:10.11.2+maria~ubu2204", `ALTER USER 'qux'@'%' IDENTIFIED BY 'quxx'`),
+			},
+			secretPending: secret,
+			wantErr:       false,
+		},
+		{
+			name: "happy path: dual password disabled, user's host set",
+			opts: []Option{WithDualPassword(false)},
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": expectAlterUser("", `ALTER USER 'qux'@'10.0.%' IDENTIFIED BY 'quxx'`),
+			},
+			secretPending: &SecretUser{
+				User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz", UserHost: "10.0.%",
+			},
+			wantErr: false,
+		},
+		{
+			name: "happy path: pending password set already",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"qux": expectIdentity("qux@%", "baz"),
+			},
+			secretPending: secret,
+			wantErr:       false,
+		},
+		{
+			name: "unhappy path: failed to alter user",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": func(m sqlmock.Sqlmock) {
+					expectVersion("8.0.32")(m)
+					m.ExpectExec("ALTER USER").WillReturnError(errors.New("Error 1227: Access denied"))
+					m.ExpectClose()
+				},
+			},
+			secretPending: secret,
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: empty password",
+			secretPending: &SecretUser{User: "qux", Host: "localhost"},
+			wantErr:       true,
+		},
+		{
+			name: "unhappy path: backslash in the password",
+			secretPending: &SecretUser{
+				User: "qux", Password: `qu\xx`, Host: "localhost", DatabaseName: "baz",
+			},
+			wantErr: true,
+		},
+		{
+			name:          "unhappy path: wrong secret type",
+			secretPending: SecretUser{},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				opts := append([]Option{WithConnector(newMockConnector(t, tt.expect))}, tt.opts...)
+				c := NewServiceClient(SecretAdmin{User: "admin", Password: "bar"}, opts...)
+				if err := c.Set(context.TODO(), nil, tt.secretPending, nil); (err != nil) != tt.wantErr {
+					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Test(t *testing.T) {
+	secret := &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz"}
+
+	tests := []struct {
+		name    string
+		expect  map[string]func(m sqlmock.Sqlmock)
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			expect:  map[string]func(m sqlmock.Sqlmock){"qux": expectIdentity("qux@%", "baz")},
+			secret:  secret,
+			wantErr: false,
+		},
+		{
+			name:    "happy path: database not set",
+			expect:  map[string]func(m sqlmock.Sqlmock){"qux": expectIdentity("qux@10.0.%", "")},
+			secret:  &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost"},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: wrong user",
+			expect:  map[string]func(m sqlmock.Sqlmock){"qux": expectIdentity("admin@%", "baz")},
+			secret:  secret,
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: failed to authenticate",
+			secret:  secret,
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(SecretAdmin{}, WithConnector(newMockConnector(t, tt.expect)))
+				if err := c.Test(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Finalize(t *testing.T) {
+	secret := &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost"}
+
+	tests := []struct {
+		name    string
+		expect  map[string]func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "happy path: old password discarded",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": func(m sqlmock.Sqlmock) {
+					expectVersion("8.0.32")(m)
+					m.ExpectExec(regexp.QuoteMeta(`ALTER USER 'qux'@'%' DISCARD OLD PASSWORD`)).
+						WillReturnResult(sqlmock.NewResult(0, 0))
+					m.ExpectClose()
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "happy path: dual password not supported",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": func(m sqlmock.Sqlmock) {
+					expectVersion("10.6.12-MariaDB")(m)
+					m.ExpectClose()
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unhappy path: failed to discard old password",
+			expect: map[string]func(m sqlmock.Sqlmock){
+				"admin": func(m sqlmock.Sqlmock) {
+					expectVersion("8.0.32")(m)
+					m.ExpectExec("DISCARD OLD PASSWORD").WillReturnError(errors.New("Error 1227: Access denied"))
+					m.ExpectClose()
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(
+					SecretAdmin{User: "admin", Password: "bar"}, WithConnector(newMockConnector(t, tt.expect)),
+				).(*dbClient)
+				if err := c.Finalize(context.TODO(), secret, nil); (err != nil) != tt.wantErr {
+					t.Errorf("Finalize() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_supportsDualPassword(t *testing.T) {
+	for version, want := range map[string]bool{
+		"8.0.32":                  true,
+		"8.0.14":                  true,
+		"8.0.13":                  false,
+		"8.1.0":                   true,
+		"9.0.1-commercial":        true,
+		"8.0.23-mysql_aurora":     true,
+		"5.7.41-log":              false,
+		"10.11.2-MariaDB-1":       false,
+		"5.5.5-10.6.12-MariaDB":   false,
+		"foo":                     false,
+		"11.0.1-MariaDB-ubu2204+": false,
+	} {
+		if got := supportsDualPassword(version); got != want {
+			t.Errorf("supportsDualPassword(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func Test_quoteLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "happy path: quote doubled",
+			v:    `b'ar`,
+			want: `'b''ar'`,
+		},
+		{
+			name:    "unhappy path: backslash",
+			v:       `b\ar`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := quoteLiteral(tt.v)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("quoteLiteral() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("quoteLiteral() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
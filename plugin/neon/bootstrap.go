@@ -0,0 +1,120 @@
+package neon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	neon "github.com/kislerdm/neon-sdk-go"
+	"github.com/lib/pq"
+)
+
+// Bootstrap defines the access of the role created upon the step createSecret to the secret's database,
+// see WithBootstrap.
+type Bootstrap struct {
+	// Owner the role becomes the owner of the database
+	Owner bool
+	// Privileges the privileges on the database granted to the role: "CREATE", "CONNECT", "TEMPORARY", or "ALL"
+	Privileges []string
+}
+
+var databasePrivileges = map[string]struct{}{
+	"CREATE": {}, "CONNECT": {}, "TEMPORARY": {}, "TEMP": {}, "ALL": {}, "ALL PRIVILEGES": {},
+}
+
+// statements returns the SQL statements to grant the role access to the database.
+func (b Bootstrap) statements(role, dbname string) ([]string, error) {
+	var o []string
+	if b.Owner {
+		o = append(o, "ALTER DATABASE "+pq.QuoteIdentifier(dbname)+" OWNER TO "+pq.QuoteIdentifier(role))
+	}
+
+	if len(b.Privileges) > 0 {
+		privileges := make([]string, len(b.Privileges))
+		for i, p := range b.Privileges {
+			p = strings.ToUpper(strings.TrimSpace(p))
+			if _, ok := databasePrivileges[p]; !ok {
+				return nil, errors.New("unknown database privilege " + b.Privileges[i])
+			}
+			privileges[i] = p
+		}
+		o = append(
+			o, "GRANT "+strings.Join(privileges, ", ")+" ON DATABASE "+pq.QuoteIdentifier(dbname)+
+				" TO "+pq.QuoteIdentifier(role),
+		)
+	}
+
+	return o, nil
+}
+
+// ensureRole verifies that the user's role exists on the secret's branch, and that its password is not stored by
+// the Neon control plane, see verifyRoleUnmanaged. The role is created over SQL if it does not exist and the bootstrap
+// is enabled, see WithBootstrap.
+func (c dbClient) ensureRole(ctx context.Context, s *SecretUser) error {
+	if c.adminRole == "" {
+		return errors.New("admin role must be set to verify the user's role")
+	}
+
+	if err := c.verifyRoleUnmanaged(ctx, s); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := c.withAdminDB(
+		ctx, s, func(db *sql.DB) error {
+			return db.QueryRowContext(
+				ctx, "SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $1)", s.User,
+			).Scan(&exists)
+		},
+	); err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	if c.bootstrap == nil {
+		return errors.New(`role "` + s.User + `" not found on the branch ` + s.BranchID)
+	}
+
+	statements, err := c.bootstrap.statements(s.User, s.DatabaseName)
+	if err != nil {
+		return err
+	}
+
+	return c.execAdmin(
+		ctx, s, append([]string{"CREATE ROLE " + pq.QuoteIdentifier(s.User) + " WITH LOGIN"}, statements...)...,
+	)
+}
+
+// verifyRoleUnmanaged verifies that the Neon control plane does not store the password of the user's role on the
+// secret's branch. The stored password is restored upon the compute's restart, e.g. when it scales up from zero,
+// while the password set over SQL is not reported to the control plane, hence the rotated password would be reverted.
+// The roles created over SQL are not affected, e.g. the roles created upon the bootstrap, see WithBootstrap.
+func (c dbClient) verifyRoleUnmanaged(ctx context.Context, s *SecretUser) error {
+	err := c.retryLocked(
+		ctx, func() error {
+			_, err := c.c.GetProjectBranchRolePassword(s.ProjectID, s.BranchID, s.User)
+			return err
+		},
+	)
+	switch {
+	case err == nil:
+		return errors.New(
+			`password of the role "` + s.User + `" is stored by the Neon control plane on the branch ` + s.BranchID +
+				`, it would be restored upon the compute's restart: the role must be created over SQL`,
+		)
+	case isNotFound(err):
+		return nil
+	default:
+		return err
+	}
+}
+
+func isNotFound(err error) bool {
+	var e neon.Error
+	return errors.As(err, &e) && e.HTTPCode == http.StatusNotFound
+}
@@ -0,0 +1,139 @@
+package neon
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+func TestBootstrap_statements(t *testing.T) {
+	tests := []struct {
+		name      string
+		bootstrap Bootstrap
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "happy path: no access granted",
+			bootstrap: Bootstrap{},
+			want:      nil,
+		},
+		{
+			name:      "happy path: owner and privileges",
+			bootstrap: Bootstrap{Owner: true, Privileges: []string{"connect", " TEMPORARY"}},
+			want: []string{
+				`ALTER DATABASE "baz" OWNER TO "qux"`,
+				`GRANT CONNECT, TEMPORARY ON DATABASE "baz" TO "qux"`,
+			},
+		},
+		{
+			name:      "unhappy path: unknown privilege",
+			bootstrap: Bootstrap{Privileges: []string{"CONNECT", "SELECT; DROP DATABASE baz"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := tt.bootstrap.statements("qux", "baz")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("statements() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("statements() got = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Create_bootstrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		roles     []string
+		adminRole string
+		bootstrap *Bootstrap
+		expect    []func(m sqlmock.Sqlmock)
+		wantErr   bool
+	}{
+		{
+			name:      "happy path: role created, ownership granted",
+			adminRole: "admin",
+			bootstrap: &Bootstrap{Owner: true},
+			expect: []func(m sqlmock.Sqlmock){
+				expectRoleExists("qux", false),
+				func(m sqlmock.Sqlmock) {
+					m.ExpectExec(regexp.QuoteMeta(`CREATE ROLE "qux" WITH LOGIN`)).
+						WillReturnResult(sqlmock.NewResult(0, 0))
+					m.ExpectExec(regexp.QuoteMeta(`ALTER DATABASE "baz" OWNER TO "qux"`)).
+						WillReturnResult(sqlmock.NewResult(0, 0))
+					m.ExpectClose()
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "happy path: role exists",
+			adminRole: "admin",
+			bootstrap: &Bootstrap{Owner: true},
+			expect:    []func(m sqlmock.Sqlmock){expectRoleExists("qux", true)},
+			wantErr:   false,
+		},
+		{
+			name:      "unhappy path: role not found, bootstrap disabled",
+			adminRole: "admin",
+			expect:    []func(m sqlmock.Sqlmock){expectRoleExists("qux", false)},
+			wantErr:   true,
+		},
+		{
+			name:      "unhappy path: password stored by the Neon control plane",
+			roles:     []string{"qux"},
+			adminRole: "admin",
+			bootstrap: &Bootstrap{Owner: true},
+			wantErr:   true,
+		},
+		{
+			name:      "unhappy path: admin role not set",
+			bootstrap: &Bootstrap{Privileges: []string{"CONNECT"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				client := &stubNeonClient{
+					roles:     tt.roles,
+					endpoints: []neon.Endpoint{{ID: "ep-foo", Host: "ep-foo.eu-central-1.aws.neon.tech", Type: "read_write"}},
+				}
+				c := dbClient{
+					c:            client,
+					adminRole:    tt.adminRole,
+					bootstrap:    tt.bootstrap,
+					pollInterval: time.Millisecond,
+					connector:    newMockConnectorSequence(t, tt.expect...),
+				}
+
+				secret := &SecretUser{User: "qux", ProjectID: "foo", BranchID: "br-bar", DatabaseName: "baz"}
+				err := c.Create(context.TODO(), secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				if secret.Host != "ep-foo.eu-central-1.aws.neon.tech" {
+					t.Errorf("Create() host = %v, want the read-write endpoint's host", secret.Host)
+				}
+				if secret.Password == "" {
+					t.Errorf("Create() failed to generate the password")
+				}
+			},
+		)
+	}
+}
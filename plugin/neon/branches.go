@@ -0,0 +1,157 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path"
+	"strings"
+
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+// BranchFailurePolicy defines how the rotation handles the failures on the additional branches,
+// see the attributes `branch_ids` and `branch_name_pattern` of SecretUser.
+type BranchFailurePolicy string
+
+const (
+	// BranchFailurePolicyAbort the step fails if it fails on any branch. The password set upon the step setSecret
+	// is rolled back to the current password on all branches.
+	BranchFailurePolicyAbort BranchFailurePolicy = "abort"
+
+	// BranchFailurePolicyContinue the step fails only if it fails on the primary branch,
+	// the failures on the additional branches are logged.
+	BranchFailurePolicyContinue BranchFailurePolicy = "continue"
+)
+
+// BranchError defines the failure of the step on the branch.
+type BranchError struct {
+	BranchID string
+	Err      error
+}
+
+func (e *BranchError) Error() string {
+	return "branch " + e.BranchID + ": " + e.Err.Error()
+}
+
+func (e *BranchError) Unwrap() error {
+	return e.Err
+}
+
+// BranchErrors defines the failures of the step on the additional branches.
+type BranchErrors []*BranchError
+
+func (e BranchErrors) Error() string {
+	o := make([]string, len(e))
+	for i, err := range e {
+		o[i] = err.Error()
+	}
+	return strings.Join(o, "; ")
+}
+
+// branchSecret returns the secret to access the database on the additional branch.
+func branchSecret(s *SecretUser, branchID, host string) *SecretUser {
+	o := *s
+	o.BranchID = branchID
+	o.Host = host
+	o.Options = ""
+	o.Endpoints = nil
+	o.BranchIDs = nil
+	o.BranchNamePattern = ""
+	return &o
+}
+
+// branchIDs returns the IDs of the additional branches listed in the secret, or selected by the name pattern.
+func (c dbClient) branchIDs(ctx context.Context, s *SecretUser) ([]string, error) {
+	seen := map[string]struct{}{s.BranchID: {}}
+	var o []string
+	add := func(id string) {
+		if _, ok := seen[id]; !ok && id != "" {
+			seen[id] = struct{}{}
+			o = append(o, id)
+		}
+	}
+
+	for _, id := range s.BranchIDs {
+		add(id)
+	}
+
+	if s.BranchNamePattern == "" {
+		return o, nil
+	}
+
+	if _, err := path.Match(s.BranchNamePattern, ""); err != nil {
+		return nil, errors.New("wrong branch_name_pattern: " + err.Error())
+	}
+
+	var v neon.BranchesResponse
+	if err := c.retryLocked(
+		ctx, func() (err error) {
+			v, err = c.c.ListProjectBranches(s.ProjectID)
+			return err
+		},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range v.Branches {
+		if ok, _ := path.Match(s.BranchNamePattern, b.Name); ok {
+			add(b.ID)
+		}
+	}
+
+	return o, nil
+}
+
+// branchSecrets returns the secrets to access the database on the additional branches using their read-write
+// endpoints. The branches which endpoints cannot be found are reported as BranchErrors.
+func (c dbClient) branchSecrets(ctx context.Context, s *SecretUser) ([]*SecretUser, BranchErrors, error) {
+	ids, err := c.branchIDs(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		o    []*SecretUser
+		errs BranchErrors
+	)
+	for _, id := range ids {
+		var v neon.EndpointsResponse
+		if err := c.retryLocked(
+			ctx, func() (err error) {
+				v, err = c.c.ListProjectBranchEndpoints(s.ProjectID, id)
+				return err
+			},
+		); err != nil {
+			errs = append(errs, &BranchError{BranchID: id, Err: err})
+			continue
+		}
+
+		var host string
+		for _, e := range v.Endpoints {
+			if e.Type == "read_write" {
+				host = e.Host
+				break
+			}
+		}
+		if host == "" {
+			errs = append(errs, &BranchError{BranchID: id, Err: errors.New("read-write endpoint not found")})
+			continue
+		}
+
+		o = append(o, branchSecret(s, id, host))
+	}
+
+	return o, errs, nil
+}
+
+// handleBranchErrors returns the errors unless the failure policy is BranchFailurePolicyContinue.
+func (c dbClient) handleBranchErrors(step string, errs BranchErrors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if c.branchFailurePolicy == BranchFailurePolicyContinue {
+		log.Println("[WARN] " + step + " failed on the additional branches: " + errs.Error())
+		return nil
+	}
+	return errs
+}
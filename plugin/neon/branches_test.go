@@ -0,0 +1,403 @@
+package neon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	neon "github.com/kislerdm/neon-sdk-go"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func (s *stubNeonClient) ListProjectBranches(projectID string) (neon.BranchesResponse, error) {
+	if err := s.request(); err != nil {
+		return neon.BranchesResponse{}, err
+	}
+	return neon.BranchesResponse{Branches: s.branches}, nil
+}
+
+func newMockBranchesClient() *stubNeonClient {
+	return &stubNeonClient{
+		branches: []neon.Branch{
+			{ID: "br-main", Name: "main"},
+			{ID: "br-foo", Name: "preview/foo"},
+			{ID: "br-bar", Name: "preview/bar"},
+			{ID: "br-qux", Name: "staging"},
+		},
+		branchEndpoints: map[string][]neon.Endpoint{
+			"br-main": {{Host: "ep-main.us-east-2.aws.neon.tech", Type: "read_write"}},
+			"br-foo": {
+				{Host: "ep-foo-ro.us-east-2.aws.neon.tech", Type: "read_only"},
+				{Host: "ep-foo.us-east-2.aws.neon.tech", Type: "read_write"},
+			},
+			"br-bar": {{Host: "ep-bar.us-east-2.aws.neon.tech", Type: "read_write"}},
+			"br-qux": {{Host: "ep-qux.us-east-2.aws.neon.tech", Type: "read_write"}},
+			"br-ro":  {{Host: "ep-ro.us-east-2.aws.neon.tech", Type: "read_only"}},
+		},
+	}
+}
+
+func Test_dbClient_branchSecrets(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       *SecretUser
+		wantHosts    []string
+		wantBranches []string
+		wantErr      bool
+	}{
+		{
+			name:   "happy path: no additional branches",
+			secret: &SecretUser{BranchID: "br-main"},
+		},
+		{
+			name: "happy path: branch IDs and name pattern, deduplicated",
+			secret: &SecretUser{
+				BranchID: "br-main", BranchIDs: []string{"br-qux", "br-foo", "br-main"},
+				BranchNamePattern: "preview/*",
+			},
+			wantHosts: []string{
+				"ep-qux.us-east-2.aws.neon.tech", "ep-foo.us-east-2.aws.neon.tech", "ep-bar.us-east-2.aws.neon.tech",
+			},
+		},
+		{
+			name:         "unhappy path: read-write endpoint not found",
+			secret:       &SecretUser{BranchID: "br-main", BranchIDs: []string{"br-ro", "br-qux"}},
+			wantHosts:    []string{"ep-qux.us-east-2.aws.neon.tech"},
+			wantBranches: []string{"br-ro"},
+		},
+		{
+			name:    "unhappy path: wrong name pattern",
+			secret:  &SecretUser{BranchID: "br-main", BranchNamePattern: "preview/["},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := dbClient{c: newMockBranchesClient()}
+				got, errs, err := c.branchSecrets(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("branchSecrets() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				var hosts []string
+				for _, s := range got {
+					if s.BranchIDs != nil || s.BranchNamePattern != "" {
+						t.Errorf("branchSecrets() secret of the branch %s lists additional branches", s.BranchID)
+					}
+					hosts = append(hosts, s.Host)
+				}
+				if !reflect.DeepEqual(hosts, tt.wantHosts) {
+					t.Errorf("branchSecrets() hosts = %v, want %v", hosts, tt.wantHosts)
+				}
+
+				var branches []string
+				for _, e := range errs {
+					branches = append(branches, e.BranchID)
+				}
+				if !reflect.DeepEqual(branches, tt.wantBranches) {
+					t.Errorf("branchSecrets() failed branches = %v, want %v", branches, tt.wantBranches)
+				}
+			},
+		)
+	}
+}
+
+// scramPassword returns the password among the candidates which matches the SCRAM-SHA-256 verifier.
+func scramPassword(verifier string, candidates ...string) string {
+	v := regexp.MustCompile(`^SCRAM-SHA-256\$(\d+):([^$]+)\$([^:]+):`).FindStringSubmatch(verifier)
+	if v == nil {
+		return ""
+	}
+	iterations, _ := strconv.Atoi(v[1])
+	salt, _ := base64.StdEncoding.DecodeString(v[2])
+	for _, p := range candidates {
+		h := hmac.New(sha256.New, pbkdf2.Key([]byte(p), salt, iterations, sha256.Size, sha256.New))
+		h.Write([]byte("Client Key"))
+		if storedKey := sha256.Sum256(h.Sum(nil)); base64.StdEncoding.EncodeToString(storedKey[:]) == v[3] {
+			return p
+		}
+	}
+	return ""
+}
+
+// newMockAlterRoleConnector returns the Connector which records the passwords "pending" and "current" set per host,
+// the statement ALTER ROLE fails on the host failOn.
+func newMockAlterRoleConnector(t *testing.T, failOn string, passwords map[string][]string) Connector {
+	t.Helper()
+	re := regexp.MustCompile(`^ALTER ROLE "qux" WITH PASSWORD '([^']+)'$`)
+	return ConnectorFunc(
+		func(cfg ConnConfig) (*sql.DB, error) {
+			db, m, err := sqlmock.New(
+				sqlmock.QueryMatcherOption(
+					sqlmock.QueryMatcherFunc(
+						func(_, actual string) error {
+							v := re.FindStringSubmatch(actual)
+							if v == nil {
+								return errors.New("unexpected statement " + actual)
+							}
+							passwords[cfg.Host] = append(passwords[cfg.Host], scramPassword(v[1], "pending", "current"))
+							return nil
+						},
+					),
+				),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := m.ExpectExec("ALTER ROLE")
+			if cfg.Host == failOn {
+				e.WillReturnError(errors.New("permission denied to alter role"))
+			} else {
+				e.WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+			m.ExpectClose()
+
+			t.Cleanup(
+				func() {
+					if err := m.ExpectationsWereMet(); err != nil {
+						t.Error(err)
+					}
+				},
+			)
+			return db, nil
+		},
+	)
+}
+
+func Test_dbClient_Set_branches(t *testing.T) {
+	secretCurrent := &SecretUser{Password: "current"}
+	secretPending := &SecretUser{
+		User:         "qux",
+		Host:         "ep-main.us-east-2.aws.neon.tech",
+		DatabaseName: "baz",
+		ProjectID:    "foo",
+		BranchID:     "br-main",
+		BranchIDs:    []string{"br-foo", "br-bar", "br-qux"},
+		Password:     "pending",
+	}
+
+	tests := []struct {
+		name          string
+		policy        BranchFailurePolicy
+		failOn        string
+		want          map[string][]string
+		wantErr       bool
+		wantBranchErr bool
+	}{
+		{
+			name:   "happy path: password set on all branches",
+			policy: BranchFailurePolicyAbort,
+			want: map[string][]string{
+				"ep-main.us-east-2.aws.neon.tech": {"pending"},
+				"ep-foo.us-east-2.aws.neon.tech":  {"pending"},
+				"ep-bar.us-east-2.aws.neon.tech":  {"pending"},
+				"ep-qux.us-east-2.aws.neon.tech":  {"pending"},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "happy path: branch failed, policy continue",
+			policy: BranchFailurePolicyContinue,
+			failOn: "ep-bar.us-east-2.aws.neon.tech",
+			want: map[string][]string{
+				"ep-main.us-east-2.aws.neon.tech": {"pending"},
+				"ep-foo.us-east-2.aws.neon.tech":  {"pending"},
+				"ep-bar.us-east-2.aws.neon.tech":  {"pending"},
+				"ep-qux.us-east-2.aws.neon.tech":  {"pending"},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "unhappy path: branch failed, policy abort, password rolled back",
+			policy: BranchFailurePolicyAbort,
+			failOn: "ep-bar.us-east-2.aws.neon.tech",
+			want: map[string][]string{
+				"ep-main.us-east-2.aws.neon.tech": {"pending", "current"},
+				"ep-foo.us-east-2.aws.neon.tech":  {"pending", "current"},
+				"ep-bar.us-east-2.aws.neon.tech":  {"pending"},
+				"ep-qux.us-east-2.aws.neon.tech":  {"pending", "current"},
+			},
+			wantErr:       true,
+			wantBranchErr: true,
+		},
+		{
+			name:   "unhappy path: primary branch failed, policy continue",
+			policy: BranchFailurePolicyContinue,
+			failOn: "ep-main.us-east-2.aws.neon.tech",
+			want: map[string][]string{
+				"ep-main.us-east-2.aws.neon.tech": {"pending"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := map[string][]string{}
+				c := dbClient{
+					c:                   newMockBranchesClient(),
+					adminRole:           "admin",
+					pollInterval:        time.Millisecond,
+					branchFailurePolicy: tt.policy,
+					connector:           newMockAlterRoleConnector(t, tt.failOn, got),
+				}
+
+				err := c.Set(context.TODO(), secretCurrent, secretPending, nil)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Set() passwords = %v, want %v", got, tt.want)
+				}
+
+				var e BranchErrors
+				if errors.As(err, &e) != tt.wantBranchErr {
+					t.Errorf("Set() error = %v, want BranchErrors %v", err, tt.wantBranchErr)
+				}
+			},
+		)
+	}
+}
+
+// failingBranchesClient stubs the Neon API which fails to list the project's branches.
+type failingBranchesClient struct {
+	*stubNeonClient
+}
+
+func (failingBranchesClient) ListProjectBranches(string) (neon.BranchesResponse, error) {
+	return neon.BranchesResponse{}, neon.Error{HTTPCode: http.StatusInternalServerError}
+}
+
+func Test_dbClient_Set_branchesNotResolved(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  neon.Client
+		pattern string
+	}{
+		{
+			name:    "unhappy path: failed to list branches",
+			client:  failingBranchesClient{newMockBranchesClient()},
+			pattern: "preview/*",
+		},
+		{
+			name:    "unhappy path: malformed branch_name_pattern",
+			client:  newMockBranchesClient(),
+			pattern: "preview/[",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := map[string][]string{}
+				c := dbClient{
+					c:            tt.client,
+					adminRole:    "admin",
+					pollInterval: time.Millisecond,
+					connector:    newMockAlterRoleConnector(t, "", got),
+				}
+
+				secret := &SecretUser{
+					User:              "qux",
+					Host:              "ep-main.us-east-2.aws.neon.tech",
+					DatabaseName:      "baz",
+					ProjectID:         "foo",
+					BranchID:          "br-main",
+					BranchNamePattern: tt.pattern,
+					Password:          "pending",
+				}
+				if err := c.Set(context.TODO(), &SecretUser{Password: "current"}, secret, nil); err == nil {
+					t.Fatalf("Set() expected error")
+				}
+				if len(got) > 0 {
+					t.Errorf("Set() passwords = %v, want the password intact", got)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Test_branches(t *testing.T) {
+	secret := &SecretUser{
+		User:              "qux",
+		Host:              "ep-main.us-east-2.aws.neon.tech",
+		DatabaseName:      "baz",
+		ProjectID:         "foo",
+		BranchID:          "br-main",
+		BranchNamePattern: "preview/*",
+		Password:          placeholderPassword,
+	}
+
+	tests := []struct {
+		name    string
+		policy  BranchFailurePolicy
+		failOn  string
+		wantErr bool
+	}{
+		{
+			name:    "happy path: all branches tested",
+			policy:  BranchFailurePolicyAbort,
+			wantErr: false,
+		},
+		{
+			name:    "happy path: branch failed, policy continue",
+			policy:  BranchFailurePolicyContinue,
+			failOn:  "ep-bar.us-east-2.aws.neon.tech",
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: branch failed, policy abort",
+			policy:  BranchFailurePolicyAbort,
+			failOn:  "ep-bar.us-east-2.aws.neon.tech",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var hosts []string
+				c := dbClient{
+					c:                   newMockBranchesClient(),
+					branchFailurePolicy: tt.policy,
+					connector: ConnectorFunc(
+						func(cfg ConnConfig) (*sql.DB, error) {
+							hosts = append(hosts, cfg.Host)
+							if cfg.Host == tt.failOn {
+								return newMockConnector(t, expectIdentity("admin", "baz")).Open(cfg)
+							}
+							return newMockConnector(t, expectIdentity("qux", "baz")).Open(cfg)
+						},
+					),
+				}
+
+				err := c.Test(context.TODO(), secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				var e BranchErrors
+				if tt.wantErr && (!errors.As(err, &e) || len(e) != 1 || e[0].BranchID != "br-bar") {
+					t.Errorf("Test() error = %v, want failure on the branch br-bar", err)
+				}
+
+				wantHosts := []string{
+					"ep-main.us-east-2.aws.neon.tech", "ep-foo.us-east-2.aws.neon.tech", "ep-bar.us-east-2.aws.neon.tech",
+				}
+				if !reflect.DeepEqual(hosts, wantHosts) {
+					t.Errorf("Test() hosts = %v, want %v", hosts, wantHosts)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	dbclient "github.com/kislerdm/aws-lambda-secret-rotation/plugin/neon"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretRotation "github.com/kislerdm/aws-lambda-secret-rotation"
+)
+
+func main() {
+	cfgSecretsManager, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+
+	var s dbclient.SecretAdmin
+	handler, err := secretRotation.NewHandler(
+		secretRotation.Config{
+			SecretsmanagerClient: secretsmanager.NewFromConfig(cfgSecretsManager),
+			ServiceClient:        dbclient.NewAPIKeyServiceClient(nil, os.Getenv("KEY_NAME")),
+			SecretObj:            &s,
+			Debug:                secretRotation.StrToBool(os.Getenv("DEBUG")),
+		},
+	)
+	if err != nil {
+		log.Fatalf("unable to init lambda handler to rotate secret, %v", err)
+	}
+
+	lambda.Start(handler)
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	dbclient "github.com/kislerdm/aws-lambda-secret-rotation/plugin/neon"
@@ -28,28 +31,112 @@ func main() {
 
 	clientSecretsManager := secretsmanager.NewFromConfig(cfgSecretsManager)
 
-	v, err := clientSecretsManager.GetSecretValue(
-		context.Background(), &secretsmanager.GetSecretValueInput{SecretId: &secretAdminARN},
-	)
-	if err != nil {
-		log.Fatalln(err)
+	opts := []dbclient.Option{dbclient.WithAdminRole(os.Getenv("ADMIN_ROLE"))}
+	if v := os.Getenv("PROBES"); v != "" {
+		var probes []string
+		for _, statement := range strings.Split(v, ";") {
+			if statement = strings.TrimSpace(statement); statement != "" {
+				probes = append(probes, statement)
+			}
+		}
+		opts = append(opts, dbclient.WithProbes(probes...))
 	}
 
-	var adminSecret dbclient.SecretAdmin
-	if err := secretRotation.ExtractSecretObject(v, &adminSecret); err != nil {
-		log.Fatalln(err)
+	switch v := os.Getenv("CONNECTOR"); v {
+	case "", "pq":
+	case "pgx":
+		opts = append(opts, dbclient.WithConnector(dbclient.PGXConnector{}))
+	default:
+		log.Fatalf("unknown CONNECTOR %s, pq, or pgx expected", v)
+	}
+	if v := os.Getenv("SSL_MODE"); v != "" {
+		opts = append(opts, dbclient.WithSSLMode(v))
+	}
+	if v := os.Getenv("SSL_ROOT_CERT"); v != "" {
+		rootCA := []byte(v)
+		if !strings.HasPrefix(v, "-----BEGIN") {
+			if rootCA, err = os.ReadFile(v); err != nil {
+				log.Fatalf("unable to read SSL_ROOT_CERT, %v", err)
+			}
+		}
+		opts = append(opts, dbclient.WithRootCA(rootCA))
+	}
+	if v := os.Getenv("CONNECT_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("unable to parse CONNECT_TIMEOUT, %v", err)
+		}
+		opts = append(opts, dbclient.WithConnectTimeout(timeout))
+	}
+	if v := os.Getenv("POLL_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("unable to parse POLL_INTERVAL, %v", err)
+		}
+		opts = append(opts, dbclient.WithPollInterval(interval))
+	}
+	switch v := dbclient.BranchFailurePolicy(os.Getenv("BRANCH_FAILURE_POLICY")); v {
+	case "":
+	case dbclient.BranchFailurePolicyAbort, dbclient.BranchFailurePolicyContinue:
+		opts = append(opts, dbclient.WithBranchFailurePolicy(v))
+	default:
+		log.Fatalf("unknown BRANCH_FAILURE_POLICY %s, abort, or continue expected", v)
+	}
+	templates := dbclient.DefaultConnectionStringTemplates
+	for env, tmpl := range map[string]*string{
+		"URI_TEMPLATE":      &templates.URI,
+		"DSN_TEMPLATE":      &templates.DSN,
+		"JDBC_URL_TEMPLATE": &templates.JDBCURL,
+	} {
+		if v, ok := os.LookupEnv(env); ok {
+			*tmpl = v
+		}
+	}
+	opts = append(opts, dbclient.WithConnectionStringTemplates(templates))
+	if secretRotation.StrToBool(os.Getenv("BOOTSTRAP")) {
+		var bootstrap dbclient.Bootstrap
+		for _, grant := range strings.Split(os.Getenv("BOOTSTRAP_GRANT"), ",") {
+			switch grant = strings.TrimSpace(grant); {
+			case grant == "":
+			case strings.EqualFold(grant, "owner"):
+				bootstrap.Owner = true
+			default:
+				bootstrap.Privileges = append(bootstrap.Privileges, grant)
+			}
+		}
+		opts = append(opts, dbclient.WithBootstrap(bootstrap))
+	}
+	if secretRotation.StrToBool(os.Getenv("ENDPOINT_ID_OPTION")) {
+		opts = append(opts, dbclient.WithEndpointIDOption())
 	}
 
-	clientNeon, err := sdk.NewClient(sdk.WithAPIKey(adminSecret.Token))
-	if err != nil {
-		log.Fatalf("unable to init Neon SDK, %v", err)
+	// the token is read upon every invocation because it can be rotated by the lambda-apikey
+	newServiceClient := func(ctx context.Context) (secretRotation.ServiceClient, error) {
+		v, err := clientSecretsManager.GetSecretValue(
+			ctx, &secretsmanager.GetSecretValueInput{SecretId: &secretAdminARN},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		var adminSecret dbclient.SecretAdmin
+		if err := secretRotation.ExtractSecretObject(v, &adminSecret); err != nil {
+			return nil, err
+		}
+
+		clientNeon, err := sdk.NewClient(sdk.WithAPIKey(adminSecret.Token))
+		if err != nil {
+			return nil, errors.New("unable to init Neon SDK, " + err.Error())
+		}
+
+		return dbclient.NewServiceClient(clientNeon, opts...), nil
 	}
 
 	var s dbclient.SecretUser
 	handler, err := secretRotation.NewHandler(
 		secretRotation.Config{
 			SecretsmanagerClient: clientSecretsManager,
-			ServiceClient:        dbclient.NewServiceClient(clientNeon),
+			ServiceClient:        secretRotation.NewServiceClientPerInvocation(newServiceClient),
 			SecretObj:            &s,
 			Debug:                secretRotation.StrToBool(os.Getenv("DEBUG")),
 		},
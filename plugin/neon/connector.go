@@ -0,0 +1,40 @@
+package neon
+
+import (
+	"strings"
+
+	"github.com/kislerdm/aws-lambda-secret-rotation/plugin/postgres"
+)
+
+// DefaultSSLMode the default TLS mode of the database connection.
+const DefaultSSLMode = postgres.DefaultSSLMode
+
+// DefaultConnectTimeout the default timeout to establish the database connection.
+const DefaultConnectTimeout = postgres.DefaultConnectTimeout
+
+// ConnConfig defines the configuration of the database connection, the connectors are shared with the plugin
+// github.com/kislerdm/aws-lambda-secret-rotation/plugin/postgres. Its attribute Options, e.g.
+// "endpoint=ep-foo-123456", routes the connection to the Neon endpoint if the client does not support SNI.
+type ConnConfig = postgres.ConnConfig
+
+// Connector opens the database connection.
+type Connector = postgres.Connector
+
+// ConnectorFunc the function which implements Connector.
+type ConnectorFunc = postgres.ConnectorFunc
+
+// PQConnector the Connector which uses the driver github.com/lib/pq.
+type PQConnector = postgres.PQConnector
+
+// PGXConnector the Connector which uses the driver github.com/jackc/pgx.
+type PGXConnector = postgres.PGXConnector
+
+// endpointID returns the Neon endpoint ID given the endpoint's host,
+// e.g. "ep-foo-123456" for "ep-foo-123456-pooler.eu-central-1.aws.neon.tech".
+func endpointID(host string) string {
+	o := host
+	if i := strings.Index(o, "."); i > -1 {
+		o = o[:i]
+	}
+	return strings.TrimSuffix(o, "-pooler")
+}
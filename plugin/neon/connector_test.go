@@ -0,0 +1,106 @@
+package neon
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_dbClient_connConfig(t *testing.T) {
+	rootCA := []byte("foo")
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		secret *SecretUser
+		want   ConnConfig
+	}{
+		{
+			name:   "happy path: defaults",
+			secret: &SecretUser{Host: "ep-foo-123.eu-central-1.aws.neon.tech", DatabaseName: "baz"},
+			want: ConnConfig{
+				Host: "ep-foo-123.eu-central-1.aws.neon.tech", User: "qux", Password: "bar", DatabaseName: "baz",
+				SSLMode: DefaultSSLMode, ConnectTimeout: DefaultConnectTimeout,
+			},
+		},
+		{
+			name: "happy path: plugin configuration",
+			opts: []Option{
+				WithSSLMode("require"), WithRootCA(rootCA), WithConnectTimeout(time.Second), WithEndpointIDOption(),
+			},
+			secret: &SecretUser{Host: "ep-foo-123-pooler.eu-central-1.aws.neon.tech", DatabaseName: "baz"},
+			want: ConnConfig{
+				Host: "ep-foo-123-pooler.eu-central-1.aws.neon.tech", User: "qux", Password: "bar",
+				DatabaseName: "baz", SSLMode: "require", RootCA: rootCA, ConnectTimeout: time.Second,
+				Options: "endpoint=ep-foo-123",
+			},
+		},
+		{
+			name: "happy path: secret overwrites plugin configuration",
+			opts: []Option{
+				WithSSLMode("require"), WithRootCA(rootCA), WithConnectTimeout(time.Second), WithEndpointIDOption(),
+			},
+			secret: &SecretUser{
+				Host: "localhost", Port: 5433, DatabaseName: "baz", SSLMode: "disable", SSLRootCert: "bar",
+				ConnectTimeout: 3, Options: "-c search_path=foo",
+			},
+			want: ConnConfig{
+				Host: "localhost", Port: 5433, User: "qux", Password: "bar", DatabaseName: "baz", SSLMode: "disable",
+				RootCA: []byte("bar"), ConnectTimeout: 3 * time.Second, Options: "-c search_path=foo",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(newMockSDKClient(), tt.opts...).(*dbClient)
+				if got := c.connConfig(tt.secret, "qux", "bar"); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("connConfig() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+// TestConnector_Open_postgres runs against the local Postgres defined by the env. variables:
+// POSTGRES_HOST, POSTGRES_PORT, POSTGRES_USER, POSTGRES_PASSWORD and POSTGRES_DB.
+func TestConnector_Open_postgres(t *testing.T) {
+	host := os.Getenv("POSTGRES_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_HOST is not set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("POSTGRES_PORT"))
+
+	secret := &SecretUser{
+		User:         os.Getenv("POSTGRES_USER"),
+		Password:     os.Getenv("POSTGRES_PASSWORD"),
+		Host:         host,
+		Port:         port,
+		DatabaseName: os.Getenv("POSTGRES_DB"),
+		SSLMode:      "disable",
+	}
+
+	for _, connector := range []Connector{PQConnector{}, PGXConnector{}} {
+		c := NewServiceClient(
+			newMockSDKClient(), WithConnector(connector), WithProbes("SELECT 1 FROM pg_catalog.pg_roles LIMIT 0"),
+		)
+		if err := c.Test(context.TODO(), secret); err != nil {
+			t.Errorf("%T: Test() unexpected error = %v", connector, err)
+		}
+	}
+}
+
+func Test_endpointID(t *testing.T) {
+	for host, want := range map[string]string{
+		"ep-foo-123456.eu-central-1.aws.neon.tech":        "ep-foo-123456",
+		"ep-foo-123456-pooler.eu-central-1.aws.neon.tech": "ep-foo-123456",
+		"localhost": "localhost",
+	} {
+		if got := endpointID(host); got != want {
+			t.Errorf("endpointID(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
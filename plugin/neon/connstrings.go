@@ -0,0 +1,111 @@
+package neon
+
+import (
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/kislerdm/aws-lambda-secret-rotation/plugin/postgres"
+)
+
+// The default templates of the connection strings derived from the secret, see ConnectionStringTemplates.
+const (
+	DefaultURITemplate = `postgres://{{uriEscape .User}}:{{uriEscape .Password}}@{{.Host}}:{{.Port}}/` +
+		`{{uriEscape .DatabaseName}}?sslmode={{uriEscape .SSLMode}}{{with .Options}}&options={{uriEscape .}}{{end}}`
+
+	DefaultDSNTemplate = `host={{dsnValue .Host}} port={{.Port}} user={{dsnValue .User}} ` +
+		`password={{dsnValue .Password}} dbname={{dsnValue .DatabaseName}} sslmode={{dsnValue .SSLMode}}` +
+		`{{with .Options}} options={{dsnValue .}}{{end}}`
+
+	DefaultJDBCURLTemplate = `jdbc:postgresql://{{.Host}}:{{.Port}}/{{uriEscape .DatabaseName}}` +
+		`?user={{uriEscape .User}}&password={{uriEscape .Password}}&sslmode={{uriEscape .SSLMode}}` +
+		`{{with .Options}}&options={{uriEscape .}}{{end}}`
+)
+
+// defaultPort the default port of the database.
+const defaultPort = 5432
+
+// ConnectionStringTemplates defines the templates of the connection strings written to the secret upon rotation.
+// The templates follow the syntax of text/template and are executed with ConnectionStringData, the functions
+// "uriEscape" and "dsnValue" escape the values for URIs and for the keyword/value DSN respectively.
+// The connection string is omitted if its template is empty.
+type ConnectionStringTemplates struct {
+	// URI the template of the attribute `uri`, DefaultURITemplate by default
+	URI string
+	// DSN the template of the attribute `dsn`, DefaultDSNTemplate by default
+	DSN string
+	// JDBCURL the template of the attribute `jdbc_url`, DefaultJDBCURLTemplate by default
+	JDBCURL string
+}
+
+// DefaultConnectionStringTemplates the default templates of the connection strings.
+var DefaultConnectionStringTemplates = ConnectionStringTemplates{
+	URI:     DefaultURITemplate,
+	DSN:     DefaultDSNTemplate,
+	JDBCURL: DefaultJDBCURLTemplate,
+}
+
+// ConnectionStringData defines the values to execute ConnectionStringTemplates.
+type ConnectionStringData struct {
+	Host         string
+	Port         int
+	User         string
+	Password     string
+	DatabaseName string
+	SSLMode      string
+	Options      string
+}
+
+var connectionStringFuncs = template.FuncMap{
+	"uriEscape": uriEscape,
+	"dsnValue":  postgres.QuoteDSNValue,
+}
+
+// uriEscape percent-encodes all characters but the unreserved, see https://www.rfc-editor.org/rfc/rfc3986#section-2.3
+func uriEscape(v string) string {
+	return strings.ReplaceAll(url.QueryEscape(v), "+", "%20")
+}
+
+func executeConnectionStringTemplate(name, tmpl string, data ConnectionStringData) (string, error) {
+	if tmpl == "" {
+		return "", nil
+	}
+
+	t, err := template.New(name).Funcs(connectionStringFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var o strings.Builder
+	if err := t.Execute(&o, data); err != nil {
+		return "", err
+	}
+	return o.String(), nil
+}
+
+// setConnectionStrings derives the connection strings from the secret's attributes.
+func (c dbClient) setConnectionStrings(s *SecretUser) error {
+	cfg := c.connConfig(s, s.User, s.Password)
+	data := ConnectionStringData{
+		Host:         cfg.Host,
+		Port:         cfg.Port,
+		User:         cfg.User,
+		Password:     cfg.Password,
+		DatabaseName: cfg.DatabaseName,
+		SSLMode:      cfg.SSLMode,
+		Options:      cfg.Options,
+	}
+	if data.Port == 0 {
+		data.Port = defaultPort
+	}
+
+	var err error
+	if s.URI, err = executeConnectionStringTemplate("uri", c.connectionStringTemplates.URI, data); err != nil {
+		return err
+	}
+	if s.DSN, err = executeConnectionStringTemplate("dsn", c.connectionStringTemplates.DSN, data); err != nil {
+		return err
+	}
+	s.JDBCURL, err = executeConnectionStringTemplate("jdbc_url", c.connectionStringTemplates.JDBCURL, data)
+	return err
+}
@@ -0,0 +1,79 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+const poolerSuffix = "-pooler"
+
+// poolerHost returns the host of the endpoint's pooler,
+// e.g. "ep-foo-123456-pooler.eu-central-1.aws.neon.tech" for "ep-foo-123456.eu-central-1.aws.neon.tech".
+func poolerHost(host string) string {
+	i := strings.Index(host, ".")
+	if i < 0 {
+		i = len(host)
+	}
+	if strings.HasSuffix(host[:i], poolerSuffix) {
+		return host
+	}
+	return host[:i] + poolerSuffix + host[i:]
+}
+
+func isPoolerHost(host string) bool {
+	if i := strings.Index(host, "."); i > -1 {
+		host = host[:i]
+	}
+	return strings.HasSuffix(host, poolerSuffix)
+}
+
+// refreshEndpoints updates the hosts of the secret's endpoints given the endpoints of the branch.
+// The hosts which do not belong to the branch's endpoints, e.g. custom domains, are left intact.
+// The primary host is set to the host of the branch's read-write endpoint if empty.
+func (c dbClient) refreshEndpoints(ctx context.Context, s *SecretUser) error {
+	var o neon.EndpointsResponse
+	if err := c.retryLocked(
+		ctx, func() (err error) {
+			o, err = c.c.ListProjectBranchEndpoints(s.ProjectID, s.BranchID)
+			return err
+		},
+	); err != nil {
+		return err
+	}
+
+	hosts := make(map[string]string, len(o.Endpoints))
+	for _, e := range o.Endpoints {
+		hosts[e.ID] = e.Host
+		if s.Host == "" && e.Type == "read_write" {
+			s.Host = e.Host
+		}
+	}
+
+	refresh := func(id, host string, pooler bool) string {
+		v, ok := hosts[id]
+		if !ok || v == "" {
+			return host
+		}
+		if pooler {
+			return poolerHost(v)
+		}
+		return v
+	}
+
+	s.Host = refresh(endpointID(s.Host), s.Host, isPoolerHost(s.Host))
+
+	for i, e := range s.Endpoints {
+		id := e.ID
+		if id == "" {
+			id = endpointID(e.Host)
+		} else if _, ok := hosts[id]; !ok {
+			return errors.New("endpoint " + id + " not found on the branch " + s.BranchID)
+		}
+		s.Endpoints[i].Host = refresh(id, e.Host, e.Pooler || isPoolerHost(e.Host))
+	}
+
+	return nil
+}
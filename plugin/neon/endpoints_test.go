@@ -0,0 +1,168 @@
+package neon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+func Test_poolerHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"ep-foo-123456.eu-central-1.aws.neon.tech":        "ep-foo-123456-pooler.eu-central-1.aws.neon.tech",
+		"ep-foo-123456-pooler.eu-central-1.aws.neon.tech": "ep-foo-123456-pooler.eu-central-1.aws.neon.tech",
+		"localhost": "localhost-pooler",
+	} {
+		if got := poolerHost(host); got != want {
+			t.Errorf("poolerHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func Test_dbClient_refreshEndpoints(t *testing.T) {
+	endpoints := []neon.Endpoint{
+		{ID: "ep-foo-1", Host: "ep-foo-1.us-east-2.aws.neon.tech"},
+		{ID: "ep-bar-2", Host: "ep-bar-2.us-east-2.aws.neon.tech"},
+	}
+
+	tests := []struct {
+		name    string
+		secret  *SecretUser
+		want    *SecretUser
+		wantErr bool
+	}{
+		{
+			name: "happy path: hosts updated",
+			secret: &SecretUser{
+				Host: "ep-foo-1.eu-central-1.aws.neon.tech",
+				Endpoints: []Endpoint{
+					{Host: "ep-foo-1-pooler.eu-central-1.aws.neon.tech"},
+					{ID: "ep-bar-2", ReadOnly: true},
+					{ID: "ep-bar-2", Pooler: true, ReadOnly: true},
+				},
+			},
+			want: &SecretUser{
+				Host: "ep-foo-1.us-east-2.aws.neon.tech",
+				Endpoints: []Endpoint{
+					{Host: "ep-foo-1-pooler.us-east-2.aws.neon.tech"},
+					{ID: "ep-bar-2", Host: "ep-bar-2.us-east-2.aws.neon.tech", ReadOnly: true},
+					{ID: "ep-bar-2", Host: "ep-bar-2-pooler.us-east-2.aws.neon.tech", Pooler: true, ReadOnly: true},
+				},
+			},
+		},
+		{
+			name: "happy path: custom hosts left intact",
+			secret: &SecretUser{
+				Host:      "db.example.com",
+				Endpoints: []Endpoint{{Host: "pooler.example.com"}},
+			},
+			want: &SecretUser{
+				Host:      "db.example.com",
+				Endpoints: []Endpoint{{Host: "pooler.example.com"}},
+			},
+		},
+		{
+			name: "unhappy path: endpoint not found",
+			secret: &SecretUser{
+				Host:      "ep-foo-1.us-east-2.aws.neon.tech",
+				Endpoints: []Endpoint{{ID: "ep-qux-3"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := dbClient{c: &stubNeonClient{endpoints: endpoints}}
+				err := c.refreshEndpoints(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("refreshEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(tt.secret, tt.want) {
+					t.Errorf("refreshEndpoints() got = %+v, want %+v", tt.secret, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Test_endpoints(t *testing.T) {
+	secret := &SecretUser{
+		User:         "qux",
+		Host:         "ep-foo-1.us-east-2.aws.neon.tech",
+		DatabaseName: "baz",
+		Password:     placeholderPassword,
+		Endpoints: []Endpoint{
+			{Host: "ep-foo-1-pooler.us-east-2.aws.neon.tech"},
+			{Host: "ep-bar-2.us-east-2.aws.neon.tech", ReadOnly: true},
+			{Host: "ep-foo-1.us-east-2.aws.neon.tech"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		failOn    string
+		wantHosts []string
+		wantErr   bool
+	}{
+		{
+			name: "happy path: all endpoints tested",
+			wantHosts: []string{
+				"ep-foo-1.us-east-2.aws.neon.tech", "ep-foo-1-pooler.us-east-2.aws.neon.tech",
+				"ep-bar-2.us-east-2.aws.neon.tech",
+			},
+			wantErr: false,
+		},
+		{
+			name:   "unhappy path: read replica failed",
+			failOn: "ep-bar-2.us-east-2.aws.neon.tech",
+			wantHosts: []string{
+				"ep-foo-1.us-east-2.aws.neon.tech", "ep-foo-1-pooler.us-east-2.aws.neon.tech",
+				"ep-bar-2.us-east-2.aws.neon.tech",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var hosts []string
+				c := dbClient{
+					c: &stubNeonClient{},
+					connector: ConnectorFunc(
+						func(cfg ConnConfig) (*sql.DB, error) {
+							hosts = append(hosts, cfg.Host)
+							if cfg.Host == tt.failOn {
+								return newMockConnector(
+									t, func(m sqlmock.Sqlmock) {
+										m.ExpectQuery(regexp.QuoteMeta("SELECT current_user")).
+											WillReturnError(errors.New("password authentication failed"))
+										m.ExpectClose()
+									},
+								).Open(cfg)
+							}
+							return newMockConnector(t, expectIdentity("qux", "baz")).Open(cfg)
+						},
+					),
+				}
+
+				err := c.Test(context.TODO(), secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				var e *TestError
+				if tt.wantErr && (!errors.As(err, &e) || e.Host != tt.failOn) {
+					t.Errorf("Test() error = %v, want failure on %s", err, tt.failOn)
+				}
+				if !reflect.DeepEqual(hosts, tt.wantHosts) {
+					t.Errorf("Test() hosts = %v, want %v", hosts, tt.wantHosts)
+				}
+			},
+		)
+	}
+}
@@ -46,6 +46,13 @@ resource "neon_role" "this" {
   name       = "myrole"
 }
 
+# the role to set the password of the rotated role
+resource "neon_role" "admin" {
+  project_id = neon_project.this.id
+  branch_id  = neon_branch.this.id
+  name       = "myadmin"
+}
+
 resource "neon_database" "this" {
   project_id = neon_project.this.id
   branch_id  = neon_branch.this.id
@@ -204,6 +211,7 @@ resource "aws_lambda_function" "this" {
   environment {
     variables = {
       NEON_TOKEN_SECRET_ARN = aws_secretsmanager_secret.admin.arn
+      ADMIN_ROLE            = neon_role.admin.name
       DEBUG                 = "true"
     }
   }
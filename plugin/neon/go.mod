@@ -3,12 +3,15 @@ module github.com/kislerdm/aws-lambda-secret-rotation/plugin/neon
 go 1.19
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/aws/aws-lambda-go v1.37.0
 	github.com/aws/aws-sdk-go-v2/config v1.18.8
 	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.18.1
 	github.com/kislerdm/aws-lambda-secret-rotation v0.1.1
+	github.com/kislerdm/aws-lambda-secret-rotation/plugin/postgres v0.0.0-00010101000000-000000000000
 	github.com/kislerdm/neon-sdk-go v0.2.0
 	github.com/lib/pq v1.10.7
+	golang.org/x/crypto v0.6.0
 )
 
 require (
@@ -23,6 +26,12 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.14.0 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.18.0 // indirect
 	github.com/aws/smithy-go v1.13.5 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.3.1 // indirect
+	golang.org/x/text v0.7.0 // indirect
 )
 
 replace github.com/kislerdm/aws-lambda-secret-rotation => ../..
+
+replace github.com/kislerdm/aws-lambda-secret-rotation/plugin/postgres => ../postgres
@@ -4,6 +4,8 @@ package neon
 type SecretAdmin struct {
 	// Token Neon API token
 	Token string `json:"token"`
+	// KeyID Neon API key ID, it is required to revoke the key upon rotation
+	KeyID int64 `json:"key_id,omitempty"`
 }
 
 // SecretUser defines the secret with db user access details.
@@ -20,4 +22,51 @@ type SecretUser struct {
 	BranchID string `json:"branch_id"`
 	// DatabaseName Neon database name
 	DatabaseName string `json:"dbname"`
+	// Port database port, 5432 by default
+	Port int `json:"port,omitempty"`
+	// SSLMode TLS mode of the connection, see WithSSLMode
+	SSLMode string `json:"sslmode,omitempty"`
+	// SSLRootCert PEM encoded certificates of the CAs to verify the server's certificate, see WithRootCA
+	SSLRootCert string `json:"sslrootcert,omitempty"`
+	// ConnectTimeout timeout in seconds to establish the connection, see WithConnectTimeout
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	// Options connection parameter "options", e.g. "endpoint=ep-foo-123456", see WithEndpointIDOption
+	Options string `json:"options,omitempty"`
+	// BranchIDs IDs of the additional branches to rotate the user's password on, e.g. the preview branches
+	BranchIDs []string `json:"branch_ids,omitempty"`
+	// BranchNamePattern pattern of the additional branches' names, e.g. "preview/*", see path.Match for syntax
+	BranchNamePattern string `json:"branch_name_pattern,omitempty"`
+	// Endpoints additional endpoints of the branch to access database, e.g. the pooler, or read replicas
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+	// URI postgres URI derived from the secret upon rotation, see ConnectionStringTemplates
+	URI string `json:"uri,omitempty"`
+	// DSN keyword/value connection string derived from the secret upon rotation, see ConnectionStringTemplates
+	DSN string `json:"dsn,omitempty"`
+	// JDBCURL JDBC URL derived from the secret upon rotation, see ConnectionStringTemplates
+	JDBCURL string `json:"jdbc_url,omitempty"`
+}
+
+// Endpoint defines the Neon compute endpoint.
+type Endpoint struct {
+	// ID Neon endpoint ID, e.g. "ep-foo-123456", it's derived from the host if not set
+	ID string `json:"id,omitempty"`
+	// Host endpoint's host, it's updated upon rotation given the endpoint ID
+	Host string `json:"host"`
+	// Pooler connection via the pooler, i.e. using the host "{endpoint ID}-pooler.{region}.aws.neon.tech"
+	Pooler bool `json:"pooler,omitempty"`
+	// ReadOnly the endpoint is read replica
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// hosts returns the unique hosts of the secret's endpoints starting from the primary host.
+func (s SecretUser) hosts() []string {
+	o := []string{s.Host}
+	seen := map[string]struct{}{s.Host: {}}
+	for _, e := range s.Endpoints {
+		if _, ok := seen[e.Host]; !ok && e.Host != "" {
+			o = append(o, e.Host)
+			seen[e.Host] = struct{}{}
+		}
+	}
+	return o
 }
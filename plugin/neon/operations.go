@@ -0,0 +1,144 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+// DefaultPollInterval the default interval to poll the status of Neon operations.
+const DefaultPollInterval = time.Second
+
+// operationsListLimit the number of the latest project's operations to check for the running operations.
+const operationsListLimit = 100
+
+// The statuses of Neon operations, see https://neon.tech/docs/manage/operations#operation-statuses
+const (
+	operationStatusFinished  neon.OperationStatus = "finished"
+	operationStatusSkipped   neon.OperationStatus = "skipped"
+	operationStatusFailed    neon.OperationStatus = "failed"
+	operationStatusError     neon.OperationStatus = "error"
+	operationStatusCancelled neon.OperationStatus = "cancelled"
+)
+
+// OperationError defines the Neon operation which finished unsuccessfully.
+type OperationError struct {
+	Operation neon.Operation
+}
+
+func (e *OperationError) Error() string {
+	o := "neon operation " + e.Operation.ID + " " + string(e.Operation.Action) + " " + string(e.Operation.Status)
+	if e.Operation.Error != "" {
+		o += ": " + e.Operation.Error
+	}
+	return o
+}
+
+func isOperationTerminated(status neon.OperationStatus) bool {
+	switch status {
+	case operationStatusFinished, operationStatusSkipped, operationStatusFailed, operationStatusError,
+		operationStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitForOperations polls the Neon operations until they terminate.
+// The error *OperationError is returned if any operation did not finish successfully.
+func (c dbClient) waitForOperations(ctx context.Context, projectID string, operations []neon.Operation) error {
+	for _, op := range operations {
+		for !isOperationTerminated(op.Status) {
+			if err := sleep(ctx, c.pollInterval); err != nil {
+				return errors.New("neon operation " + op.ID + " " + string(op.Action) + " is " + string(op.Status) +
+					": " + err.Error())
+			}
+
+			if err := c.retryLocked(
+				ctx, func() error {
+					o, err := c.c.GetProjectOperation(projectID, op.ID)
+					if err == nil {
+						op = o.Operation
+					}
+					return err
+				},
+			); err != nil {
+				return err
+			}
+		}
+
+		switch op.Status {
+		case operationStatusFinished, operationStatusSkipped:
+		default:
+			return &OperationError{Operation: op}
+		}
+	}
+	return nil
+}
+
+// waitForBranchOperations waits for the running operations on the branch to terminate.
+func (c dbClient) waitForBranchOperations(ctx context.Context, projectID, branchID string) error {
+	var o neon.ListOperations
+	limit := operationsListLimit
+	if err := c.retryLocked(
+		ctx, func() (err error) {
+			o, err = c.c.ListProjectOperations(projectID, nil, &limit)
+			return err
+		},
+	); err != nil {
+		return err
+	}
+
+	var running []neon.Operation
+	for _, op := range o.Operations {
+		if op.BranchID == branchID && !isOperationTerminated(op.Status) {
+			running = append(running, op)
+		}
+	}
+
+	return c.waitForOperations(ctx, projectID, running)
+}
+
+// retryLocked calls fn until it does not fail because the project is locked by a concurrent operation.
+func (c dbClient) retryLocked(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		if !isProjectLocked(err) {
+			return err
+		}
+
+		if e := sleep(ctx, c.pollInterval); e != nil {
+			return errors.New(err.Error() + ": " + e.Error())
+		}
+	}
+}
+
+func isProjectLocked(err error) bool {
+	var e neon.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.HTTPCode == http.StatusLocked ||
+		(e.HTTPCode == http.StatusConflict && strings.Contains(strings.ToLower(e.Message), "locked"))
+}
+
+// sleep pauses for the duration d unless the context's deadline comes sooner.
+func sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return errors.New("deadline exceeded")
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
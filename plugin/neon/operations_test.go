@@ -0,0 +1,250 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+// stubNeonClient stubs the Neon API.
+type stubNeonClient struct {
+	neon.Client
+	// statuses the sequences of the statuses returned upon polling per operation ID
+	statuses map[string][]neon.OperationStatus
+	// operations the project's operations
+	operations []neon.Operation
+	// endpoints the branch's endpoints
+	endpoints []neon.Endpoint
+	// branchEndpoints the endpoints per branch ID, endpoints are returned for the branches not listed
+	branchEndpoints map[string][]neon.Endpoint
+	// branches the project's branches
+	branches []neon.Branch
+	// roles the names of the roles which passwords are stored by the Neon control plane, besides the admin role
+	roles []string
+	// locked the number of requests to fail because the project is locked
+	locked int
+	// err the error returned by the requests
+	err error
+	// requests the number of requests
+	requests int
+}
+
+func (s *stubNeonClient) request() error {
+	s.requests++
+	if s.locked > 0 {
+		s.locked--
+		return neon.Error{HTTPCode: http.StatusLocked}
+	}
+	return s.err
+}
+
+func (s *stubNeonClient) GetProjectOperation(projectID string, operationID string) (neon.OperationResponse, error) {
+	if err := s.request(); err != nil {
+		return neon.OperationResponse{}, err
+	}
+	v := s.statuses[operationID]
+	o := neon.Operation{ID: operationID, ProjectID: projectID, Status: v[0]}
+	if len(v) > 1 {
+		s.statuses[operationID] = v[1:]
+	}
+	return neon.OperationResponse{Operation: o}, nil
+}
+
+func (s *stubNeonClient) ListProjectOperations(projectID string, cursor *string, limit *int) (
+	neon.ListOperations, error,
+) {
+	if err := s.request(); err != nil {
+		return neon.ListOperations{}, err
+	}
+	var o neon.ListOperations
+	o.Operations = s.operations
+	return o, nil
+}
+
+func (s *stubNeonClient) ListProjectBranchEndpoints(projectID string, branchID string) (
+	neon.EndpointsResponse, error,
+) {
+	if err := s.request(); err != nil {
+		return neon.EndpointsResponse{}, err
+	}
+	if v, ok := s.branchEndpoints[branchID]; ok {
+		return neon.EndpointsResponse{Endpoints: v}, nil
+	}
+	return neon.EndpointsResponse{Endpoints: s.endpoints}, nil
+}
+
+func (s *stubNeonClient) GetProjectBranchRolePassword(projectID string, branchID string, roleName string) (
+	neon.RolePasswordResponse, error,
+) {
+	if err := s.request(); err != nil {
+		return neon.RolePasswordResponse{}, err
+	}
+	if roleName != "admin" {
+		var found bool
+		for _, v := range s.roles {
+			found = found || v == roleName
+		}
+		if !found {
+			return neon.RolePasswordResponse{}, neon.Error{HTTPCode: http.StatusNotFound}
+		}
+	}
+	return neon.RolePasswordResponse{Password: "admin-password"}, nil
+}
+
+func Test_dbClient_waitForOperations(t *testing.T) {
+	tests := []struct {
+		name         string
+		client       *stubNeonClient
+		operations   []neon.Operation
+		timeout      time.Duration
+		wantRequests int
+		wantErr      bool
+	}{
+		{
+			name:   "happy path: operations finished",
+			client: &stubNeonClient{},
+			operations: []neon.Operation{
+				{ID: "foo", Status: operationStatusFinished}, {ID: "bar", Status: operationStatusSkipped},
+			},
+			wantRequests: 0,
+			wantErr:      false,
+		},
+		{
+			name: "happy path: operations polled until finished",
+			client: &stubNeonClient{
+				statuses: map[string][]neon.OperationStatus{
+					"foo": {"running", operationStatusFinished},
+					"bar": {operationStatusFinished},
+				},
+			},
+			operations:   []neon.Operation{{ID: "foo", Status: "scheduling"}, {ID: "bar", Status: "running"}},
+			wantRequests: 3,
+			wantErr:      false,
+		},
+		{
+			name: "happy path: project locked",
+			client: &stubNeonClient{
+				statuses: map[string][]neon.OperationStatus{"foo": {operationStatusFinished}},
+				locked:   2,
+			},
+			operations:   []neon.Operation{{ID: "foo", Status: "running"}},
+			wantRequests: 3,
+			wantErr:      false,
+		},
+		{
+			name: "unhappy path: operation failed",
+			client: &stubNeonClient{
+				statuses: map[string][]neon.OperationStatus{"foo": {"running", operationStatusFailed}},
+			},
+			operations:   []neon.Operation{{ID: "foo", Status: "running"}},
+			wantRequests: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "unhappy path: request failed",
+			client:       &stubNeonClient{err: errors.New("foo")},
+			operations:   []neon.Operation{{ID: "foo", Status: "running"}},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name: "unhappy path: deadline exceeded",
+			client: &stubNeonClient{
+				statuses: map[string][]neon.OperationStatus{"foo": {"running"}},
+			},
+			operations: []neon.Operation{{ID: "foo", Status: "running"}},
+			timeout:    50 * time.Millisecond,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				ctx := context.TODO()
+				if tt.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+					defer cancel()
+				}
+
+				c := dbClient{c: tt.client, pollInterval: time.Millisecond}
+				err := c.waitForOperations(ctx, "foo", tt.operations)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("waitForOperations() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.timeout == 0 && tt.client.requests != tt.wantRequests {
+					t.Errorf("waitForOperations() requests = %d, want %d", tt.client.requests, tt.wantRequests)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Set_operations(t *testing.T) {
+	secret := &SecretUser{
+		User:         "qux",
+		Host:         "ep-foo.eu-central-1.aws.neon.tech",
+		DatabaseName: "baz",
+		ProjectID:    "foo",
+		BranchID:     "br-bar",
+		Password:     placeholderPassword,
+	}
+
+	client := &stubNeonClient{
+		operations: []neon.Operation{
+			{ID: "foo", BranchID: "br-bar", Status: "running"},
+			{ID: "bar", BranchID: "br-bar", Status: operationStatusFinished},
+			{ID: "baz", BranchID: "br-qux", Status: "running"},
+		},
+		statuses: map[string][]neon.OperationStatus{"foo": {"running", operationStatusFinished}},
+		locked:   1,
+	}
+
+	c := dbClient{
+		c:            client,
+		adminRole:    "admin",
+		pollInterval: time.Millisecond,
+		connector: newMockConnector(
+			t, func(m sqlmock.Sqlmock) {
+				m.ExpectExec(`ALTER ROLE "qux"`).WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectClose()
+			},
+		),
+	}
+
+	if err := c.Set(context.TODO(), nil, secret, nil); err != nil {
+		t.Fatalf("Set() unexpected error = %v", err)
+	}
+
+	// locked request to verify the user's role, its retry, the request to read the admin password,
+	// the list of operations, and two polls of the operation
+	if client.requests != 6 {
+		t.Errorf("Set() requests = %d, want 6", client.requests)
+	}
+}
+
+func Test_isProjectLocked(t *testing.T) {
+	locked := neon.Error{HTTPCode: http.StatusConflict}
+	locked.Message = "project already has running operations, project is locked"
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errors.New("foo"), want: false},
+		{err: neon.Error{HTTPCode: http.StatusLocked}, want: true},
+		{err: locked, want: true},
+		{err: neon.Error{HTTPCode: http.StatusConflict}, want: false},
+	}
+	for _, tt := range tests {
+		if got := isProjectLocked(tt.err); got != tt.want {
+			t.Errorf("isProjectLocked(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
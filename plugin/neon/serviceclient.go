@@ -4,96 +4,488 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+	"github.com/kislerdm/aws-lambda-secret-rotation/plugin/postgres"
 	neon "github.com/kislerdm/neon-sdk-go"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // NewServiceClient initiates the `ServiceClient` to rotate credentials for Neon user.
-func NewServiceClient(client neon.Client) lambda.ServiceClient {
-	return &dbClient{c: client}
+func NewServiceClient(client neon.Client, opts ...Option) lambda.ServiceClient {
+	c := &dbClient{
+		c:              client,
+		connector:      PQConnector{},
+		sslMode:        DefaultSSLMode,
+		connectTimeout: DefaultConnectTimeout,
+		pollInterval:   DefaultPollInterval,
+
+		branchFailurePolicy: BranchFailurePolicyAbort,
+
+		connectionStringTemplates: DefaultConnectionStringTemplates,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Option defines the option of the ServiceClient.
+type Option func(c *dbClient)
+
+// WithAdminRole sets the Neon role used to verify the user's role upon the step createSecret, and to set the new
+// password of the user upon the step setSecret.
+// The role must be authorized to alter the user's role, e.g. the project's owner role. Its password is retrieved
+// using the Neon API, hence the project must store the roles' passwords.
+func WithAdminRole(role string) Option {
+	return func(c *dbClient) {
+		c.adminRole = role
+	}
+}
+
+// WithProbes sets the SQL statements executed with the new password upon the step testSecret to verify the user's
+// privileges, e.g. "SELECT 1 FROM myschema.mytable LIMIT 0". The statements are executed in a read-only transaction
+// which is rolled back, the step fails if any statement fails.
+func WithProbes(statements ...string) Option {
+	return func(c *dbClient) {
+		c.probes = statements
+	}
+}
+
+// WithConnector sets the Connector to open the database connections, PQConnector by default.
+func WithConnector(connector Connector) Option {
+	return func(c *dbClient) {
+		c.connector = connector
+	}
+}
+
+// WithSSLMode sets the TLS mode of the database connections, DefaultSSLMode by default.
+// It's overwritten by the secret's attribute `sslmode`.
+func WithSSLMode(mode string) Option {
+	return func(c *dbClient) {
+		c.sslMode = mode
+	}
+}
+
+// WithRootCA sets the PEM encoded certificates of the CAs to verify the database server's certificate.
+// It's overwritten by the secret's attribute `sslrootcert`.
+func WithRootCA(pem []byte) Option {
+	return func(c *dbClient) {
+		c.rootCA = pem
+	}
+}
+
+// WithConnectTimeout sets the timeout to establish the database connections, DefaultConnectTimeout by default.
+// It's overwritten by the secret's attribute `connect_timeout`.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *dbClient) {
+		c.connectTimeout = timeout
+	}
+}
+
+// WithEndpointIDOption activates passing the Neon endpoint ID derived from the host as the connection parameter
+// "options", i.e. "endpoint=ep-foo-123456". It's required for the clients without SNI support.
+// The parameter is overwritten by the secret's attribute `options`.
+func WithEndpointIDOption() Option {
+	return func(c *dbClient) {
+		c.endpointIDOption = true
+	}
+}
+
+// WithPollInterval sets the interval to poll the status of Neon operations, DefaultPollInterval by default.
+// The requests failed because the project is locked by a concurrent operation are retried with the same interval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *dbClient) {
+		c.pollInterval = interval
+	}
+}
+
+// WithConnectionStringTemplates sets the templates of the connection strings written to the secret upon the step
+// createSecret, DefaultConnectionStringTemplates by default.
+func WithConnectionStringTemplates(templates ConnectionStringTemplates) Option {
+	return func(c *dbClient) {
+		c.connectionStringTemplates = templates
+	}
+}
+
+// WithBootstrap enables the creation of the user's role upon the step createSecret if the role does not exist.
+// The role is created over SQL using the admin role, hence its password is not stored by the Neon control plane.
+// The role is granted the ownership, or the privileges on the secret's database using the admin role,
+// see WithAdminRole.
+func WithBootstrap(bootstrap Bootstrap) Option {
+	return func(c *dbClient) {
+		c.bootstrap = &bootstrap
+	}
+}
+
+// WithBranchFailurePolicy sets how the rotation handles the failures on the additional branches,
+// BranchFailurePolicyAbort by default.
+func WithBranchFailurePolicy(policy BranchFailurePolicy) Option {
+	return func(c *dbClient) {
+		c.branchFailurePolicy = policy
+	}
 }
 
 type dbClient struct {
-	c neon.Client
+	c                   neon.Client
+	adminRole           string
+	probes              []string
+	pollInterval        time.Duration
+	branchFailurePolicy BranchFailurePolicy
+	bootstrap           *Bootstrap
+
+	connectionStringTemplates ConnectionStringTemplates
+
+	connector        Connector
+	sslMode          string
+	rootCA           []byte
+	connectTimeout   time.Duration
+	endpointIDOption bool
 }
 
+// Set sets the pending password of the user using the admin role, see WithAdminRole.
+// The additional branches are resolved before any password is changed, hence the step fails without side effects
+// if the branches cannot be listed, or branch_name_pattern is malformed.
+// The password is set on the primary branch first, and on the additional branches afterwards. If it fails on any
+// additional branch, the password is rolled back to the current password unless the failure policy is
+// BranchFailurePolicyContinue, see WithBranchFailurePolicy.
+// The password is set only if the Neon control plane does not store the role's password on the branch, because
+// the stored password would be restored upon the compute's restart, see verifyRoleUnmanaged.
+// A retry sets the same password again on every branch.
 func (c dbClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
-	return nil
+	s, ok := secretPending.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if c.adminRole == "" {
+		return errors.New("admin role must be set to set the user's password")
+	}
+
+	if s.Password == "" {
+		return errors.New("pending secret is corrupt: password is empty")
+	}
+
+	if err := c.verifyRoleUnmanaged(ctx, s); err != nil {
+		return err
+	}
+
+	branches, errs, err := c.branchSecrets(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	var targets []*SecretUser
+	for _, b := range branches {
+		if err := c.verifyRoleUnmanaged(ctx, b); err != nil {
+			errs = append(errs, &BranchError{BranchID: b.BranchID, Err: err})
+			continue
+		}
+		targets = append(targets, b)
+	}
+
+	if err := c.setPassword(ctx, s, s.Password); err != nil {
+		return err
+	}
+
+	done := []*SecretUser{s}
+	for _, b := range targets {
+		if err := c.setPassword(ctx, b, s.Password); err != nil {
+			errs = append(errs, &BranchError{BranchID: b.BranchID, Err: err})
+			continue
+		}
+		done = append(done, b)
+	}
+
+	if len(errs) > 0 && c.branchFailurePolicy != BranchFailurePolicyContinue {
+		if current, ok := secretCurrent.(*SecretUser); ok && current.Password != "" {
+			for _, b := range done {
+				if err := c.setPassword(ctx, b, current.Password); err != nil {
+					errs = append(errs, &BranchError{BranchID: b.BranchID, Err: errors.New("rollback: " + err.Error())})
+				}
+			}
+		}
+	}
+
+	return c.handleBranchErrors("setSecret", errs)
 }
 
-func (c dbClient) Test(ctx context.Context, secret any) error {
-	db, err := c.openDBConnection(secret)
+// setPassword sets the user's password on the secret's branch. The password is sent as the SCRAM-SHA-256 verifier,
+// hence the plaintext password does not appear in the server's logs.
+func (c dbClient) setPassword(ctx context.Context, s *SecretUser, password string) error {
+	verifier, err := postgres.ScramSHA256(password)
+	if err != nil {
+		return err
+	}
+	return c.execAdmin(
+		ctx, s, "ALTER ROLE "+pq.QuoteIdentifier(s.User)+" WITH PASSWORD "+pq.QuoteLiteral(verifier),
+	)
+}
+
+// execAdmin executes the statements on the secret's branch using the admin role, see WithAdminRole.
+func (c dbClient) execAdmin(ctx context.Context, s *SecretUser, statements ...string) error {
+	return c.withAdminDB(
+		ctx, s, func(db *sql.DB) error {
+			for _, statement := range statements {
+				if _, err := db.ExecContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// withAdminDB calls fn with the connection to the database on the secret's branch using the admin role,
+// see WithAdminRole.
+func (c dbClient) withAdminDB(ctx context.Context, s *SecretUser, fn func(db *sql.DB) error) error {
+	var o neon.RolePasswordResponse
+	if err := c.retryLocked(
+		ctx, func() (err error) {
+			o, err = c.c.GetProjectBranchRolePassword(s.ProjectID, s.BranchID, c.adminRole)
+			return err
+		},
+	); err != nil {
+		return err
+	}
+
+	// the concurrent operations on the branch, e.g. the compute endpoint's restart, may interrupt the connection
+	if err := c.waitForBranchOperations(ctx, s.ProjectID, s.BranchID); err != nil {
+		return err
+	}
+
+	db, err := c.openDBConnection(s, c.adminRole, o.Password)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = db.Close() }()
 
-	return db.PingContext(ctx)
+	return fn(db)
 }
 
-func (c dbClient) Create(ctx context.Context, secret any) error {
+// Test verifies that the secret authenticates the user to the database: the current user and the database
+// must match the secret. The privileges probes are executed afterwards, see WithProbes.
+// The checks run against every endpoint of the primary branch, and against the additional branches.
+// The returned error is of the type *TestError unless the secret is corrupt, or the checks failed on the additional
+// branches, see BranchErrors.
+func (c dbClient) Test(ctx context.Context, secret any) error {
 	s, ok := secret.(*SecretUser)
 	if !ok {
 		return errors.New("wrong secret type")
 	}
 
-	o, err := c.c.ResetProjectBranchRolePassword(s.ProjectID, s.BranchID, s.User)
+	if err := c.testBranch(ctx, s); err != nil {
+		return err
+	}
+
+	branches, errs, err := c.branchSecrets(ctx, s)
 	if err != nil {
 		return err
 	}
 
-	s.Password = o.RoleResponse.Role.Password
+	for _, b := range branches {
+		if err := c.testBranch(ctx, b); err != nil {
+			errs = append(errs, &BranchError{BranchID: b.BranchID, Err: err})
+		}
+	}
+
+	return c.handleBranchErrors("testSecret", errs)
+}
+
+// testBranch runs the checks of the step testSecret against the endpoints of the secret's branch.
+func (c dbClient) testBranch(ctx context.Context, s *SecretUser) error {
+	if err := c.waitForBranchOperations(ctx, s.ProjectID, s.BranchID); err != nil {
+		return err
+	}
+
+	for _, host := range s.hosts() {
+		if err := c.testHost(ctx, s, host); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-type db interface {
-	Close() error
-	PingContext(ctx context.Context) error
+// testHost runs the checks of the step testSecret against the endpoint's host.
+func (c dbClient) testHost(ctx context.Context, s *SecretUser, host string) error {
+	e := *s
+	e.Host = host
+
+	db, err := c.openDBConnection(&e, s.User, s.Password)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	const statementIdentity = "SELECT current_user, current_database()"
+
+	var user, dbname string
+	if err := db.QueryRowContext(ctx, statementIdentity).Scan(&user, &dbname); err != nil {
+		return &TestError{Check: CheckConnection, Host: host, Statement: statementIdentity, Err: err}
+	}
+	if user != s.User || dbname != s.DatabaseName {
+		return &TestError{
+			Check:     CheckIdentity,
+			Host:      host,
+			Statement: statementIdentity,
+			Err: errors.New(
+				`connected as "` + user + `" to "` + dbname + `", want "` + s.User + `" to "` + s.DatabaseName + `"`,
+			),
+		}
+	}
+
+	if len(c.probes) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return &TestError{Check: CheckProbe, Host: host, Err: err}
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, statement := range c.probes {
+		if err := probe(ctx, tx, statement); err != nil {
+			return &TestError{Check: CheckProbe, Host: host, Statement: statement, Err: err}
+		}
+	}
+
+	return nil
 }
 
-type mockDB struct {
-	FailedPing bool
+func probe(ctx context.Context, tx *sql.Tx, statement string) error {
+	rows, err := tx.QueryContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
 }
 
-func (m mockDB) Close() error {
-	return nil
+// Checks of the step testSecret.
+const (
+	// CheckConnection the connection to the database with the new password
+	CheckConnection = "connection"
+	// CheckIdentity the user and the database the connection is established to
+	CheckIdentity = "identity"
+	// CheckProbe the privileges probe, see WithProbes
+	CheckProbe = "probe"
+)
+
+// TestError defines the failure of the step testSecret.
+type TestError struct {
+	// Check the failed check, e.g. CheckIdentity
+	Check string
+	// Host the host of the endpoint the check failed on
+	Host string
+	// Statement the failed SQL statement
+	Statement string
+	// Err the cause of the failure
+	Err error
 }
 
-func (m mockDB) PingContext(ctx context.Context) error {
-	if m.FailedPing {
-		return errors.New("failed to query")
+func (e *TestError) Error() string {
+	o := e.Check + " check failed"
+	if e.Host != "" {
+		o += " on " + e.Host
 	}
-	return nil
+	if e.Statement != "" {
+		o += ` executing "` + e.Statement + `"`
+	}
+	return o + ": " + e.Err.Error()
+}
+
+func (e *TestError) Unwrap() error {
+	return e.Err
 }
 
-func (c dbClient) openDBConnection(secret any) (db, error) {
+// Create prepares the pending secret without touching the role's password: it refreshes the branch's endpoints
+// and generates the new password locally, the Neon API's password reset is not used.
+// The role is created if it does not exist and the bootstrap is enabled, see WithBootstrap. The step fails if the Neon
+// control plane stores the role's password, see verifyRoleUnmanaged.
+// The connection strings are derived from the secret with the new password, see WithConnectionStringTemplates.
+func (c dbClient) Create(ctx context.Context, secret any) error {
 	s, ok := secret.(*SecretUser)
 	if !ok {
-		return nil, errors.New("wrong secret type")
+		return errors.New("wrong secret type")
 	}
 
-	if s.User == "" || s.DatabaseName == "" || s.Host == "" {
-		return nil, errors.New("failed to connect")
+	if s.User == "" || s.ProjectID == "" || s.BranchID == "" {
+		return errors.New("wrong secret content: user, project_id and branch_id must be set")
 	}
 
-	connStr := "user=" + s.User +
-		" dbname=" + s.DatabaseName +
-		" host=" + s.Host +
-		" sslmode=verify-full"
+	if err := c.refreshEndpoints(ctx, s); err != nil {
+		return err
+	}
 
-	if s.Password != "" {
-		connStr += " password=" + s.Password
+	if err := c.ensureRole(ctx, s); err != nil {
+		return err
 	}
 
-	if s.Host == "dev" {
-		if s.DatabaseName == "fail" {
-			return mockDB{FailedPing: true}, nil
+	branches, errs, err := c.branchSecrets(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, b := range branches {
+		if err := c.ensureRole(ctx, b); err != nil {
+			errs = append(errs, &BranchError{BranchID: b.BranchID, Err: err})
 		}
-		return mockDB{}, nil
+	}
+	if err := c.handleBranchErrors("createSecret", errs); err != nil {
+		return err
+	}
+
+	password, err := lambda.GeneratePassword(passwordLength)
+	if err != nil {
+		return err
+	}
+
+	s.Password = password
+
+	return c.setConnectionStrings(s)
+}
+
+// passwordLength the length of the role's password generated upon the step createSecret.
+const passwordLength = 32
+
+func (c dbClient) openDBConnection(s *SecretUser, user, password string) (*sql.DB, error) {
+	if user == "" || s.DatabaseName == "" || s.Host == "" {
+		return nil, errors.New("failed to connect")
+	}
+
+	return c.connector.Open(c.connConfig(s, user, password))
+}
+
+// connConfig defines the database connection's configuration given the secret and the plugin's configuration.
+func (c dbClient) connConfig(s *SecretUser, user, password string) ConnConfig {
+	o := ConnConfig{
+		Host:           s.Host,
+		Port:           s.Port,
+		User:           user,
+		Password:       password,
+		DatabaseName:   s.DatabaseName,
+		SSLMode:        c.sslMode,
+		RootCA:         c.rootCA,
+		ConnectTimeout: c.connectTimeout,
+		Options:        s.Options,
+	}
+
+	if s.SSLMode != "" {
+		o.SSLMode = s.SSLMode
+	}
+	if s.SSLRootCert != "" {
+		o.RootCA = []byte(s.SSLRootCert)
+	}
+	if s.ConnectTimeout > 0 {
+		o.ConnectTimeout = time.Duration(s.ConnectTimeout) * time.Second
+	}
+	if o.Options == "" && c.endpointIDOption {
+		o.Options = "endpoint=" + endpointID(s.Host)
 	}
 
-	return sql.Open("postgres", connStr)
+	return o
 }
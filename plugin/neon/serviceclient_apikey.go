@@ -0,0 +1,167 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+	neon "github.com/kislerdm/neon-sdk-go"
+)
+
+// DefaultAPIKeyName the name assigned to the Neon API key generated upon rotation.
+const DefaultAPIKeyName = "aws-lambda-secret-rotation"
+
+// NewClientFn defines the function to initialise the Neon SDK client authenticated with the API key.
+type NewClientFn func(key string) (neon.Client, error)
+
+// NewAPIKeyServiceClient initiates the `ServiceClient` to rotate the Neon API key stored as `SecretAdmin`.
+// The Neon SDK client is initialised by newClient using the key from the secret to rotate,
+// i.e. the current key is used to generate a new key which is used to revoke the current key
+// once it's moved to the stage AWSPREVIOUS.
+func NewAPIKeyServiceClient(newClient NewClientFn, keyName string) lambda.ServiceClient {
+	if newClient == nil {
+		newClient = func(key string) (neon.Client, error) {
+			return neon.NewClient(neon.WithAPIKey(key))
+		}
+	}
+	if keyName == "" {
+		keyName = DefaultAPIKeyName
+	}
+	return &apiKeyClient{newClient: newClient, keyName: keyName}
+}
+
+type apiKeyClient struct {
+	newClient NewClientFn
+	keyName   string
+}
+
+func (c apiKeyClient) Create(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	client, err := c.newClient(s.Token)
+	if err != nil {
+		return err
+	}
+
+	o, err := client.CreateApiKey(neon.ApiKeyCreateRequest{KeyName: c.keyName})
+	if err != nil {
+		return err
+	}
+
+	if o.Key == "" {
+		return errors.New("new API key is corrupt: key is empty")
+	}
+
+	s.Token = o.Key
+	s.KeyID = o.ID
+
+	return nil
+}
+
+func (c apiKeyClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	current, ok := secretCurrent.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
+
+	pending, ok := secretPending.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if current.Token == pending.Token {
+		return errors.New("API key shall be modified")
+	}
+
+	return nil
+}
+
+func (c apiKeyClient) Test(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	client, err := c.newClient(s.Token)
+	if err != nil {
+		return err
+	}
+
+	limit := 1
+	_, err = client.ListProjects(nil, &limit)
+	return err
+}
+
+// Finalize revokes the API key of the secret's version staged as AWSPREVIOUS.
+// The key is not revoked if its ID is unknown, e.g. if the key was provisioned prior to the first rotation without
+// the attribute key_id: the Neon API does not expose the keys' tokens, hence the ID cannot be looked up by the token.
+func (c apiKeyClient) Finalize(ctx context.Context, secretCurrent, secretPrevious any) error {
+	current, ok := secretCurrent.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
+
+	if secretPrevious == nil {
+		return nil
+	}
+
+	previous, ok := secretPrevious.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong type of the previous secret")
+	}
+
+	if previous.KeyID == 0 {
+		if previous.Token != current.Token {
+			log.Println(
+				"[WARN] API key of the secret's version staged as AWSPREVIOUS cannot be revoked: key_id is not set, " +
+					"the key must be revoked manually",
+			)
+		}
+		return nil
+	}
+
+	if previous.KeyID == current.KeyID {
+		return nil
+	}
+
+	client, err := c.newClient(current.Token)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.RevokeApiKey(previous.KeyID); err != nil {
+		var e neon.Error
+		if errors.As(err, &e) && e.HTTPCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Discard revokes the API key generated upon the step createSecret which could not be stored as the secret's version
+// staged as AWSPENDING, the key authenticates its own revocation.
+func (c apiKeyClient) Discard(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretAdmin)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if s.KeyID == 0 {
+		return nil
+	}
+
+	client, err := c.newClient(s.Token)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.RevokeApiKey(s.KeyID)
+	return err
+}
@@ -0,0 +1,348 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+	sdk "github.com/kislerdm/neon-sdk-go"
+)
+
+// stubSDKClient stubs the Neon SDK client, the methods which are not defined explicitly panic.
+type stubSDKClient struct {
+	sdk.Client
+
+	key string
+
+	keys       map[int64]string
+	lastKeyID  int64
+	revokedIDs []int64
+
+	createKeyErr error
+	revokeKeyErr error
+}
+
+func (s *stubSDKClient) CreateApiKey(cfg sdk.ApiKeyCreateRequest) (sdk.ApiKeyCreateResponse, error) {
+	if s.createKeyErr != nil {
+		return sdk.ApiKeyCreateResponse{}, s.createKeyErr
+	}
+	if _, ok := s.authorized(); !ok {
+		return sdk.ApiKeyCreateResponse{}, sdk.Error{HTTPCode: http.StatusUnauthorized}
+	}
+	s.lastKeyID++
+	key := cfg.KeyName + "-" + strconv.FormatInt(s.lastKeyID, 10)
+	s.keys[s.lastKeyID] = key
+	return sdk.ApiKeyCreateResponse{ID: s.lastKeyID, Key: key}, nil
+}
+
+func (s *stubSDKClient) RevokeApiKey(keyID int64) (sdk.ApiKeyRevokeResponse, error) {
+	if s.revokeKeyErr != nil {
+		return sdk.ApiKeyRevokeResponse{}, s.revokeKeyErr
+	}
+	if _, ok := s.authorized(); !ok {
+		return sdk.ApiKeyRevokeResponse{}, sdk.Error{HTTPCode: http.StatusUnauthorized}
+	}
+	if _, ok := s.keys[keyID]; !ok {
+		return sdk.ApiKeyRevokeResponse{}, sdk.Error{HTTPCode: http.StatusNotFound}
+	}
+	delete(s.keys, keyID)
+	s.revokedIDs = append(s.revokedIDs, keyID)
+	return sdk.ApiKeyRevokeResponse{ID: keyID, Revoked: true}, nil
+}
+
+func (s *stubSDKClient) ListProjects(cursor *string, limit *int) (sdk.ListProjectsRespObj, error) {
+	if _, ok := s.authorized(); !ok {
+		return sdk.ListProjectsRespObj{}, sdk.Error{HTTPCode: http.StatusUnauthorized}
+	}
+	return sdk.ListProjectsRespObj{}, nil
+}
+
+func (s *stubSDKClient) authorized() (int64, bool) {
+	for id, key := range s.keys {
+		if key == s.key {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// newStubClientFn returns the function to init the stub authenticated with the key.
+func newStubClientFn(stub *stubSDKClient) NewClientFn {
+	return func(key string) (sdk.Client, error) {
+		if key == "" {
+			return nil, errors.New("authorization key must be provided")
+		}
+		stub.key = key
+		return stub, nil
+	}
+}
+
+func Test_apiKeyClient_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		stub    *stubSDKClient
+		secret  any
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			stub:    &stubSDKClient{keys: map[int64]string{1: "foo"}, lastKeyID: 1},
+			secret:  &SecretAdmin{Token: "foo", KeyID: 1},
+			want:    &SecretAdmin{Token: DefaultAPIKeyName + "-2", KeyID: 2},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			stub:    &stubSDKClient{},
+			secret:  SecretAdmin{Token: "foo"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: empty token",
+			stub:    &stubSDKClient{},
+			secret:  &SecretAdmin{},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: unauthorized",
+			stub:    &stubSDKClient{keys: map[int64]string{1: "foo"}, lastKeyID: 1},
+			secret:  &SecretAdmin{Token: "bar"},
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: API error",
+			stub: &stubSDKClient{
+				keys: map[int64]string{1: "foo"}, lastKeyID: 1, createKeyErr: errors.New("foo"),
+			},
+			secret:  &SecretAdmin{Token: "foo"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewAPIKeyServiceClient(newStubClientFn(tt.stub), "")
+				if err := c.Create(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(tt.secret, tt.want) {
+					t.Errorf("Create() got = %v, want %v", tt.secret, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func Test_apiKeyClient_Set(t *testing.T) {
+	tests := []struct {
+		name          string
+		secretCurrent any
+		secretPending any
+		wantErr       bool
+	}{
+		{
+			name:          "happy path",
+			secretCurrent: &SecretAdmin{Token: "foo", KeyID: 1},
+			secretPending: &SecretAdmin{Token: "bar", KeyID: 2},
+			wantErr:       false,
+		},
+		{
+			name:          "unhappy path: token not modified",
+			secretCurrent: &SecretAdmin{Token: "foo", KeyID: 1},
+			secretPending: &SecretAdmin{Token: "foo", KeyID: 1},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong type of the current secret",
+			secretCurrent: "foo",
+			secretPending: &SecretAdmin{Token: "bar", KeyID: 2},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong type of the pending secret",
+			secretCurrent: &SecretAdmin{Token: "foo", KeyID: 1},
+			secretPending: "bar",
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewAPIKeyServiceClient(newStubClientFn(&stubSDKClient{}), "")
+				if err := c.Set(context.TODO(), tt.secretCurrent, tt.secretPending, nil); (err != nil) != tt.wantErr {
+					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_apiKeyClient_Test(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			secret:  &SecretAdmin{Token: "bar", KeyID: 2},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: unauthorized",
+			secret:  &SecretAdmin{Token: "baz", KeyID: 3},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  "bar",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				stub := &stubSDKClient{keys: map[int64]string{1: "foo", 2: "bar"}, lastKeyID: 2}
+				c := NewAPIKeyServiceClient(newStubClientFn(stub), "")
+				if err := c.Test(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_apiKeyClient_Finalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		stub           *stubSDKClient
+		secretCurrent  any
+		secretPrevious any
+		wantRevokedIDs []int64
+		wantErr        bool
+	}{
+		{
+			name:           "happy path",
+			stub:           &stubSDKClient{keys: map[int64]string{1: "foo", 2: "bar"}, lastKeyID: 2},
+			secretCurrent:  &SecretAdmin{Token: "bar", KeyID: 2},
+			secretPrevious: &SecretAdmin{Token: "foo", KeyID: 1},
+			wantRevokedIDs: []int64{1},
+			wantErr:        false,
+		},
+		{
+			name:           "happy path: previous key was revoked already",
+			stub:           &stubSDKClient{keys: map[int64]string{2: "bar"}, lastKeyID: 2},
+			secretCurrent:  &SecretAdmin{Token: "bar", KeyID: 2},
+			secretPrevious: &SecretAdmin{Token: "foo", KeyID: 1},
+			wantErr:        false,
+		},
+		{
+			name:           "happy path: no previous version",
+			stub:           &stubSDKClient{keys: map[int64]string{2: "bar"}, lastKeyID: 2},
+			secretCurrent:  &SecretAdmin{Token: "bar", KeyID: 2},
+			secretPrevious: nil,
+			wantErr:        false,
+		},
+		{
+			name:           "happy path: unknown ID of the previous key",
+			stub:           &stubSDKClient{keys: map[int64]string{1: "foo", 2: "bar"}, lastKeyID: 2},
+			secretCurrent:  &SecretAdmin{Token: "bar", KeyID: 2},
+			secretPrevious: &SecretAdmin{Token: "foo"},
+			wantErr:        false,
+		},
+		{
+			name: "unhappy path: API error",
+			stub: &stubSDKClient{
+				keys: map[int64]string{1: "foo", 2: "bar"}, lastKeyID: 2, revokeKeyErr: errors.New("foo"),
+			},
+			secretCurrent:  &SecretAdmin{Token: "bar", KeyID: 2},
+			secretPrevious: &SecretAdmin{Token: "foo", KeyID: 1},
+			wantErr:        true,
+		},
+		{
+			name:           "unhappy path: wrong type of the current secret",
+			stub:           &stubSDKClient{},
+			secretCurrent:  "bar",
+			secretPrevious: &SecretAdmin{Token: "foo", KeyID: 1},
+			wantErr:        true,
+		},
+		{
+			name:           "unhappy path: wrong type of the previous secret",
+			stub:           &stubSDKClient{},
+			secretCurrent:  &SecretAdmin{Token: "bar", KeyID: 2},
+			secretPrevious: "foo",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewAPIKeyServiceClient(newStubClientFn(tt.stub), "")
+				err := c.(*apiKeyClient).Finalize(context.TODO(), tt.secretCurrent, tt.secretPrevious)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Finalize() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(tt.stub.revokedIDs, tt.wantRevokedIDs) {
+					t.Errorf("Finalize() revoked keys = %v, want %v", tt.stub.revokedIDs, tt.wantRevokedIDs)
+				}
+			},
+		)
+	}
+}
+
+func Test_apiKeyClient_Discard(t *testing.T) {
+	tests := []struct {
+		name           string
+		stub           *stubSDKClient
+		secret         any
+		wantRevokedIDs []int64
+		wantErr        bool
+	}{
+		{
+			name:           "happy path: new key revoked",
+			stub:           &stubSDKClient{keys: map[int64]string{1: "foo", 2: "bar"}, lastKeyID: 2},
+			secret:         &SecretAdmin{Token: "bar", KeyID: 2},
+			wantRevokedIDs: []int64{2},
+			wantErr:        false,
+		},
+		{
+			name:    "happy path: no key generated",
+			stub:    &stubSDKClient{keys: map[int64]string{1: "foo"}, lastKeyID: 1},
+			secret:  &SecretAdmin{Token: "foo"},
+			wantErr: false,
+		},
+		{
+			name: "unhappy path: API error",
+			stub: &stubSDKClient{
+				keys: map[int64]string{1: "foo", 2: "bar"}, lastKeyID: 2, revokeKeyErr: errors.New("foo"),
+			},
+			secret:  &SecretAdmin{Token: "bar", KeyID: 2},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			stub:    &stubSDKClient{},
+			secret:  "bar",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewAPIKeyServiceClient(newStubClientFn(tt.stub), "")
+				err := c.(lambda.ServiceClientDiscarder).Discard(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Discard() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(tt.stub.revokedIDs, tt.wantRevokedIDs) {
+					t.Errorf("Discard() revoked keys = %v, want %v", tt.stub.revokedIDs, tt.wantRevokedIDs)
+				}
+			},
+		)
+	}
+}
@@ -2,8 +2,12 @@ package neon
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"regexp"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	sdk "github.com/kislerdm/neon-sdk-go"
 )
 
@@ -19,7 +23,8 @@ const placeholderPassword = "quxx"
 
 func Test_clientDB_GenerateSecret(t *testing.T) {
 	type fields struct {
-		c sdk.Client
+		roles  []string
+		expect func(m sqlmock.Sqlmock)
 	}
 	type args struct {
 		ctx    context.Context
@@ -34,31 +39,30 @@ func Test_clientDB_GenerateSecret(t *testing.T) {
 		{
 			name: "happy path",
 			fields: fields{
-				c: newMockSDKClient(),
+				expect: expectRoleExists("qux", true),
 			},
 			args: args{
 				ctx: context.TODO(),
 				secret: &SecretUser{
-					User:      "qux",
-					ProjectID: "foo",
-					BranchID:  "br-bar",
-					Password:  placeholderPassword,
+					User:         "qux",
+					DatabaseName: "baz",
+					ProjectID:    "foo",
+					BranchID:     "br-bar",
+					Password:     placeholderPassword,
 				},
 			},
 			wantErr: false,
 		},
 		{
 			name: "unhappy path: wrong secret type",
-			fields: fields{
-				c: newMockSDKClient(),
-			},
 			args: args{
 				ctx: context.TODO(),
 				secret: SecretUser{
-					User:      "qux",
-					ProjectID: "foo",
-					BranchID:  "br-bar",
-					Password:  placeholderPassword,
+					User:         "qux",
+					DatabaseName: "baz",
+					ProjectID:    "foo",
+					BranchID:     "br-bar",
+					Password:     placeholderPassword,
 				},
 			},
 			wantErr: true,
@@ -66,31 +70,47 @@ func Test_clientDB_GenerateSecret(t *testing.T) {
 		{
 			name: "unhappy path: user not found",
 			fields: fields{
-				c: newMockSDKClient(),
+				expect: expectRoleExists("missing", false),
 			},
 			args: args{
 				ctx: context.TODO(),
 				secret: &SecretUser{
-					User:      "missing",
-					ProjectID: "foo",
-					BranchID:  "br-bar",
-					Password:  placeholderPassword,
+					User:         "missing",
+					DatabaseName: "baz",
+					ProjectID:    "foo",
+					BranchID:     "br-bar",
+					Password:     placeholderPassword,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "unhappy path: missing user",
+			name: "unhappy path: password stored by the Neon control plane",
 			fields: fields{
-				c: newMockSDKClient(),
+				roles: []string{"qux"},
 			},
 			args: args{
 				ctx: context.TODO(),
 				secret: &SecretUser{
-					User:      "",
-					ProjectID: "foo",
-					BranchID:  "br-bar",
-					Password:  placeholderPassword,
+					User:         "qux",
+					DatabaseName: "baz",
+					ProjectID:    "foo",
+					BranchID:     "br-bar",
+					Password:     placeholderPassword,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: missing user",
+			args: args{
+				ctx: context.TODO(),
+				secret: &SecretUser{
+					User:         "",
+					DatabaseName: "baz",
+					ProjectID:    "foo",
+					BranchID:     "br-bar",
+					Password:     placeholderPassword,
 				},
 			},
 			wantErr: true,
@@ -100,7 +120,14 @@ func Test_clientDB_GenerateSecret(t *testing.T) {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				c := dbClient{
-					c: tt.fields.c,
+					c: &stubNeonClient{
+						roles: tt.fields.roles,
+						endpoints: []sdk.Endpoint{
+							{ID: "ep-foo", Host: "ep-foo.eu-central-1.aws.neon.tech", Type: "read_write"},
+						},
+					},
+					adminRole: "admin",
+					connector: newMockConnector(t, tt.fields.expect),
 				}
 				err := c.Create(tt.args.ctx, tt.args.secret)
 				if (err != nil) != tt.wantErr {
@@ -114,82 +141,275 @@ func Test_clientDB_GenerateSecret(t *testing.T) {
 	}
 }
 
-func Test_clientDB_TryConnection(t *testing.T) {
-	type fields struct {
-		c sdk.Client
+// newMockConnector returns the Connector to open the mock database connection with the expectations set by expect.
+func newMockConnector(t *testing.T, expect func(m sqlmock.Sqlmock)) Connector {
+	t.Helper()
+	return ConnectorFunc(
+		func(cfg ConnConfig) (*sql.DB, error) {
+			db, m, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect != nil {
+				expect(m)
+			}
+			t.Cleanup(
+				func() {
+					if err := m.ExpectationsWereMet(); err != nil {
+						t.Error(err)
+					}
+				},
+			)
+			return db, nil
+		},
+	)
+}
+
+// newMockConnectorSequence returns the Connector to open the mock database connections,
+// the expectations of the n-th connection are set by the n-th element of expect.
+func newMockConnectorSequence(t *testing.T, expect ...func(m sqlmock.Sqlmock)) Connector {
+	t.Helper()
+	var n int
+	return ConnectorFunc(
+		func(cfg ConnConfig) (*sql.DB, error) {
+			if n >= len(expect) {
+				t.Fatalf("unexpected connection #%d", n+1)
+			}
+			n++
+			return newMockConnector(t, expect[n-1]).Open(cfg)
+		},
+	)
+}
+
+// expectRoleExists sets the expectation to verify the role's existence.
+func expectRoleExists(user string, exists bool) func(m sqlmock.Sqlmock) {
+	return func(m sqlmock.Sqlmock) {
+		m.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $1)")).
+			WithArgs(user).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+		m.ExpectClose()
 	}
-	type args struct {
-		ctx    context.Context
-		secret any
+}
+
+func expectIdentity(user, dbname string) func(m sqlmock.Sqlmock) {
+	return func(m sqlmock.Sqlmock) {
+		m.ExpectQuery(regexp.QuoteMeta("SELECT current_user, current_database()")).
+			WillReturnRows(sqlmock.NewRows([]string{"current_user", "current_database"}).AddRow(user, dbname))
+		m.ExpectClose()
+	}
+}
+
+func Test_clientDB_TryConnection(t *testing.T) {
+	secret := &SecretUser{
+		User:         "qux",
+		Host:         "ep-foo.eu-central-1.aws.neon.tech",
+		DatabaseName: "baz",
+		ProjectID:    "foo",
+		BranchID:     "br-bar",
+		Password:     placeholderPassword,
 	}
+
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name      string
+		probes    []string
+		expect    func(m sqlmock.Sqlmock)
+		secret    any
+		wantErr   bool
+		wantCheck string
 	}{
 		{
-			name: "happy path",
-			fields: fields{
-				c: newMockSDKClient(),
-			},
-			args: args{
-				ctx: context.TODO(),
-				secret: &SecretUser{
-					User:         "qux",
-					Host:         "dev",
-					DatabaseName: "baz",
-					ProjectID:    "foo",
-					BranchID:     "br-bar",
-					Password:     placeholderPassword,
-				},
+			name:    "happy path",
+			expect:  expectIdentity("qux", "baz"),
+			secret:  secret,
+			wantErr: false,
+		},
+		{
+			name:   "happy path: probes passed",
+			probes: []string{"SELECT 1 FROM foo LIMIT 0", "SELECT 1 FROM bar LIMIT 0"},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(regexp.QuoteMeta("SELECT current_user, current_database()")).
+					WillReturnRows(sqlmock.NewRows([]string{"current_user", "current_database"}).AddRow("qux", "baz"))
+				m.ExpectBegin()
+				m.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM foo LIMIT 0")).WillReturnRows(sqlmock.NewRows(nil))
+				m.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM bar LIMIT 0")).WillReturnRows(sqlmock.NewRows(nil))
+				m.ExpectRollback()
+				m.ExpectClose()
 			},
+			secret:  secret,
 			wantErr: false,
 		},
 		{
-			name: "unhappy path: wrong secret content - missing host",
-			fields: fields{
-				c: newMockSDKClient(),
+			name:   "unhappy path: probe failed",
+			probes: []string{"SELECT 1 FROM foo LIMIT 0", "SELECT 1 FROM bar LIMIT 0"},
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(regexp.QuoteMeta("SELECT current_user, current_database()")).
+					WillReturnRows(sqlmock.NewRows([]string{"current_user", "current_database"}).AddRow("qux", "baz"))
+				m.ExpectBegin()
+				m.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM foo LIMIT 0")).
+					WillReturnError(errors.New("permission denied for table foo"))
+				m.ExpectRollback()
+				m.ExpectClose()
 			},
-			args: args{
-				ctx: context.TODO(),
-				secret: &SecretUser{
-					User:         "qux",
-					ProjectID:    "foo",
-					DatabaseName: "baz",
-					BranchID:     "br-bar",
-					Password:     placeholderPassword,
-				},
+			secret:    secret,
+			wantErr:   true,
+			wantCheck: CheckProbe,
+		},
+		{
+			name:      "unhappy path: wrong user",
+			expect:    expectIdentity("admin", "baz"),
+			secret:    secret,
+			wantErr:   true,
+			wantCheck: CheckIdentity,
+		},
+		{
+			name:      "unhappy path: wrong database",
+			expect:    expectIdentity("qux", "postgres"),
+			secret:    secret,
+			wantErr:   true,
+			wantCheck: CheckIdentity,
+		},
+		{
+			name: "unhappy path: failed to authenticate",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(regexp.QuoteMeta("SELECT current_user, current_database()")).
+					WillReturnError(errors.New("password authentication failed for user qux"))
+				m.ExpectClose()
+			},
+			secret:    secret,
+			wantErr:   true,
+			wantCheck: CheckConnection,
+		},
+		{
+			name: "unhappy path: wrong secret content - missing host",
+			secret: &SecretUser{
+				User:         "qux",
+				ProjectID:    "foo",
+				DatabaseName: "baz",
+				BranchID:     "br-bar",
+				Password:     placeholderPassword,
 			},
 			wantErr: true,
 		},
 		{
-			name: "unhappy path: failed to ping",
-			fields: fields{
-				c: newMockSDKClient(),
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := dbClient{
+					c:         newMockSDKClient(),
+					probes:    tt.probes,
+					connector: newMockConnector(t, tt.expect),
+				}
+				err := c.Test(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				var e *TestError
+				if errors.As(err, &e) != (tt.wantCheck != "") || (e != nil && e.Check != tt.wantCheck) {
+					t.Errorf("Test() error = %v, want failed check %s", err, tt.wantCheck)
+				}
 			},
-			args: args{
-				ctx: context.TODO(),
-				secret: &SecretUser{
-					User:         "qux",
-					ProjectID:    "foo",
-					Host:         "dev",
-					DatabaseName: "fail",
-					BranchID:     "br-bar",
-					Password:     placeholderPassword,
-				},
+		)
+	}
+}
+
+// alterRoleStatement the statement to set the password of the role "qux" as the SCRAM-SHA-256 verifier.
+const alterRoleStatement = `^ALTER ROLE "qux" WITH PASSWORD 'SCRAM-SHA-256\$4096:[^']+'$`
+
+func Test_clientDB_Set(t *testing.T) {
+	secret := &SecretUser{
+		User:         "qux",
+		Host:         "ep-foo.eu-central-1.aws.neon.tech",
+		DatabaseName: "baz",
+		ProjectID:    "foo",
+		BranchID:     "br-bar",
+		Password:     placeholderPassword,
+	}
+
+	expectAlterRole := func(m sqlmock.Sqlmock) {
+		m.ExpectExec(alterRoleStatement).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectClose()
+	}
+
+	tests := []struct {
+		name          string
+		roles         []string
+		adminRole     string
+		expect        func(m sqlmock.Sqlmock)
+		secretPending any
+		wantErr       bool
+	}{
+		{
+			name:          "happy path",
+			adminRole:     "admin",
+			expect:        expectAlterRole,
+			secretPending: secret,
+			wantErr:       false,
+		},
+		{
+			name:          "unhappy path: wrong secret type",
+			adminRole:     "admin",
+			secretPending: SecretUser{},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: admin role not set",
+			secretPending: secret,
+			wantErr:       true,
+		},
+		{
+			name:      "unhappy path: empty password",
+			adminRole: "admin",
+			secretPending: &SecretUser{
+				User:         "qux",
+				Host:         "ep-foo.eu-central-1.aws.neon.tech",
+				DatabaseName: "baz",
+				ProjectID:    "foo",
+				BranchID:     "br-bar",
 			},
 			wantErr: true,
 		},
+		{
+			name:          "unhappy path: password stored by the Neon control plane",
+			roles:         []string{"qux"},
+			adminRole:     "admin",
+			secretPending: secret,
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: admin role not found",
+			adminRole:     "missing",
+			secretPending: secret,
+			wantErr:       true,
+		},
+		{
+			name:      "unhappy path: failed to alter role",
+			adminRole: "admin",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(alterRoleStatement).
+					WillReturnError(errors.New("permission denied to alter role"))
+				m.ExpectClose()
+			},
+			secretPending: secret,
+			wantErr:       true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				c := dbClient{
-					c: tt.fields.c,
+					c:         &stubNeonClient{roles: tt.roles},
+					adminRole: tt.adminRole,
+					connector: newMockConnector(t, tt.expect),
 				}
-				if err := c.Test(tt.args.ctx, tt.args.secret); (err != nil) != tt.wantErr {
-					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				if err := c.Set(context.TODO(), nil, tt.secretPending, nil); (err != nil) != tt.wantErr {
+					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
 				}
 			},
 		)
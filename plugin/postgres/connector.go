@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
+)
+
+// DefaultSSLMode the default TLS mode of the database connection.
+const DefaultSSLMode = "verify-full"
+
+// DefaultConnectTimeout the default timeout to establish the database connection.
+const DefaultConnectTimeout = 10 * time.Second
+
+// ConnConfig defines the configuration of the database connection.
+type ConnConfig struct {
+	Host         string
+	Port         int
+	User         string
+	Password     string
+	DatabaseName string
+
+	// SSLMode the TLS mode, see https://www.postgresql.org/docs/current/libpq-ssl.html#LIBPQ-SSL-PROTECTION
+	SSLMode string
+
+	// RootCA PEM encoded certificates of the CAs to verify the server's certificate,
+	// the system's certificates pool is used if empty.
+	RootCA []byte
+
+	// ConnectTimeout the timeout to establish the connection.
+	ConnectTimeout time.Duration
+
+	// Options the value of the connection parameter "options", e.g. "-c search_path=foo" to set the run-time
+	// parameters, see https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNECT-OPTIONS
+	Options string
+}
+
+// dsn returns the connection string in the keyword/value format.
+func (cfg ConnConfig) dsn(extra ...string) string {
+	kv := []string{
+		"host", cfg.Host,
+		"user", cfg.User,
+		"password", cfg.Password,
+		"dbname", cfg.DatabaseName,
+		"sslmode", cfg.SSLMode,
+		"options", cfg.Options,
+	}
+	if cfg.Port > 0 {
+		kv = append(kv, "port", strconv.Itoa(cfg.Port))
+	}
+	if cfg.ConnectTimeout > 0 {
+		// the timeout is set in seconds, the minimum is 2s, see
+		// https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNECT-CONNECT-TIMEOUT
+		timeout := int((cfg.ConnectTimeout + time.Second - 1) / time.Second)
+		if timeout < 2 {
+			timeout = 2
+		}
+		kv = append(kv, "connect_timeout", strconv.Itoa(timeout))
+	}
+	kv = append(kv, extra...)
+
+	var o []string
+	for i := 0; i < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			o = append(o, kv[i]+"="+QuoteDSNValue(kv[i+1]))
+		}
+	}
+	return strings.Join(o, " ")
+}
+
+var dsnValueReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// QuoteDSNValue quotes the value of the keyword/value connection string, the quotes and backslashes are escaped.
+func QuoteDSNValue(v string) string {
+	return "'" + dsnValueReplacer.Replace(v) + "'"
+}
+
+// Connector opens the database connection.
+type Connector interface {
+	Open(cfg ConnConfig) (*sql.DB, error)
+}
+
+// ConnectorFunc the function which implements Connector.
+type ConnectorFunc func(cfg ConnConfig) (*sql.DB, error)
+
+func (f ConnectorFunc) Open(cfg ConnConfig) (*sql.DB, error) {
+	return f(cfg)
+}
+
+// PQConnector the Connector which uses the driver github.com/lib/pq.
+type PQConnector struct{}
+
+func (PQConnector) Open(cfg ConnConfig) (*sql.DB, error) {
+	var extra []string
+	if len(cfg.RootCA) > 0 {
+		extra = []string{"sslrootcert", string(cfg.RootCA), "sslinline", "true"}
+	}
+
+	c, err := pq.NewConnector(cfg.dsn(extra...))
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(c), nil
+}
+
+// PGXConnector the Connector which uses the driver github.com/jackc/pgx.
+type PGXConnector struct{}
+
+func (PGXConnector) Open(cfg ConnConfig) (*sql.DB, error) {
+	// following libpq, the server's certificate is verified if the root CA is set
+	if len(cfg.RootCA) > 0 && cfg.SSLMode == "require" {
+		cfg.SSLMode = "verify-ca"
+	}
+
+	c, err := pgx.ParseConfig(cfg.dsn())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.RootCA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.RootCA) {
+			return nil, errors.New("unable to add root CA to the certificates pool")
+		}
+		if c.TLSConfig != nil {
+			c.TLSConfig.RootCAs = pool
+		}
+		for _, fb := range c.Fallbacks {
+			if fb.TLSConfig != nil {
+				fb.TLSConfig.RootCAs = pool
+			}
+		}
+	}
+
+	return stdlib.OpenDB(*c), nil
+}
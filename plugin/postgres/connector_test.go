@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newMockRootCA(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "foo"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestConnConfig_dsn(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   ConnConfig
+		extra []string
+		want  string
+	}{
+		{
+			name: "happy path: defaults",
+			cfg: ConnConfig{
+				Host: "localhost", User: "qux", Password: "bar", DatabaseName: "baz",
+				SSLMode: DefaultSSLMode,
+			},
+			want: "host='localhost' user='qux' password='bar' dbname='baz' " +
+				"sslmode='verify-full'",
+		},
+		{
+			name: "happy path: all attributes, the values escaped",
+			cfg: ConnConfig{
+				Host: "localhost", Port: 5433, User: "qux", Password: `b a'r\`, DatabaseName: "baz",
+				SSLMode: "disable", ConnectTimeout: 500 * time.Millisecond, Options: "-c search_path=foo",
+			},
+			extra: []string{"sslinline", "true"},
+			want: `host='localhost' user='qux' password='b a\'r\\' dbname='baz' sslmode='disable' ` +
+				`options='-c search_path=foo' port='5433' connect_timeout='2' sslinline='true'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := tt.cfg.dsn(tt.extra...); got != tt.want {
+					t.Errorf("dsn() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestConnector_Open(t *testing.T) {
+	cfg := ConnConfig{Host: "localhost", User: "qux", Password: "bar", DatabaseName: "baz", SSLMode: "verify-full"}
+
+	cfgRootCA := cfg
+	cfgRootCA.RootCA = newMockRootCA(t)
+
+	cfgWrongRootCA := cfg
+	cfgWrongRootCA.RootCA = []byte("foo")
+
+	cfgWrongSSLMode := cfg
+	cfgWrongSSLMode.SSLMode = "foo"
+
+	tests := []struct {
+		name      string
+		connector Connector
+		cfg       ConnConfig
+		wantErr   bool
+	}{
+		{
+			name:      "happy path: pq",
+			connector: PQConnector{},
+			cfg:       cfgRootCA,
+			wantErr:   false,
+		},
+		{
+			name:      "happy path: pgx",
+			connector: PGXConnector{},
+			cfg:       cfgRootCA,
+			wantErr:   false,
+		},
+		{
+			name:      "unhappy path: pgx, wrong root CA",
+			connector: PGXConnector{},
+			cfg:       cfgWrongRootCA,
+			wantErr:   true,
+		},
+		{
+			name:      "unhappy path: pgx, wrong sslmode",
+			connector: PGXConnector{},
+			cfg:       cfgWrongSSLMode,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				db, err := tt.connector.Open(tt.cfg)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if db != nil {
+					_ = db.Close()
+				}
+			},
+		)
+	}
+}
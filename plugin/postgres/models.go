@@ -0,0 +1,37 @@
+package postgres
+
+// SecretAdmin defines the secret with the access details of the admin role which is authorized to alter the user's
+// role, e.g. the RDS master user.
+type SecretAdmin struct {
+	// User admin role
+	User string `json:"user"`
+	// Password admin role's password
+	Password string `json:"password"`
+	// Host database host, the host of SecretUser is used if empty
+	Host string `json:"host,omitempty"`
+	// Port database port, the port of SecretUser is used if empty
+	Port int `json:"port,omitempty"`
+	// DatabaseName database name, the database of SecretUser is used if empty
+	DatabaseName string `json:"dbname,omitempty"`
+	// SSLMode TLS mode of the connection, the TLS mode of SecretUser is used if empty
+	SSLMode string `json:"sslmode,omitempty"`
+}
+
+// SecretUser defines the secret with db user access details.
+type SecretUser struct {
+	// User database role
+	User string `json:"user"`
+	// Password role's password
+	Password string `json:"password"`
+	// Host database host
+	Host string `json:"host"`
+	// Port database port, 5432 by default
+	Port int `json:"port,omitempty"`
+	// DatabaseName database name
+	DatabaseName string `json:"dbname"`
+	// SSLMode TLS mode of the connection, see WithSSLMode
+	SSLMode string `json:"sslmode,omitempty"`
+	// AlternateUser the role which alternates with User upon rotation in the alternating-users layout,
+	// the single-user layout is used if empty
+	AlternateUser string `json:"alternate_user,omitempty"`
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// TestServiceClient_postgres runs the rotation against the local Postgres defined by the env. variables:
+// POSTGRES_HOST, POSTGRES_PORT, POSTGRES_USER, POSTGRES_PASSWORD and POSTGRES_DB.
+func TestServiceClient_postgres(t *testing.T) {
+	host := os.Getenv("POSTGRES_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_HOST is not set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("POSTGRES_PORT"))
+
+	admin := SecretAdmin{User: os.Getenv("POSTGRES_USER"), Password: os.Getenv("POSTGRES_PASSWORD")}
+	dbname := os.Getenv("POSTGRES_DB")
+
+	c := NewServiceClient(admin, WithSSLMode("disable"))
+
+	db, err := PQConnector{}.Open(
+		ConnConfig{
+			Host: host, Port: port, User: admin.User, Password: admin.Password, DatabaseName: dbname,
+			SSLMode: "disable",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	createRole := func(role string) {
+		t.Helper()
+		dropRole(t, db, role)
+		if _, err := db.Exec("CREATE ROLE " + pq.QuoteIdentifier(role) + " WITH LOGIN PASSWORD 'initial'"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotate := func(secret *SecretUser) {
+		t.Helper()
+		current := *secret
+		if err := c.Create(context.TODO(), secret); err != nil {
+			t.Fatalf("Create() unexpected error = %v", err)
+		}
+		if err := c.Set(context.TODO(), &current, secret, nil); err != nil {
+			t.Fatalf("Set() unexpected error = %v", err)
+		}
+		if err := c.Test(context.TODO(), secret); err != nil {
+			t.Fatalf("Test() unexpected error = %v", err)
+		}
+	}
+
+	t.Run(
+		"single user", func(t *testing.T) {
+			createRole("rotation_single")
+			defer dropRole(t, db, "rotation_single")
+
+			secret := &SecretUser{
+				User: "rotation_single", Password: "initial", Host: host, Port: port, DatabaseName: dbname,
+			}
+			rotate(secret)
+
+			var verifier string
+			if err := db.QueryRow(
+				"SELECT rolpassword FROM pg_catalog.pg_authid WHERE rolname = 'rotation_single'",
+			).Scan(&verifier); err == nil && !strings.HasPrefix(verifier, "SCRAM-SHA-256$") {
+				t.Errorf("Set() stored password = %s, want the SCRAM-SHA-256 verifier", verifier)
+			}
+		},
+	)
+
+	t.Run(
+		"alternating users", func(t *testing.T) {
+			createRole("rotation_alternating")
+			dropRole(t, db, "rotation_alternating_clone")
+			defer dropRole(t, db, "rotation_alternating")
+			defer dropRole(t, db, "rotation_alternating_clone")
+
+			secret := &SecretUser{
+				User: "rotation_alternating", Password: "initial", Host: host, Port: port, DatabaseName: dbname,
+				AlternateUser: "rotation_alternating_clone",
+			}
+
+			rotate(secret)
+			if secret.User != "rotation_alternating_clone" {
+				t.Errorf("first rotation user = %s, want rotation_alternating_clone", secret.User)
+			}
+
+			rotate(secret)
+			if secret.User != "rotation_alternating" {
+				t.Errorf("second rotation user = %s, want rotation_alternating", secret.User)
+			}
+		},
+	)
+}
+
+func dropRole(t *testing.T, db *sql.DB, role string) {
+	t.Helper()
+	if _, err := db.Exec("DROP ROLE IF EXISTS " + pq.QuoteIdentifier(role)); err != nil {
+		t.Fatal(err)
+	}
+}
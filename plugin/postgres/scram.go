@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The parameters of the SCRAM-SHA-256 verifier, see https://www.postgresql.org/docs/current/auth-password.html
+const (
+	scramIterations = 4096
+	scramSaltLength = 16
+)
+
+// ScramSHA256 returns the SCRAM-SHA-256 verifier of the password in the format stored by Postgres,
+// "SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>", see https://www.rfc-editor.org/rfc/rfc5802#section-3.
+// Postgres stores the verifier as is if it's set as the role's password, hence the plaintext password does not reach
+// the server. Note that the password is not normalized with SASLprep, hence it must consist of ASCII characters.
+func ScramSHA256(password string) (string, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return scramSHA256WithSalt(password, salt, scramIterations), nil
+}
+
+func scramSHA256WithSalt(password string, salt []byte, iterations int) string {
+	saltedPassword := pbkdf2.Key([]byte(password), salt, iterations, sha256.Size, sha256.New)
+
+	clientKey := hmacSHA256(saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := hmacSHA256(saltedPassword, "Server Key")
+
+	return "SCRAM-SHA-256$" + strconv.Itoa(iterations) + ":" + base64.StdEncoding.EncodeToString(salt) + "$" +
+		base64.StdEncoding.EncodeToString(storedKey[:]) + ":" + base64.StdEncoding.EncodeToString(serverKey)
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(msg))
+	return h.Sum(nil)
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"regexp"
+	"testing"
+)
+
+func Test_scramSHA256WithSalt(t *testing.T) {
+	salt := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	const want = "SCRAM-SHA-256$4096:AAECAwQFBgcICQoLDA0ODw==" +
+		"$zHCdol2044/ZyWzPLi7oxApCkamKw9Z+E4U/QApd/5Y=:dd5peBOitVnLNFu7VmwP+HiDaaw4OUCv396eVCWhYiE="
+
+	if got := scramSHA256WithSalt("pencil", salt, scramIterations); got != want {
+		t.Errorf("scramSHA256WithSalt() = %v, want %v", got, want)
+	}
+}
+
+func TestScramSHA256(t *testing.T) {
+	got, err := ScramSHA256("pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format := regexp.MustCompile(`^SCRAM-SHA-256\$4096:[A-Za-z0-9+/]{22}==\$[A-Za-z0-9+/]{43}=:[A-Za-z0-9+/]{43}=$`)
+	if !format.MatchString(got) {
+		t.Errorf("ScramSHA256() = %v, want the SCRAM-SHA-256 verifier", got)
+	}
+
+	other, _ := ScramSHA256("pencil")
+	if got == other {
+		t.Errorf("ScramSHA256() generated the same salt twice")
+	}
+}
@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+	"github.com/lib/pq"
+)
+
+// NewServiceClient initiates the `ServiceClient` to rotate the Postgres user's password.
+// The admin role is used to set the user's password upon the step setSecret.
+func NewServiceClient(admin SecretAdmin, opts ...Option) lambda.ServiceClient {
+	c := &dbClient{
+		admin:          admin,
+		connector:      PQConnector{},
+		sslMode:        DefaultSSLMode,
+		connectTimeout: DefaultConnectTimeout,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Option defines the option of the ServiceClient.
+type Option func(c *dbClient)
+
+// WithConnector sets the Connector to open the database connections, PQConnector by default.
+func WithConnector(connector Connector) Option {
+	return func(c *dbClient) {
+		c.connector = connector
+	}
+}
+
+// WithSSLMode sets the TLS mode of the database connections, DefaultSSLMode by default.
+// It's overwritten by the secret's attribute `sslmode`.
+func WithSSLMode(mode string) Option {
+	return func(c *dbClient) {
+		c.sslMode = mode
+	}
+}
+
+// WithRootCA sets the PEM encoded certificates of the CAs to verify the database server's certificate,
+// e.g. the RDS certificates bundle. The system's certificates are used by default.
+func WithRootCA(pem []byte) Option {
+	return func(c *dbClient) {
+		c.rootCA = pem
+	}
+}
+
+// WithConnectTimeout sets the timeout to establish the database connections, DefaultConnectTimeout by default.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *dbClient) {
+		c.connectTimeout = timeout
+	}
+}
+
+type dbClient struct {
+	admin SecretAdmin
+
+	connector      Connector
+	sslMode        string
+	rootCA         []byte
+	connectTimeout time.Duration
+}
+
+// Create generates the new password of the pending secret, no connection to the server is made,
+// hence the role's password is only changed by `ALTER ROLE` upon the step setSecret.
+// In the alternating-users layout, the new password is generated for the alternate user, and the users swap,
+// hence the secret's version staged as AWSCURRENT stays valid until the next rotation.
+func (c dbClient) Create(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if s.User == "" || s.Host == "" || s.DatabaseName == "" {
+		return errors.New("wrong secret content: user, host and dbname must be set")
+	}
+
+	if s.AlternateUser != "" {
+		s.User, s.AlternateUser = s.AlternateUser, s.User
+	}
+
+	password, err := lambda.GeneratePassword(passwordLength)
+	if err != nil {
+		return err
+	}
+
+	s.Password = password
+
+	return nil
+}
+
+// Set sets the pending password of the user using the admin role. The password is hashed with SCRAM-SHA-256 before
+// it's sent to the server, hence the plaintext password does not appear in the server's logs.
+// In the alternating-users layout, the user's role is created as a member of the alternate user's role if it does
+// not exist, i.e. the user inherits the alternate user's privileges.
+// A retry sends the new SCRAM verifier of the same password, hence the repeated step is harmless.
+func (c dbClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	s, ok := secretPending.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if s.Password == "" {
+		return errors.New("pending secret is corrupt: password is empty")
+	}
+
+	verifier, err := ScramSHA256(s.Password)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.openDBConnection(c.adminConnConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	statement := "ALTER ROLE " + pq.QuoteIdentifier(s.User) + " WITH PASSWORD " + pq.QuoteLiteral(verifier)
+
+	if s.AlternateUser != "" {
+		var exists bool
+		if err := db.QueryRowContext(
+			ctx, "SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $1)", s.User,
+		).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			statement = "CREATE ROLE " + pq.QuoteIdentifier(s.User) + " WITH LOGIN PASSWORD " +
+				pq.QuoteLiteral(verifier) + " IN ROLE " + pq.QuoteIdentifier(s.AlternateUser)
+		}
+	}
+
+	_, err = db.ExecContext(ctx, statement)
+	return err
+}
+
+// Test verifies that the secret authenticates the user to the database: the current user and the database
+// must match the secret.
+func (c dbClient) Test(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	db, err := c.openDBConnection(c.connConfig(s))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	var user, dbname string
+	if err := db.QueryRowContext(ctx, "SELECT current_user, current_database()").Scan(&user, &dbname); err != nil {
+		return err
+	}
+	if user != s.User || dbname != s.DatabaseName {
+		return errors.New(
+			`connected as "` + user + `" to "` + dbname + `", want "` + s.User + `" to "` + s.DatabaseName + `"`,
+		)
+	}
+
+	return nil
+}
+
+// connConfig returns the configuration of the user's connection, the secret's attributes overwrite
+// the plugin configuration.
+func (c dbClient) connConfig(s *SecretUser) ConnConfig {
+	o := ConnConfig{
+		Host:           s.Host,
+		Port:           s.Port,
+		User:           s.User,
+		Password:       s.Password,
+		DatabaseName:   s.DatabaseName,
+		SSLMode:        c.sslMode,
+		RootCA:         c.rootCA,
+		ConnectTimeout: c.connectTimeout,
+	}
+	if s.SSLMode != "" {
+		o.SSLMode = s.SSLMode
+	}
+	return o
+}
+
+// adminConnConfig returns the configuration of the admin's connection,
+// the unset attributes of the admin secret default to the user's secret.
+func (c dbClient) adminConnConfig(s *SecretUser) ConnConfig {
+	o := c.connConfig(s)
+	o.User = c.admin.User
+	o.Password = c.admin.Password
+	if c.admin.Host != "" {
+		o.Host = c.admin.Host
+	}
+	if c.admin.Port > 0 {
+		o.Port = c.admin.Port
+	}
+	if c.admin.DatabaseName != "" {
+		o.DatabaseName = c.admin.DatabaseName
+	}
+	if c.admin.SSLMode != "" {
+		o.SSLMode = c.admin.SSLMode
+	}
+	return o
+}
+
+func (c dbClient) openDBConnection(cfg ConnConfig) (*sql.DB, error) {
+	if cfg.User == "" || cfg.DatabaseName == "" || cfg.Host == "" {
+		return nil, errors.New("failed to connect: host, user and dbname must be set")
+	}
+	return c.connector.Open(cfg)
+}
+
+// passwordLength the length of the role's password, the password is sent as the SCRAM verifier.
+const passwordLength = 32
@@ -0,0 +1,303 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const placeholderPassword = "quxx"
+
+// newMockConnector returns the Connector to open the mock database connection with the expectations set by expect.
+// The configurations of the opened connections are recorded to cfgs.
+func newMockConnector(t *testing.T, expect func(m sqlmock.Sqlmock), cfgs *[]ConnConfig) Connector {
+	t.Helper()
+	return ConnectorFunc(
+		func(cfg ConnConfig) (*sql.DB, error) {
+			if cfgs != nil {
+				*cfgs = append(*cfgs, cfg)
+			}
+
+			db, m, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect != nil {
+				expect(m)
+			}
+			t.Cleanup(
+				func() {
+					if err := m.ExpectationsWereMet(); err != nil {
+						t.Error(err)
+					}
+				},
+			)
+			return db, nil
+		},
+	)
+}
+
+func Test_dbClient_Create(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   any
+		wantUser string
+		wantAlt  string
+		wantErr  bool
+	}{
+		{
+			name: "happy path: single user",
+			secret: &SecretUser{
+				User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz",
+			},
+			wantUser: "qux",
+			wantErr:  false,
+		},
+		{
+			name: "happy path: alternating users swapped",
+			secret: &SecretUser{
+				User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz",
+				AlternateUser: "qux_clone",
+			},
+			wantUser: "qux_clone",
+			wantAlt:  "qux",
+			wantErr:  false,
+		},
+		{
+			name:    "unhappy path: missing host",
+			secret:  &SecretUser{User: "qux", Password: placeholderPassword, DatabaseName: "baz"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(SecretAdmin{})
+				err := c.Create(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				s := tt.secret.(*SecretUser)
+				if s.Password == placeholderPassword || len(s.Password) != passwordLength {
+					t.Errorf("Create() failed to generate the password")
+				}
+				if s.User != tt.wantUser || s.AlternateUser != tt.wantAlt {
+					t.Errorf(
+						"Create() users = %s, %s, want %s, %s", s.User, s.AlternateUser, tt.wantUser, tt.wantAlt,
+					)
+				}
+			},
+		)
+	}
+}
+
+// scramStatement matches the statement which sets the SCRAM-SHA-256 verifier as the role's password.
+const scramStatement = `PASSWORD 'SCRAM-SHA-256\$4096:[^']+'`
+
+func Test_dbClient_Set(t *testing.T) {
+	secret := &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz"}
+	secretAlternating := &SecretUser{
+		User: "qux_clone", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz",
+		AlternateUser: "qux",
+	}
+
+	expectRoleExists := func(exists bool) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"exists"}).AddRow(exists)
+	}
+
+	tests := []struct {
+		name          string
+		expect        func(m sqlmock.Sqlmock)
+		secretPending any
+		wantErr       bool
+	}{
+		{
+			name: "happy path: single user",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(`^ALTER ROLE "qux" WITH ` + scramStatement + `$`).WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectClose()
+			},
+			secretPending: secret,
+			wantErr:       false,
+		},
+		{
+			name: "happy path: alternating users, role exists",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT EXISTS").WithArgs("qux_clone").WillReturnRows(expectRoleExists(true))
+				m.ExpectExec(`^ALTER ROLE "qux_clone" WITH ` + scramStatement + `$`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectClose()
+			},
+			secretPending: secretAlternating,
+			wantErr:       false,
+		},
+		{
+			name: "happy path: alternating users, role created",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery("SELECT EXISTS").WithArgs("qux_clone").WillReturnRows(expectRoleExists(false))
+				m.ExpectExec(`^CREATE ROLE "qux_clone" WITH LOGIN ` + scramStatement + ` IN ROLE "qux"$`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectClose()
+			},
+			secretPending: secretAlternating,
+			wantErr:       false,
+		},
+		{
+			name: "unhappy path: failed to alter role",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectExec(`^ALTER ROLE "qux"`).WillReturnError(errors.New("permission denied to alter role"))
+				m.ExpectClose()
+			},
+			secretPending: secret,
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: empty password",
+			secretPending: &SecretUser{User: "qux", Host: "localhost", DatabaseName: "baz"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong secret type",
+			secretPending: SecretUser{},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var cfgs []ConnConfig
+				c := NewServiceClient(
+					SecretAdmin{User: "admin", Password: "bar"}, WithConnector(newMockConnector(t, tt.expect, &cfgs)),
+				)
+				if err := c.Set(context.TODO(), nil, tt.secretPending, nil); (err != nil) != tt.wantErr {
+					t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				for _, cfg := range cfgs {
+					if cfg.User != "admin" || cfg.Password != "bar" {
+						t.Errorf("Set() connected as %s, want admin", cfg.User)
+					}
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_Test(t *testing.T) {
+	secret := &SecretUser{User: "qux", Password: placeholderPassword, Host: "localhost", DatabaseName: "baz"}
+
+	expectIdentity := func(user, dbname string) func(m sqlmock.Sqlmock) {
+		return func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(regexp.QuoteMeta("SELECT current_user, current_database()")).
+				WillReturnRows(sqlmock.NewRows([]string{"current_user", "current_database"}).AddRow(user, dbname))
+			m.ExpectClose()
+		}
+	}
+
+	tests := []struct {
+		name    string
+		expect  func(m sqlmock.Sqlmock)
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			expect:  expectIdentity("qux", "baz"),
+			secret:  secret,
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: wrong user",
+			expect:  expectIdentity("admin", "baz"),
+			secret:  secret,
+			wantErr: true,
+		},
+		{
+			name: "unhappy path: failed to authenticate",
+			expect: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(regexp.QuoteMeta("SELECT current_user, current_database()")).
+					WillReturnError(errors.New("password authentication failed for user qux"))
+				m.ExpectClose()
+			},
+			secret:  secret,
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret content - missing host",
+			secret:  &SecretUser{User: "qux", Password: placeholderPassword, DatabaseName: "baz"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(SecretAdmin{}, WithConnector(newMockConnector(t, tt.expect, nil)))
+				if err := c.Test(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_dbClient_adminConnConfig(t *testing.T) {
+	rootCA := []byte("foo")
+	secret := &SecretUser{
+		User: "qux", Password: "quxx", Host: "db.example.com", Port: 5433, DatabaseName: "baz", SSLMode: "require",
+	}
+
+	tests := []struct {
+		name  string
+		admin SecretAdmin
+		want  ConnConfig
+	}{
+		{
+			name:  "happy path: user's secret attributes",
+			admin: SecretAdmin{User: "admin", Password: "bar"},
+			want: ConnConfig{
+				Host: "db.example.com", Port: 5433, User: "admin", Password: "bar", DatabaseName: "baz",
+				SSLMode: "require", RootCA: rootCA, ConnectTimeout: time.Second,
+			},
+		},
+		{
+			name: "happy path: admin's secret attributes",
+			admin: SecretAdmin{
+				User: "admin", Password: "bar", Host: "admin.example.com", Port: 5432, DatabaseName: "postgres",
+				SSLMode: "verify-ca",
+			},
+			want: ConnConfig{
+				Host: "admin.example.com", Port: 5432, User: "admin", Password: "bar", DatabaseName: "postgres",
+				SSLMode: "verify-ca", RootCA: rootCA, ConnectTimeout: time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(tt.admin, WithRootCA(rootCA), WithConnectTimeout(time.Second)).(*dbClient)
+				if got := c.adminConnConfig(secret); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("adminConnConfig() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultConnectTimeout the default timeout to establish the connection.
+const DefaultConnectTimeout = 10 * time.Second
+
+// defaultPort the default port of Redis.
+const defaultPort = 6379
+
+// ConnConfig defines the configuration of the connection.
+type ConnConfig struct {
+	// Addr the address of the node, host:port
+	Addr     string
+	User     string
+	Password string
+
+	// TLS whether the connection is encrypted, the server's certificate is verified.
+	TLS bool
+
+	// RootCA PEM encoded certificates of the CAs to verify the server's certificate,
+	// the system's certificates pool is used if empty.
+	RootCA []byte
+
+	// ConnectTimeout the timeout to establish the connection.
+	ConnectTimeout time.Duration
+}
+
+// Connector opens the connection.
+type Connector interface {
+	Open(cfg ConnConfig) (*redis.Client, error)
+}
+
+// ConnectorFunc the function which implements Connector.
+type ConnectorFunc func(cfg ConnConfig) (*redis.Client, error)
+
+func (f ConnectorFunc) Open(cfg ConnConfig) (*redis.Client, error) {
+	return f(cfg)
+}
+
+// RedisConnector the Connector which uses the client github.com/redis/go-redis/v9.
+type RedisConnector struct{}
+
+func (RedisConnector) Open(cfg ConnConfig) (*redis.Client, error) {
+	o := &redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.User,
+		Password: cfg.Password,
+		// RESP2 is supported by Redis 6+ and its forks
+		Protocol:    2,
+		DialTimeout: cfg.ConnectTimeout,
+	}
+
+	if cfg.TLS {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, err
+		}
+		o.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if len(cfg.RootCA) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(cfg.RootCA) {
+				return nil, errors.New("unable to add root CA to the certificates pool")
+			}
+			o.TLSConfig.RootCAs = pool
+		}
+	}
+
+	return redis.NewClient(o), nil
+}
@@ -0,0 +1,26 @@
+package redis
+
+// SecretAdmin defines the secret with the access details of the admin user which is authorized to run
+// `ACL SETUSER`, e.g. the user with the permission `+acl`.
+type SecretAdmin struct {
+	// User admin user
+	User string `json:"user"`
+	// Password admin user's password
+	Password string `json:"password"`
+	// Nodes addresses, host:port, of the nodes to change the user's passwords on. ACL is not propagated across the
+	// nodes of the cluster, nor to the replicas, hence every node must be listed. The host and port of SecretUser
+	// are used if empty.
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+// SecretUser defines the secret with the ACL user access details.
+type SecretUser struct {
+	// User ACL user
+	User string `json:"user"`
+	// Password user's password
+	Password string `json:"password"`
+	// Host Redis host
+	Host string `json:"host"`
+	// Port Redis port, 6379 by default
+	Port int `json:"port,omitempty"`
+}
@@ -0,0 +1,314 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	lambda "github.com/kislerdm/aws-lambda-secret-rotation"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewServiceClient initiates the `ServiceClient` to rotate the Redis ACL user's password.
+// The admin user is used to alter the user's passwords upon the steps setSecret and finishSecret.
+func NewServiceClient(admin SecretAdmin, opts ...Option) lambda.ServiceClient {
+	c := &aclClient{
+		admin:          admin,
+		connector:      RedisConnector{},
+		tls:            true,
+		connectTimeout: DefaultConnectTimeout,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Option defines the option of the ServiceClient.
+type Option func(c *aclClient)
+
+// WithACLSave sets whether the ACL is persisted with `ACL SAVE` after the user's passwords were changed, false by
+// default. It requires the nodes configured with the `aclfile`.
+func WithACLSave(enabled bool) Option {
+	return func(c *aclClient) {
+		c.aclSave = enabled
+	}
+}
+
+// WithConnector sets the Connector to open the connections, RedisConnector by default.
+func WithConnector(connector Connector) Option {
+	return func(c *aclClient) {
+		c.connector = connector
+	}
+}
+
+// WithTLS sets whether the connections are encrypted, true by default.
+func WithTLS(enabled bool) Option {
+	return func(c *aclClient) {
+		c.tls = enabled
+	}
+}
+
+// WithRootCA sets the PEM encoded certificates of the CAs to verify the server's certificate,
+// e.g. the self-signed CA. The system's certificates are used by default.
+func WithRootCA(pem []byte) Option {
+	return func(c *aclClient) {
+		c.rootCA = pem
+	}
+}
+
+// WithConnectTimeout sets the timeout to establish the connections, DefaultConnectTimeout by default.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *aclClient) {
+		c.connectTimeout = timeout
+	}
+}
+
+type aclClient struct {
+	admin   SecretAdmin
+	aclSave bool
+
+	connector      Connector
+	tls            bool
+	rootCA         []byte
+	connectTimeout time.Duration
+}
+
+// Create generates the new password of the pending secret, the ACL user is left intact on every node
+// until the step setSecret adds the password.
+func (c aclClient) Create(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if s.User == "" || s.Host == "" {
+		return errors.New("wrong secret content: user and host must be set")
+	}
+
+	password, err := lambda.GeneratePassword(passwordLength)
+	if err != nil {
+		return err
+	}
+
+	s.Password = password
+
+	return nil
+}
+
+// Set adds the pending password to the user's passwords on every node, the current password is kept.
+// Hence, the secret's version staged as AWSCURRENT stays valid until the step finishSecret.
+// The passwords other than the current and the pending one, e.g. added by the aborted rotations, are removed.
+func (c aclClient) Set(ctx context.Context, secretCurrent, secretPending, secretPrevious any) error {
+	s, ok := secretPending.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the pending secret")
+	}
+
+	if s.Password == "" {
+		return errors.New("pending secret is corrupt: password is empty")
+	}
+
+	current, ok := secretCurrent.(*SecretUser)
+	if !ok || current.Password == "" || current.User != s.User {
+		// the current password is unknown, the stale passwords cannot be told apart from it
+		current = nil
+	}
+
+	for _, node := range c.nodes(s) {
+		if err := c.alterUser(
+			ctx, node, s.User, func(passwords []string) []any {
+				rules := []any{">" + s.Password}
+				if current == nil {
+					return rules
+				}
+				return append(rules, removeStalePasswords(passwords, current.Password, s.Password)...)
+			},
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Test verifies that the secret authenticates the user, i.e. `AUTH user password` succeeds.
+func (c aclClient) Test(ctx context.Context, secret any) error {
+	s, ok := secret.(*SecretUser)
+	if !ok {
+		return errors.New("wrong secret type")
+	}
+
+	if s.User == "" || s.Host == "" {
+		return errors.New("wrong secret content: user and host must be set")
+	}
+
+	client, err := c.connector.Open(c.connConfig(userAddr(s), "", ""))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	conn := client.Conn()
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.AuthACL(ctx, s.User, s.Password).Err(); err != nil {
+		return errors.New(userAddr(s) + ": " + err.Error())
+	}
+	return conn.Ping(ctx).Err()
+}
+
+// Finalize removes every password but the current one from the user's passwords on every node after the pending
+// password was promoted to AWSCURRENT, i.e. the previous password and the passwords of the aborted rotations.
+// The node is not altered if the current password is not among the user's passwords to not lock the user out.
+func (c aclClient) Finalize(ctx context.Context, secretCurrent, _ any) error {
+	s, ok := secretCurrent.(*SecretUser)
+	if !ok {
+		return errors.New("wrong type of the current secret")
+	}
+
+	if s.Password == "" {
+		return errors.New("current secret is corrupt: password is empty")
+	}
+
+	hash := passwordHash(s.Password)
+	for _, node := range c.nodes(s) {
+		var found bool
+		if err := c.alterUser(
+			ctx, node, s.User, func(passwords []string) []any {
+				for _, p := range passwords {
+					if p == hash {
+						found = true
+					}
+				}
+				if !found {
+					return nil
+				}
+				return removeStalePasswords(passwords, s.Password)
+			},
+		); err != nil {
+			return err
+		}
+		if !found {
+			return errors.New(node + `: current password is not set for the user "` + s.User + `"`)
+		}
+	}
+
+	return nil
+}
+
+// removeStalePasswords returns the `ACL SETUSER` rules to remove the passwords given their hashes, but the kept ones.
+func removeStalePasswords(passwords []string, keep ...string) []any {
+	kept := make(map[string]bool, len(keep))
+	for _, p := range keep {
+		kept[passwordHash(p)] = true
+	}
+
+	var o []any
+	for _, h := range passwords {
+		if !kept[h] {
+			o = append(o, "!"+h)
+		}
+	}
+	return o
+}
+
+// alterUser runs `ACL SETUSER` on the node with the rules returned by fn given the hashes of the user's passwords,
+// the ACL is persisted if WithACLSave is set. `ACL SETUSER` is skipped if fn returns no rules.
+func (c aclClient) alterUser(ctx context.Context, node, user string, fn func(passwords []string) []any) error {
+	client, err := c.connector.Open(c.connConfig(node, c.admin.User, c.admin.Password))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	passwords, err := userPasswords(ctx, client, user)
+	if err != nil {
+		return errors.New(node + ": " + err.Error())
+	}
+
+	rules := fn(passwords)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	if err := client.Do(ctx, append([]any{"ACL", "SETUSER", user}, rules...)...).Err(); err != nil {
+		return errors.New(node + ": " + err.Error())
+	}
+
+	if c.aclSave {
+		if err := client.Do(ctx, "ACL", "SAVE").Err(); err != nil {
+			return errors.New(node + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// userPasswords returns the SHA256 hashes of the user's passwords, see `ACL GETUSER`.
+// The user must exist: `ACL SETUSER` would create the new user otherwise.
+func userPasswords(ctx context.Context, client *redis.Client, user string) ([]string, error) {
+	v, err := client.Do(ctx, "ACL", "GETUSER", user).Slice()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errors.New(`user "` + user + `" not found`)
+		}
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(v); i += 2 {
+		if k, _ := v[i].(string); k != "passwords" {
+			continue
+		}
+		values, _ := v[i+1].([]any)
+		o := make([]string, 0, len(values))
+		for _, p := range values {
+			if s, ok := p.(string); ok {
+				o = append(o, s)
+			}
+		}
+		return o, nil
+	}
+
+	return nil, nil
+}
+
+// passwordHash returns the hash of the password as listed by `ACL GETUSER`.
+func passwordHash(password string) string {
+	h := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(h[:])
+}
+
+// nodes returns the addresses of the nodes to alter the user on.
+func (c aclClient) nodes(s *SecretUser) []string {
+	if len(c.admin.Nodes) > 0 {
+		return c.admin.Nodes
+	}
+	return []string{userAddr(s)}
+}
+
+func userAddr(s *SecretUser) string {
+	port := s.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	return net.JoinHostPort(s.Host, strconv.Itoa(port))
+}
+
+func (c aclClient) connConfig(addr, user, password string) ConnConfig {
+	return ConnConfig{
+		Addr:           addr,
+		User:           user,
+		Password:       password,
+		TLS:            c.tls,
+		RootCA:         c.rootCA,
+		ConnectTimeout: c.connectTimeout,
+	}
+}
+
+// passwordLength the length of the ACL user's password, only its SHA-256 hash is stored by the server.
+const passwordLength = 32
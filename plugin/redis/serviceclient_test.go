@@ -0,0 +1,460 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// aclServer the in-process stand-in of Redis which implements the commands AUTH, PING and ACL GETUSER|SETUSER|SAVE.
+// Every user can have several passwords, the user "admin" is authorized to run ACL.
+type aclServer struct {
+	*server.Server
+
+	mu      sync.Mutex
+	users   map[string]map[string]bool
+	aclFile bool
+	saved   int
+}
+
+func newACLServer(t *testing.T, users map[string][]string) *aclServer {
+	t.Helper()
+	srv, err := server.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	s := &aclServer{Server: srv, users: map[string]map[string]bool{}}
+	for user, passwords := range users {
+		s.users[user] = map[string]bool{}
+		for _, p := range passwords {
+			s.users[user][passwordHash(p)] = true
+		}
+	}
+
+	for cmd, fn := range map[string]server.Cmd{"AUTH": s.auth, "PING": s.ping, "ACL": s.acl} {
+		if err := srv.Register(cmd, fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func (s *aclServer) addr() string {
+	return s.Addr().String()
+}
+
+// passwords returns the user's passwords among the given.
+func (s *aclServer) passwords(user string, passwords ...string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var o []string
+	for _, p := range passwords {
+		if s.users[user][passwordHash(p)] {
+			o = append(o, p)
+		}
+	}
+	return o
+}
+
+func (s *aclServer) auth(c *server.Peer, cmd string, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(args) != 2 || !s.users[args[0]][passwordHash(args[1])] {
+		c.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+	c.Ctx = args[0]
+	c.WriteOK()
+}
+
+func (s *aclServer) ping(c *server.Peer, cmd string, args []string) {
+	if c.Ctx == nil {
+		c.WriteError("NOAUTH Authentication required.")
+		return
+	}
+	c.WriteInline("PONG")
+}
+
+func (s *aclServer) acl(c *server.Peer, cmd string, args []string) {
+	if c.Ctx != "admin" {
+		c.WriteError("NOPERM this user has no permissions to run the 'acl' command")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "GETUSER":
+		passwords, ok := s.users[args[1]]
+		if !ok {
+			c.WriteNull()
+			return
+		}
+		c.WriteLen(4)
+		c.WriteBulk("flags")
+		c.WriteStrings([]string{"on"})
+		c.WriteBulk("passwords")
+		var hashes []string
+		for h := range passwords {
+			hashes = append(hashes, h)
+		}
+		c.WriteStrings(hashes)
+
+	case "SETUSER":
+		passwords, ok := s.users[args[1]]
+		if !ok {
+			passwords = map[string]bool{}
+			s.users[args[1]] = passwords
+		}
+		for _, rule := range args[2:] {
+			h := passwordHash(rule[1:])
+			if rule[0] == '!' {
+				h = rule[1:]
+			}
+			switch rule[0] {
+			case '>':
+				passwords[h] = true
+			case '<', '!':
+				if !passwords[h] {
+					c.WriteError(
+						"ERR Error in ACL SETUSER modifier '" + rule[:1] + "...': The password you are trying to remove from the user does not exist",
+					)
+					return
+				}
+				delete(passwords, h)
+			}
+		}
+		c.WriteOK()
+
+	case "SAVE":
+		if !s.aclFile {
+			c.WriteError("ERR This Redis instance is not configured to use an ACL file.")
+			return
+		}
+		s.saved++
+		c.WriteOK()
+
+	default:
+		c.WriteError("ERR unknown subcommand '" + args[0] + "'")
+	}
+}
+
+func Test_aclClient_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path",
+			secret:  &SecretUser{User: "qux", Password: "quxx", Host: "localhost"},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: missing user",
+			secret:  &SecretUser{Password: "quxx", Host: "localhost"},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{User: "qux", Password: "quxx", Host: "localhost"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := NewServiceClient(SecretAdmin{}).Create(context.TODO(), tt.secret)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && tt.secret.(*SecretUser).Password == "quxx" {
+					t.Errorf("Create() failed to mutate a SecretUser obj")
+				}
+			},
+		)
+	}
+}
+
+func Test_aclClient_Set(t *testing.T) {
+	tests := []struct {
+		name          string
+		users         map[string][]string
+		aclSave       bool
+		aclFile       bool
+		secretCurrent any
+		secretPending any
+		wantPasswords []string
+		wantSaved     int
+		wantErr       bool
+	}{
+		{
+			name:          "happy path: current password kept",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old"}},
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old", "new"},
+			wantErr:       false,
+		},
+		{
+			name:          "happy path: pending password set already",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old", "new"}},
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old", "new"},
+			wantErr:       false,
+		},
+		{
+			name:          "happy path: password of the aborted rotation removed",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old", "stale"}},
+			secretCurrent: &SecretUser{User: "qux", Password: "old"},
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old", "new"},
+			wantErr:       false,
+		},
+		{
+			name:          "happy path: current password unknown, passwords other than pending kept",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old", "stale"}},
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old", "new", "stale"},
+			wantErr:       false,
+		},
+		{
+			name:          "happy path: ACL saved",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old"}},
+			aclSave:       true,
+			aclFile:       true,
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old", "new"},
+			wantSaved:     1,
+			wantErr:       false,
+		},
+		{
+			name:          "unhappy path: ACL file not configured",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old"}},
+			aclSave:       true,
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old", "new"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: user not found",
+			users:         map[string][]string{"admin": {"bar"}},
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: admin not authenticated",
+			users:         map[string][]string{"admin": {"baz"}, "qux": {"old"}},
+			secretPending: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords: []string{"old"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: empty password",
+			users:         map[string][]string{"admin": {"bar"}, "qux": {"old"}},
+			secretPending: &SecretUser{User: "qux"},
+			wantPasswords: []string{"old"},
+			wantErr:       true,
+		},
+		{
+			name:          "unhappy path: wrong secret type",
+			secretPending: SecretUser{},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				srv := newACLServer(t, tt.users)
+				srv.aclFile = tt.aclFile
+
+				c := NewServiceClient(
+					SecretAdmin{User: "admin", Password: "bar", Nodes: []string{srv.addr()}},
+					WithTLS(false), WithACLSave(tt.aclSave),
+				)
+				if err := c.Set(context.TODO(), tt.secretCurrent, tt.secretPending, nil); (err != nil) != tt.wantErr {
+					t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				if got := srv.passwords("qux", "old", "new", "stale"); strings.Join(got, ",") != strings.Join(
+					tt.wantPasswords, ",",
+				) {
+					t.Errorf("Set() passwords = %v, want %v", got, tt.wantPasswords)
+				}
+				if srv.saved != tt.wantSaved {
+					t.Errorf("Set() ACL saved %d times, want %d", srv.saved, tt.wantSaved)
+				}
+			},
+		)
+	}
+}
+
+func Test_aclClient_Set_nodes(t *testing.T) {
+	users := map[string][]string{"admin": {"bar"}, "qux": {"old"}}
+	nodes := []*aclServer{newACLServer(t, users), newACLServer(t, users)}
+
+	c := NewServiceClient(
+		SecretAdmin{User: "admin", Password: "bar", Nodes: []string{nodes[0].addr(), nodes[1].addr()}},
+		WithTLS(false),
+	)
+	if err := c.Set(context.TODO(), nil, &SecretUser{User: "qux", Password: "new"}, nil); err != nil {
+		t.Fatalf("Set() unexpected error = %v", err)
+	}
+
+	for i, srv := range nodes {
+		if got := srv.passwords("qux", "old", "new"); len(got) != 2 {
+			t.Errorf("Set() node %d passwords = %v, want [old new]", i, got)
+		}
+	}
+}
+
+func Test_aclClient_Test(t *testing.T) {
+	srv := newACLServer(t, map[string][]string{"qux": {"old", "new"}})
+	host, port := srv.Addr().IP.String(), srv.Addr().Port
+
+	tests := []struct {
+		name    string
+		secret  any
+		wantErr bool
+	}{
+		{
+			name:    "happy path: new password",
+			secret:  &SecretUser{User: "qux", Password: "new", Host: host, Port: port},
+			wantErr: false,
+		},
+		{
+			name:    "happy path: current password",
+			secret:  &SecretUser{User: "qux", Password: "old", Host: host, Port: port},
+			wantErr: false,
+		},
+		{
+			name:    "unhappy path: wrong password",
+			secret:  &SecretUser{User: "qux", Password: "foo", Host: host, Port: port},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong user",
+			secret:  &SecretUser{User: "quxx", Password: "new", Host: host, Port: port},
+			wantErr: true,
+		},
+		{
+			name:    "unhappy path: wrong secret type",
+			secret:  SecretUser{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := NewServiceClient(SecretAdmin{}, WithTLS(false))
+				if err := c.Test(context.TODO(), tt.secret); (err != nil) != tt.wantErr {
+					t.Errorf("Test() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func Test_aclClient_Finalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		users          map[string][]string
+		aclSave        bool
+		secretPrevious any
+		wantPasswords  []string
+		wantSaved      int
+		wantErr        bool
+	}{
+		{
+			name:           "happy path: previous password removed",
+			users:          map[string][]string{"admin": {"bar"}, "qux": {"old", "new"}},
+			aclSave:        true,
+			secretPrevious: &SecretUser{User: "qux", Password: "old"},
+			wantPasswords:  []string{"new"},
+			wantSaved:      1,
+			wantErr:        false,
+		},
+		{
+			name:           "happy path: previous password removed already",
+			users:          map[string][]string{"admin": {"bar"}, "qux": {"new"}},
+			aclSave:        true,
+			secretPrevious: &SecretUser{User: "qux", Password: "old"},
+			wantPasswords:  []string{"new"},
+			wantErr:        false,
+		},
+		{
+			name:           "happy path: no previous version",
+			users:          map[string][]string{"admin": {"bar"}, "qux": {"new"}},
+			secretPrevious: nil,
+			wantPasswords:  []string{"new"},
+			wantErr:        false,
+		},
+		{
+			name:           "happy path: previous version of the same password",
+			users:          map[string][]string{"admin": {"bar"}, "qux": {"new"}},
+			secretPrevious: &SecretUser{User: "qux", Password: "new"},
+			wantPasswords:  []string{"new"},
+			wantErr:        false,
+		},
+		{
+			name:           "unhappy path: user not found",
+			users:          map[string][]string{"admin": {"bar"}},
+			secretPrevious: &SecretUser{User: "qux", Password: "old"},
+			wantErr:        true,
+		},
+		{
+			name:           "happy path: password of the aborted rotation removed",
+			users:          map[string][]string{"admin": {"bar"}, "qux": {"old", "new", "stale"}},
+			secretPrevious: &SecretUser{User: "qux", Password: "old"},
+			wantPasswords:  []string{"new"},
+			wantErr:        false,
+		},
+		{
+			name:           "unhappy path: current password not set",
+			users:          map[string][]string{"admin": {"bar"}, "qux": {"old", "stale"}},
+			secretPrevious: &SecretUser{User: "qux", Password: "old"},
+			wantPasswords:  []string{"old", "stale"},
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				srv := newACLServer(t, tt.users)
+				srv.aclFile = true
+
+				c := NewServiceClient(
+					SecretAdmin{User: "admin", Password: "bar", Nodes: []string{srv.addr()}},
+					WithTLS(false), WithACLSave(tt.aclSave),
+				).(*aclClient)
+				if err := c.Finalize(
+					context.TODO(), &SecretUser{User: "qux", Password: "new"}, tt.secretPrevious,
+				); (err != nil) != tt.wantErr {
+					t.Fatalf("Finalize() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				if got := srv.passwords("qux", "old", "new", "stale"); strings.Join(got, ",") != strings.Join(
+					tt.wantPasswords, ",",
+				) {
+					t.Errorf("Finalize() passwords = %v, want %v", got, tt.wantPasswords)
+				}
+				if srv.saved != tt.wantSaved {
+					t.Errorf("Finalize() ACL saved %d times, want %d", srv.saved, tt.wantSaved)
+				}
+			},
+		)
+	}
+}
+
+func Test_passwordHash(t *testing.T) {
+	// see https://redis.io/commands/acl-setuser/
+	const want = "c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"
+	if got := passwordHash("foobar"); got != want {
+		t.Errorf("passwordHash() = %v, want %v", got, want)
+	}
+}